@@ -0,0 +1,103 @@
+/*
+Command import loads catalog records in bulk, the same way as `POST /admin/books/import`.
+
+	go run cmd/import/main.go -format marc21 -dry-run records.mrc
+	go run cmd/import/main.go -format csv -map "isbn_13=ISBN,title=Book Title" books.csv
+
+The report is written to stdout as JSON. The command exits with status 1 when any record failed.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/DarrelA/e-lib/config"
+	"github.com/DarrelA/e-lib/internal/infrastructure/catalog"
+	"github.com/DarrelA/e-lib/internal/infrastructure/db/postgres"
+	logger "github.com/DarrelA/e-lib/internal/infrastructure/logger/zerolog"
+	interfaceSvc "github.com/DarrelA/e-lib/internal/interface/services"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	logFilePath = "./config/app.log"
+)
+
+func main() {
+	format := flag.String("format", catalog.FormatCSV, "source format: csv, marc21, marcxml or onix")
+	mapping := flag.String("map", "", "CSV column mapping, e.g. \"isbn_13=ISBN,title=Book Title\"")
+	dryRun := flag.Bool("dry-run", false, "report the changes without saving them")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logFile := logger.CreateAppLog(logFilePath)
+	logger.NewZeroLogger(logFile)
+	defer logFile.Close()
+
+	os.Exit(run(flag.Arg(0), *format, *mapping, *dryRun))
+}
+
+func run(filePath string, format string, mapping string, dryRun bool) int {
+	envConfig := config.NewEnvConfig()
+	envConfig.LoadLogConfig()
+	envConfig.LoadPostgresConfig()
+	config, ok := envConfig.(*config.EnvConfig)
+	if !ok {
+		log.Error().Msg("failed to load environment configuration")
+		return 1
+	}
+
+	columnMapping, restErr := interfaceSvc.ParseColumnMapping(mapping)
+	if restErr != nil {
+		log.Error().Msg(restErr.Message)
+		return 2
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to open import file")
+		return 1
+	}
+	defer file.Close()
+
+	postgresDB := &postgres.PostgresDB{}
+	postgresConnection := postgresDB.Connect(config.PostgresDBConfig)
+	defer postgresConnection.Disconnect()
+	postgresDBInstance := postgresConnection.(*postgres.PostgresDB)
+
+	catalogImportService := interfaceSvc.NewCatalogImportService(
+		postgres.NewBookRepository(postgresDBInstance.Dbpool),
+		postgres.NewSearchRepository(postgresDBInstance.Dbpool),
+		catalog.NewParser,
+	)
+
+	report, restErr := catalogImportService.ImportBooks(uuid.NewString(), format, columnMapping, file, dryRun)
+	if restErr != nil {
+		log.Error().Msg(restErr.Message)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Error().Err(err).Msg("failed to write import report")
+		return 1
+	}
+
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
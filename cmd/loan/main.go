@@ -13,17 +13,25 @@ import (
 	"github.com/DarrelA/e-lib/internal/application/dto"
 	"github.com/DarrelA/e-lib/internal/domain/entity"
 	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/DarrelA/e-lib/internal/infrastructure/catalog"
 	"github.com/DarrelA/e-lib/internal/infrastructure/db/postgres"
 	"github.com/DarrelA/e-lib/internal/infrastructure/db/redis"
 	logger "github.com/DarrelA/e-lib/internal/infrastructure/logger/zerolog"
+	"github.com/DarrelA/e-lib/internal/infrastructure/mail"
 	interfaceSvc "github.com/DarrelA/e-lib/internal/interface/services"
 	"github.com/DarrelA/e-lib/internal/interface/transport/rest"
+	"github.com/DarrelA/e-lib/internal/interface/worker"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
 )
 
 const (
 	logFilePath = "./config/app.log"
+
+	loanExpiryInterval       = time.Minute
+	holdOfferExpiryInterval  = time.Minute
+	loanNotificationInterval = 5 * time.Minute
+	workerBatchSize          = 100
 )
 
 func main() {
@@ -31,17 +39,27 @@ func main() {
 	logger.NewZeroLogger(logFile)
 	config := initializeEnv()
 	redisConn, postgresConn, postgresDBInstance,
-		userRepository, bookRepository, loanRepository, sessionRepository := initializeDatabases(config)
+		userRepository, bookRepository, loanRepository, holdRepository, sessionRepository, idempotencyRepository,
+		oauth2StateRepository, searchRepository, apiTokenRepository := initializeDatabases(config)
 
 	// Use `WaitGroup` when you just need to wait for tasks to complete without exchanging data.
 	// Use channels when you need to signal task completion and possibly exchange data.
 	var wg sync.WaitGroup
 	appInstance := initializeServer(&wg, config, postgresDBInstance,
-		userRepository, bookRepository, loanRepository, sessionRepository)
+		userRepository, bookRepository, loanRepository, holdRepository, sessionRepository, idempotencyRepository,
+		oauth2StateRepository, searchRepository, apiTokenRepository)
 
 	wg.Wait()
 
-	waitForShutdown(appInstance, redisConn, postgresConn)
+	notificationRepository := postgres.NewNotificationRepository(postgresDBInstance.Dbpool)
+	notificationService := interfaceSvc.NewNotificationService(notificationRepository, mail.NewSMTPMailer(config.SMTPConfig))
+
+	stopWorkers := startWorkers(
+		worker.NewBatchWorker("loan expiry", loanExpiryInterval, workerBatchSize, loanRepository.ExpireLoans),
+		worker.NewBatchWorker("hold offer expiry", holdOfferExpiryInterval, workerBatchSize, holdRepository.ExpireHoldOffers),
+		worker.NewBatchWorker("loan notifications", loanNotificationInterval, workerBatchSize, notificationService.SendLoanNotifications),
+	)
+	waitForShutdown(appInstance, stopWorkers, redisConn, postgresConn)
 	log.Info().Msg("exiting...")
 	logFile.Close()
 	os.Exit(0)
@@ -54,6 +72,9 @@ func initializeEnv() *config.EnvConfig {
 	envConfig.LoadPostgresConfig()
 	envConfig.LoadRedisConfig()
 	envConfig.LoadOAuth2Config()
+	envConfig.LoadOIDCConfig()
+	envConfig.LoadSessionConfig()
+	envConfig.LoadSMTPConfig()
 	config, ok := envConfig.(*config.EnvConfig)
 	if !ok {
 		log.Error().Msg("failed to load environment configuration")
@@ -64,7 +85,9 @@ func initializeEnv() *config.EnvConfig {
 
 func initializeDatabases(config *config.EnvConfig) (
 	repository.DatabaseConnection, repository.DatabaseConnection,
-	*postgres.PostgresDB, repository.UserRepository, repository.BookRepository, repository.LoanRepository, repository.SessionRepository,
+	*postgres.PostgresDB, repository.UserRepository, repository.BookRepository, repository.LoanRepository, repository.HoldRepository,
+	repository.SessionRepository, repository.IdempotencyRepository, repository.OAuth2StateRepository,
+	repository.SearchRepository, repository.APITokenRepository,
 ) {
 	postgresDB := &postgres.PostgresDB{}
 	postgresConnection := postgresDB.Connect(config.PostgresDBConfig)
@@ -76,14 +99,24 @@ func initializeDatabases(config *config.EnvConfig) (
 	userRepository := postgres.NewUserRepository(postgresDBInstance.Dbpool)
 	bookRepository := postgres.NewBookRepository(postgresDBInstance.Dbpool)
 	loanRepository := postgres.NewLoanRepository(postgresDBInstance.Dbpool)
+	holdRepository := postgres.NewHoldRepository(postgresDBInstance.Dbpool)
+	apiTokenRepository := postgres.NewAPITokenRepository(postgresDBInstance.Dbpool)
+
+	searchRepository := postgres.NewSearchRepository(postgresDBInstance.Dbpool)
+	if restErr := searchRepository.IndexBooks(""); restErr != nil {
+		log.Error().Err(restErr).Msg("failed to build the search index, /Search finds no books until they are indexed")
+	}
 
 	redisDB := &redis.RedisDB{}
 	redisConnection := redisDB.Connect(config.RedisDBConfig)
 	redisDBInstance := redisConnection.(*redis.RedisDB)
-	sessionRepository := redis.NewSessionRepository(redisDBInstance.RedisClient)
+	sessionRepository := redis.NewSessionRepository(redisDBInstance.RedisClient, config.SessionConfig)
+	idempotencyRepository := redis.NewIdempotencyRepository(redisDBInstance.RedisClient)
+	oauth2StateRepository := redis.NewOAuth2StateRepository(redisDBInstance.RedisClient)
 
 	return redisConnection, postgresConnection, postgresDBInstance,
-		userRepository, bookRepository, loanRepository, sessionRepository
+		userRepository, bookRepository, loanRepository, holdRepository, sessionRepository, idempotencyRepository,
+		oauth2StateRepository, searchRepository, apiTokenRepository
 }
 
 func initializeServer(
@@ -92,19 +125,33 @@ func initializeServer(
 	userRepository repository.UserRepository,
 	bookRepository repository.BookRepository,
 	loanRepository repository.LoanRepository,
+	holdRepository repository.HoldRepository,
 	sessionRepository repository.SessionRepository,
+	idempotencyRepository repository.IdempotencyRepository,
+	oauth2StateRepository repository.OAuth2StateRepository,
+	searchRepository repository.SearchRepository,
+	apiTokenRepository repository.APITokenRepository,
 ) *fiber.App {
 
 	wg.Add(1)
 	defer wg.Done()
 
-	googleOAuth2Service := interfaceSvc.NewGoogleOAuth2(config.OAuth2Config, userRepository, sessionRepository)
+	googleOAuth2Service := interfaceSvc.NewGoogleOAuth2(config.OAuth2Config, userRepository, sessionRepository, oauth2StateRepository)
+	oidcService := interfaceSvc.NewOIDC(config.OIDCProviders, config.OAuth2Config.RedirectAllowList,
+		userRepository, sessionRepository, oauth2StateRepository)
+	sessionService := interfaceSvc.NewSessionService(sessionRepository)
+	apiTokenService := interfaceSvc.NewAPITokenService(apiTokenRepository)
 	bookService := interfaceSvc.NewBookService(bookRepository)
-	loanService := interfaceSvc.NewLoanService(bookRepository, loanRepository)
+	loanService := interfaceSvc.NewLoanService(bookRepository, loanRepository, holdRepository)
+	holdService := interfaceSvc.NewHoldService(bookRepository, holdRepository)
+	searchService := interfaceSvc.NewSearchService(searchRepository)
+	catalogService := interfaceSvc.NewCatalogService(bookRepository, searchRepository)
+	catalogImportService := interfaceSvc.NewCatalogImportService(bookRepository, searchRepository, catalog.NewParser)
+	opdsService := interfaceSvc.NewOPDSService(bookRepository, searchRepository, loanService)
 
 	// Higher-Order Functions
-	newSessionFunc := func(userID int64) (string, *apperrors.RestErr) {
-		return sessionRepository.NewSession(userID)
+	newSessionFunc := func(userID int64, role string, client entity.SessionClient, rememberMe bool) (string, time.Duration, *apperrors.RestErr) {
+		return sessionRepository.NewSession(userID, role, client, rememberMe)
 	}
 	saveUserFunc := func(user *dto.GoogleOAuth2UserRes, provider string) (*entity.User, *apperrors.RestErr) {
 		return userRepository.SaveUser(user, provider)
@@ -113,15 +160,24 @@ func initializeServer(
 	getSessionDataFunc := func(sessionID string) (*entity.Session, *apperrors.RestErr) {
 		return sessionRepository.GetSessionData(sessionID)
 	}
+	refreshSessionFunc := func(sessionID string, session *entity.Session, role string) (string, time.Duration, *apperrors.RestErr) {
+		return sessionRepository.RefreshSession(sessionID, session, role)
+	}
+	getAPITokenByHashFunc := func(tokenHash string) (*entity.APIToken, *apperrors.RestErr) {
+		return apiTokenRepository.GetAPITokenByHash(tokenHash)
+	}
 	getUserByIDFunc := func(userID int64) (dto.UserDetail, *apperrors.RestErr) {
 		return userRepository.GetUserByID(userID)
 	}
 
 	appInstance := rest.NewRouter(
-		config, googleOAuth2Service, postgresDBInstance,
-		bookService, loanService,
+		config, googleOAuth2Service, oidcService, sessionService, apiTokenService, postgresDBInstance,
+		bookService, loanService, holdService, searchService,
+		catalogService, catalogImportService,
+		opdsService,
 		newSessionFunc, saveUserFunc,
-		getSessionDataFunc, getUserByIDFunc,
+		getSessionDataFunc, refreshSessionFunc, getAPITokenByHashFunc, getUserByIDFunc,
+		idempotencyRepository,
 	)
 
 	go func() {
@@ -130,7 +186,29 @@ func initializeServer(
 	return appInstance
 }
 
-func waitForShutdown(appInstance *fiber.App, redisConn repository.DatabaseConnection, postgresConn repository.DatabaseConnection) {
+// startWorkers runs the background jobs until the returned function stops them and waits for them to finish.
+func startWorkers(workers ...*worker.BatchWorker) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	for _, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Run(ctx)
+		}()
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func waitForShutdown(
+	appInstance *fiber.App, stopWorkers func(),
+	redisConn repository.DatabaseConnection, postgresConn repository.DatabaseConnection,
+) {
 	sigChan := make(chan os.Signal, 1) // Create a channel to listen for OS signals
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	<-sigChan // Block until a signal is received
@@ -144,6 +222,8 @@ func waitForShutdown(appInstance *fiber.App, redisConn repository.DatabaseConnec
 	cancel()
 	log.Info().Msg("app instance has shutdown")
 
+	stopWorkers()
+
 	redisConn.Disconnect()
 	postgresConn.Disconnect()
 }
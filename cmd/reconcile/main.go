@@ -0,0 +1,74 @@
+/*
+Command reconcile checks the available copies of every book against its total copies less its active loans.
+
+	go run cmd/reconcile/main.go        # report the drift
+	go run cmd/reconcile/main.go -fix   # report and fix it
+
+The report is written to stdout as JSON. The command exits with status 1 when drift remains,
+i.e. without -fix, or for over-lent books, whose copies only a librarian can correct.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/DarrelA/e-lib/config"
+	"github.com/DarrelA/e-lib/internal/infrastructure/db/postgres"
+	logger "github.com/DarrelA/e-lib/internal/infrastructure/logger/zerolog"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	logFilePath = "./config/app.log"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "set the available copies of drifted books to their total copies less their active loans")
+	flag.Parse()
+
+	logFile := logger.CreateAppLog(logFilePath)
+	logger.NewZeroLogger(logFile)
+	defer logFile.Close()
+
+	os.Exit(run(*fix))
+}
+
+func run(fix bool) int {
+	envConfig := config.NewEnvConfig()
+	envConfig.LoadLogConfig()
+	envConfig.LoadPostgresConfig()
+	config, ok := envConfig.(*config.EnvConfig)
+	if !ok {
+		log.Error().Msg("failed to load environment configuration")
+		return 1
+	}
+
+	postgresDB := &postgres.PostgresDB{}
+	postgresConnection := postgresDB.Connect(config.PostgresDBConfig)
+	defer postgresConnection.Disconnect()
+	postgresDBInstance := postgresConnection.(*postgres.PostgresDB)
+
+	bookRepository := postgres.NewBookRepository(postgresDBInstance.Dbpool)
+	copiesDrifts, restErr := bookRepository.ReconcileCopies(uuid.NewString(), fix)
+	if restErr != nil {
+		log.Error().Msg(restErr.Message)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(copiesDrifts); err != nil {
+		log.Error().Err(err).Msg("failed to write reconcile report")
+		return 1
+	}
+
+	for _, drift := range copiesDrifts {
+		if !drift.Fixed {
+			return 1
+		}
+	}
+	return 0
+}
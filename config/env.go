@@ -2,7 +2,11 @@ package config
 
 import (
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/DarrelA/e-lib/internal/domain/entity"
 	"github.com/rs/zerolog"
@@ -13,12 +17,18 @@ const (
 	errMsgVarNotSet = "%s is not set"
 )
 
+// An OIDC provider name is part of its login URLs and of its env variables.
+var oidcProviderNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
 type LoadEnvConfig interface {
 	LoadServerConfig()
 	LoadLogConfig()
 	LoadPostgresConfig()
 	LoadRedisConfig()
 	LoadOAuth2Config()
+	LoadOIDCConfig()
+	LoadSessionConfig()
+	LoadSMTPConfig()
 }
 
 type EnvConfig struct {
@@ -97,7 +107,104 @@ func (e *EnvConfig) LoadOAuth2Config() {
 			"https://www.googleapis.com/auth/userinfo.email",
 			"https://www.googleapis.com/auth/userinfo.profile",
 		},
+		RedirectAllowList: splitEnvList("OAUTH2_REDIRECT_ALLOWLIST"),
+	}
+}
+
+// LoadOIDCConfig loads the providers named in OIDC_PROVIDERS, each from its OIDC_<NAME>_* variables.
+func (e *EnvConfig) LoadOIDCConfig() {
+	e.OIDCProviders = nil
+	for _, name := range splitEnvList("OIDC_PROVIDERS") {
+		name = strings.ToLower(name)
+		if !oidcProviderNamePattern.MatchString(name) {
+			log.Error().Msgf("OIDC provider [%s] is skipped; only lowercase letters, digits and '_' are accepted", name)
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		scopes := splitEnvList(prefix + "SCOPES")
+		if len(scopes) == 0 {
+			scopes = []string{"email", "profile"}
+		}
+		if !slices.Contains(scopes, "openid") {
+			scopes = append([]string{"openid"}, scopes...)
+		}
+
+		e.OIDCProviders = append(e.OIDCProviders, &entity.OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    checkEmptyEnvVar(prefix + "ISSUER"),
+			ClientID:     checkEmptyEnvVar(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  "http://localhost:" + e.Port + "/auth/oidc/" + name + "/callback",
+			Scopes:       scopes,
+			TrustEmail:   parseEnvBool(prefix+"TRUST_EMAIL", false),
+		})
+	}
+}
+
+func (e *EnvConfig) LoadSessionConfig() {
+	e.SessionConfig = &entity.SessionConfig{
+		IdleTimeout:                parseEnvDuration("SESSION_IDLE_TIMEOUT", 30*time.Minute),
+		AbsoluteLifetime:           parseEnvDuration("SESSION_ABSOLUTE_LIFETIME", 24*time.Hour),
+		RememberMeIdleTimeout:      parseEnvDuration("SESSION_REMEMBER_ME_IDLE_TIMEOUT", 7*24*time.Hour),
+		RememberMeAbsoluteLifetime: parseEnvDuration("SESSION_REMEMBER_ME_ABSOLUTE_LIFETIME", 30*24*time.Hour),
+	}
+
+	if e.SessionConfig.IdleTimeout > e.SessionConfig.AbsoluteLifetime ||
+		e.SessionConfig.RememberMeIdleTimeout > e.SessionConfig.RememberMeAbsoluteLifetime {
+		log.Warn().Msg("a session idle timeout is longer than its absolute lifetime, which ends the session first")
+	}
+}
+
+func (e *EnvConfig) LoadSMTPConfig() {
+	e.SMTPConfig = &entity.SMTPConfig{
+		Host:     checkEmptyEnvVar("SMTP_HOST"),
+		Port:     checkEmptyEnvVar("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     checkEmptyEnvVar("SMTP_FROM"),
+	}
+}
+
+// splitEnvList returns the comma-separated values of an optional variable.
+func splitEnvList(envVar string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(envVar), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// parseEnvDuration returns an optional duration variable such as "30m" or "168h", or its default.
+func parseEnvDuration(envVar string, defaultValue time.Duration) time.Duration {
+	valueStr := os.Getenv(envVar)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := time.ParseDuration(valueStr)
+	if err != nil || value <= 0 {
+		log.Error().Msgf("%s is set to [%s]; a positive duration such as '30m' or '168h' is expected, %s is used", envVar, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// parseEnvBool returns an optional boolean variable such as "true" or "false", or its default.
+func parseEnvBool(envVar string, defaultValue bool) bool {
+	valueStr := os.Getenv(envVar)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		log.Error().Msgf("%s is set to [%s]; 'true' or 'false' is expected, %t is used", envVar, valueStr, defaultValue)
+		return defaultValue
 	}
+	return value
 }
 
 func checkEmptyEnvVar(envVar string) string {
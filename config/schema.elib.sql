@@ -1,15 +1,73 @@
+-- Trigram matching for the forgiving title search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Maps an ISO 639-1 language code onto the text search configuration used for stemming
+CREATE OR REPLACE FUNCTION elib_regconfig(language text) RETURNS regconfig AS $$
+  SELECT CASE lower(language)
+    WHEN 'da' THEN 'danish'
+    WHEN 'de' THEN 'german'
+    WHEN 'en' THEN 'english'
+    WHEN 'es' THEN 'spanish'
+    WHEN 'fi' THEN 'finnish'
+    WHEN 'fr' THEN 'french'
+    WHEN 'hu' THEN 'hungarian'
+    WHEN 'it' THEN 'italian'
+    WHEN 'nl' THEN 'dutch'
+    WHEN 'no' THEN 'norwegian'
+    WHEN 'pt' THEN 'portuguese'
+    WHEN 'ro' THEN 'romanian'
+    WHEN 'ru' THEN 'russian'
+    WHEN 'sv' THEN 'swedish'
+    WHEN 'tr' THEN 'turkish'
+    ELSE 'simple'
+  END::regconfig;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Create the LoanPolicies table (the lending rules of each patron category)
+CREATE TABLE IF NOT EXISTS LoanPolicies(
+  patron_category varchar(32) PRIMARY KEY,
+  loan_days integer NOT NULL CHECK (loan_days > 0),
+  extension_days integer NOT NULL CHECK (extension_days > 0),
+  max_renewals integer NOT NULL CHECK (max_renewals >= 0),
+  max_active_loans integer NOT NULL CHECK (max_active_loans > 0),
+  renewal_window_days integer NOT NULL DEFAULT 0 CHECK (renewal_window_days >= 0), -- 0 allows extending at any time
+  hold_offer_hours integer NOT NULL DEFAULT 48 CHECK (hold_offer_hours > 0), -- How long an offered copy is reserved
+  updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- For databases created before hold offers followed the loan policy, which also predate the 7-day renewal window
+-- of the standard category; the block runs once, so a window set by a librarian afterwards is kept.
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                 WHERE table_schema = current_schema() AND table_name = 'loanpolicies'
+                   AND column_name = 'hold_offer_hours') THEN
+    ALTER TABLE LoanPolicies ADD COLUMN hold_offer_hours integer NOT NULL DEFAULT 48 CHECK (hold_offer_hours > 0);
+    UPDATE LoanPolicies SET renewal_window_days = 7, updated_at = CURRENT_TIMESTAMP
+    WHERE patron_category = 'standard' AND renewal_window_days = 0;
+  END IF;
+END $$;
+
+-- Every patron starts in the standard category
+INSERT INTO LoanPolicies (patron_category, loan_days, extension_days, max_renewals, max_active_loans, renewal_window_days,
+  hold_offer_hours)
+VALUES ('standard', 28, 21, 1, 10, 7, 48)
+ON CONFLICT (patron_category) DO NOTHING;
+
 -- Create the User table
 CREATE TABLE IF NOT EXISTS Users(
   id serial PRIMARY KEY,
   name varchar(255) NOT NULL,
   email varchar(255) UNIQUE NOT NULL, -- Email should be unique
+  role varchar(20) NOT NULL DEFAULT 'patron' CHECK (role IN ('patron', 'librarian')),
+  patron_category varchar(32) NOT NULL DEFAULT 'standard' REFERENCES LoanPolicies(patron_category),
   created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
   updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 -- Create the AuthProviders table
 CREATE TABLE IF NOT EXISTS AuthProviders(
-  id varchar(45) PRIMARY KEY,
+  id varchar(255) NOT NULL, -- The subject of the user at the provider, which is unique only within it
   user_id bigint NOT NULL,
   name varchar(255) NOT NULL,
   email varchar(255) NOT NULL,
@@ -18,16 +76,69 @@ CREATE TABLE IF NOT EXISTS AuthProviders(
   created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
   updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
   FOREIGN KEY (user_id) REFERENCES Users(id),
+  PRIMARY KEY (provider, id),
   UNIQUE (provider, email) -- Ensure that provider and email combination is unique
 );
 
--- Create the Book table
+-- Create the Book table (one row per edition)
 CREATE TABLE IF NOT EXISTS Books(
   uuid uuid PRIMARY KEY,
-  title varchar(255) UNIQUE NOT NULL, -- Title should be unique
-  available_copies integer NOT NULL DEFAULT 0 CHECK (available_copies >= 0), -- Ensure non-negative
+  isbn_13 char(13) UNIQUE NOT NULL, -- ISBN-13 should be unique
+  isbn_10 char(10) UNIQUE,
+  title varchar(255) NOT NULL,
+  edition varchar(100) NOT NULL DEFAULT '',
+  publisher varchar(255) NOT NULL DEFAULT '',
+  language varchar(8) NOT NULL DEFAULT 'en', -- ISO 639-1 code
+  publication_year smallint CHECK (publication_year BETWEEN 1 AND 9999),
+  description text NOT NULL DEFAULT '',
+  total_copies integer NOT NULL DEFAULT 0 CHECK (total_copies >= 0), -- Copies (or licences) the library owns
+  available_copies integer NOT NULL DEFAULT 0 CHECK (available_copies >= 0), -- total_copies less active loans, see cmd/reconcile
+  withdrawn_at timestamp with time zone, -- Withdrawn titles are hidden from the catalog
   created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
-  updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+  updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CHECK (available_copies <= total_copies)
+);
+
+-- Create the Author table
+CREATE TABLE IF NOT EXISTS Authors(
+  id serial PRIMARY KEY,
+  name varchar(255) UNIQUE NOT NULL, -- Name should be unique
+  created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create the BookAuthors table (many-to-many between Books and Authors)
+CREATE TABLE IF NOT EXISTS BookAuthors(
+  book_uuid uuid NOT NULL,
+  author_id integer NOT NULL,
+  position smallint NOT NULL DEFAULT 0, -- Order of the author on the title page
+  PRIMARY KEY (book_uuid, author_id),
+  FOREIGN KEY (book_uuid) REFERENCES Books(uuid) ON DELETE CASCADE,
+  FOREIGN KEY (author_id) REFERENCES Authors(id)
+);
+
+-- Create the BookSearchDocuments table (full-text search index, maintained by the search repository)
+CREATE TABLE IF NOT EXISTS BookSearchDocuments(
+  book_uuid uuid PRIMARY KEY,
+  language varchar(8) NOT NULL,
+  title text NOT NULL,
+  authors text NOT NULL DEFAULT '',
+  description text NOT NULL DEFAULT '',
+  document tsvector NOT NULL,
+  updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (book_uuid) REFERENCES Books(uuid) ON DELETE CASCADE
+);
+
+-- Create the BookFeedRecords table (the feed message that last touched each record of a publisher feed, e.g. ONIX)
+CREATE TABLE IF NOT EXISTS BookFeedRecords(
+  sender varchar(255) NOT NULL,
+  record_reference varchar(255) NOT NULL, -- The sender's key of the record
+  book_uuid uuid, -- NULL when a delete notification names a record that was never imported
+  message_id varchar(255) NOT NULL,
+  message_sent_at timestamp with time zone NOT NULL,
+  notification_type varchar(2) NOT NULL,
+  updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (sender, record_reference),
+  FOREIGN KEY (book_uuid) REFERENCES Books(uuid) ON DELETE CASCADE
 );
 
 -- Create the Loan table
@@ -38,12 +149,72 @@ CREATE TABLE IF NOT EXISTS Loans(
   name_of_borrower varchar(255) NOT NULL,
   loan_date timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
   return_date timestamp with time zone NOT NULL,
-  is_extended boolean NOT NULL DEFAULT FALSE,
+  is_extended boolean NOT NULL DEFAULT FALSE, -- Extended at least once
+  renewal_count integer NOT NULL DEFAULT 0 CHECK (renewal_count >= 0),
   is_returned boolean NOT NULL DEFAULT FALSE,
+  returned_at timestamp with time zone,
+  return_reason varchar(16) CHECK (return_reason IN ('returned', 'expired', 'withdrawn')), -- Who ended the loan
+  FOREIGN KEY (user_id) REFERENCES Users(id),
+  FOREIGN KEY (book_uuid) REFERENCES Books(uuid)
+);
+
+-- Create the Holds table (a first come, first served queue per title for copies that are not available)
+CREATE TABLE IF NOT EXISTS Holds(
+  uuid uuid PRIMARY KEY,
+  user_id bigint NOT NULL,
+  book_uuid uuid NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'waiting'
+    CHECK (status IN ('waiting', 'offered', 'fulfilled', 'cancelled', 'expired')),
+  placed_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  offered_at timestamp with time zone,
+  offer_expires_at timestamp with time zone, -- An offered copy is reserved for the patron until then
+  closed_at timestamp with time zone,
   FOREIGN KEY (user_id) REFERENCES Users(id),
   FOREIGN KEY (book_uuid) REFERENCES Books(uuid)
 );
 
+-- Create the LoanNotifications table (the emails sent about each loan, so that none is sent twice)
+CREATE TABLE IF NOT EXISTS LoanNotifications(
+  loan_uuid uuid NOT NULL,
+  kind varchar(16) NOT NULL CHECK (kind IN ('due_soon', 'extended', 'expired')),
+  return_date timestamp with time zone NOT NULL, -- The due date the email is about; an extended loan is notified again
+  status varchar(16) NOT NULL DEFAULT 'sending' CHECK (status IN ('sending', 'sent', 'failed')),
+  attempts integer NOT NULL DEFAULT 1,
+  last_error text NOT NULL DEFAULT '',
+  claimed_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  sent_at timestamp with time zone,
+  PRIMARY KEY (loan_uuid, kind, return_date),
+  FOREIGN KEY (loan_uuid) REFERENCES Loans(uuid)
+);
+
+-- Create the LoanEvents table (the append-only history of each loan; events are never updated or deleted)
+CREATE TABLE IF NOT EXISTS LoanEvents(
+  id bigserial PRIMARY KEY,
+  loan_uuid uuid NOT NULL,
+  kind varchar(16) NOT NULL CHECK (kind IN ('borrowed', 'extended', 'returned', 'expired', 'withdrawn')),
+  actor_user_id bigint, -- NULL when the loan was ended for the patron, i.e. expired or withdrawn
+  request_id text NOT NULL DEFAULT '',
+  previous_return_date timestamp with time zone, -- The due date before the event; NULL when the loan was borrowed
+  return_date timestamp with time zone NOT NULL, -- The due date after the event
+  occurred_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (loan_uuid) REFERENCES Loans(uuid),
+  FOREIGN KEY (actor_user_id) REFERENCES Users(id)
+);
+
+-- Create the PersonalAccessTokens table (the API tokens of scripts and integrations; only a hash of each is kept)
+CREATE TABLE IF NOT EXISTS PersonalAccessTokens(
+  uuid uuid PRIMARY KEY,
+  user_id bigint NOT NULL,
+  name varchar(100) NOT NULL,
+  token_hash char(64) UNIQUE NOT NULL, -- Hex SHA-256 of the token
+  scopes text[] NOT NULL,
+  created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at timestamp with time zone, -- NULL when the token never expires
+  last_used_at timestamp with time zone,
+  revoked_at timestamp with time zone,
+  FOREIGN KEY (user_id) REFERENCES Users(id)
+);
+
 -- Indexes for performance
 -- For searching loans by user
 CREATE INDEX IF NOT EXISTS idx_loans_user_id ON Loans(user_id);
@@ -54,3 +225,47 @@ CREATE INDEX IF NOT EXISTS idx_loans_book_uuid ON Loans(book_uuid);
 -- For efficiently finding overdue/active loans
 CREATE INDEX IF NOT EXISTS idx_loans_is_returned ON Loans(is_returned);
 
+-- For expiring active loans at their due date
+CREATE INDEX IF NOT EXISTS idx_loans_active_return_date ON Loans(return_date) WHERE is_returned = FALSE;
+
+-- For the history of a loan, in the order it happened
+CREATE INDEX IF NOT EXISTS idx_loan_events_loan_uuid ON LoanEvents(loan_uuid, id);
+
+-- A user has at most one active loan per book
+CREATE UNIQUE INDEX IF NOT EXISTS idx_loans_active_user_book ON Loans(user_id, book_uuid) WHERE is_returned = FALSE;
+
+-- For listing the books of an author
+CREATE INDEX IF NOT EXISTS idx_book_authors_author_id ON BookAuthors(author_id);
+
+-- For finding the feed records of a book
+CREATE INDEX IF NOT EXISTS idx_book_feed_records_book_uuid ON BookFeedRecords(book_uuid);
+
+-- For keyset pagination of the catalog listing
+CREATE INDEX IF NOT EXISTS idx_books_title_uuid ON Books(title, uuid);
+CREATE INDEX IF NOT EXISTS idx_books_created_at_uuid ON Books(created_at, uuid);
+CREATE INDEX IF NOT EXISTS idx_books_available_copies_uuid ON Books(available_copies, uuid);
+
+-- For the case-insensitive title lookup of GET /Book and the loan endpoints
+CREATE INDEX IF NOT EXISTS idx_books_lower_title ON Books(lower(title));
+
+-- For substring, prefix and similarity matching on book titles
+CREATE INDEX IF NOT EXISTS idx_books_title_trgm ON Books USING gin (title gin_trgm_ops);
+
+-- For full-text search
+CREATE INDEX IF NOT EXISTS idx_book_search_documents_document ON BookSearchDocuments USING gin (document);
+
+-- A user has at most one open hold per book
+CREATE UNIQUE INDEX IF NOT EXISTS idx_holds_open_user_book ON Holds(user_id, book_uuid) WHERE status IN ('waiting', 'offered');
+
+-- For finding the next hold in the queue of a book
+CREATE INDEX IF NOT EXISTS idx_holds_queue ON Holds(book_uuid, placed_at, uuid) WHERE status = 'waiting';
+
+-- For expiring lapsed offers
+CREATE INDEX IF NOT EXISTS idx_holds_offer_expires_at ON Holds(offer_expires_at) WHERE status = 'offered';
+
+-- For retrying the emails that failed, or whose sending was interrupted
+CREATE INDEX IF NOT EXISTS idx_loan_notifications_retry ON LoanNotifications(claimed_at) WHERE status IN ('failed', 'sending');
+DROP INDEX IF EXISTS idx_loan_notifications_failed;
+
+-- The names of the tokens of a user that are not revoked are unique, which also serves listing them
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_user_name ON PersonalAccessTokens(user_id, name) WHERE revoked_at IS NULL;
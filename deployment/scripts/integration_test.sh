@@ -47,6 +47,10 @@ for i in "${!TESTDATA_JSON_FILES[@]}"; do
         elif [[ "$method" == "POST" ]]; then
 
             curl -s -X POST -H "Content-Type: application/json" -d "$json_body_request" "$full_url" > /dev/null  # POST Request -- Discard Output
+
+        elif [[ "$method" == "DELETE" ]]; then
+
+            curl -s -X DELETE -H "Content-Type: application/json" -d "$json_body_request" "$full_url" > /dev/null  # DELETE Request -- Discard Output
         fi
     done
 done
@@ -8,12 +8,19 @@ import (
 type RestErr struct {
 	Message string `json:"message"`
 	Status  int    `json:"status"`
+	Code    string `json:"code,omitempty"` // Machine-readable reason, for clients to explain a refusal
 }
 
 func (e *RestErr) Error() string {
 	return fmt.Sprintf("status %d: %s", e.Status, e.Message)
 }
 
+// WithCode sets the reason code of the error and returns it.
+func (e *RestErr) WithCode(code string) *RestErr {
+	e.Code = code
+	return e
+}
+
 // Factory functions
 func NewInternalServerError(message string) *RestErr {
 	return &RestErr{
@@ -29,9 +36,30 @@ func NewBadRequestError(message string) *RestErr {
 	}
 }
 
+func NewUnauthorizedError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Status:  http.StatusUnauthorized,
+	}
+}
+
 func NewNotFoundError(message string) *RestErr {
 	return &RestErr{
 		Message: message,
 		Status:  http.StatusNotFound,
 	}
 }
+
+func NewForbiddenError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Status:  http.StatusForbidden,
+	}
+}
+
+func NewConflictError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Status:  http.StatusConflict,
+	}
+}
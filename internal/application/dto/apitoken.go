@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APITokenCreateRequest is the body of the endpoint that creates a personal access token. A token without
+// expires_in_days never expires.
+type APITokenCreateRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,max=5,dive,oneof=catalog:write loans:read loans:write holds:read holds:write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// APITokenDetail is a personal access token of the user, as listed for them to revoke. The token itself is
+// only shown when it is created.
+type APITokenDetail struct {
+	UUID       uuid.UUID  `json:"uuid"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type APITokenCreateResponse struct {
+	APITokenDetail
+	Token string `json:"token"`
+}
+
+type APITokenListResponse struct {
+	Tokens []APITokenDetail `json:"tokens"`
+}
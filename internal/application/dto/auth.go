@@ -1,5 +1,7 @@
 package dto
 
+// GoogleOAuth2UserRes is the account of a user at a login provider. The claims of an OpenID Connect ID
+// token are mapped to it as well.
 type GoogleOAuth2UserRes struct {
 	ID            string `json:"id"`
 	Name          string `json:"name"`
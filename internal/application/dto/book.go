@@ -4,10 +4,99 @@ import "github.com/google/uuid"
 
 type BookDetail struct {
 	UUID            uuid.UUID `json:"uuid"`
+	ISBN13          string    `json:"isbn_13"`
+	ISBN10          string    `json:"isbn_10,omitempty"`
 	Title           string    `json:"title"`
+	Authors         []string  `json:"authors"`
+	Edition         string    `json:"edition,omitempty"`
+	Publisher       string    `json:"publisher,omitempty"`
+	Language        string    `json:"language"`
+	PublicationYear int       `json:"publication_year,omitempty"`
+	Description     string    `json:"description,omitempty"`
+	TotalCopies     int       `json:"total_copies"`
 	AvailableCopies int       `json:"available_copies"`
 }
 
+// BookRequest identifies a book by UUID or ISBN, or by title for convenience when no edition shares it.
 type BookRequest struct {
-	Title string `json:"title" validate:"required,max=200"`
+	UUID  string `json:"uuid" query:"uuid" validate:"omitempty,uuid"`
+	ISBN  string `json:"isbn" query:"isbn" validate:"omitempty,isbn"` // ISBN-13 or ISBN-10, hyphens are ignored
+	Title string `json:"title" query:"title" validate:"required_without_all=UUID ISBN,max=200"`
+}
+
+// BookListRequest holds the query parameters of the paginated catalog listing.
+type BookListRequest struct {
+	Cursor    string `query:"cursor" validate:"omitempty,base64rawurl"`
+	Limit     int    `query:"limit" validate:"min=1,max=100"`
+	SortBy    string `query:"sort_by" validate:"oneof=title created_at available_copies"`
+	Order     string `query:"order" validate:"oneof=asc desc"`
+	Available bool   `query:"available"` // Only list books with at least one available copy
+}
+
+type BookListResponse struct {
+	Books      []BookDetail `json:"books"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	PrevCursor string       `json:"prev_cursor,omitempty"`
+}
+
+// BookSearchRequest holds the query parameters of the forgiving title search.
+type BookSearchRequest struct {
+	Title string `query:"title" validate:"required,max=200"`
+	Mode  string `query:"mode" validate:"oneof=fuzzy substring prefix"`
+	Limit int    `query:"limit" validate:"min=1,max=50"`
+}
+
+type BookSearchResult struct {
+	BookDetail
+	Score float64 `json:"score"` // Relevance between 0 and 1, where 1 is an exact title match
+}
+
+// BookCreateRequest is the body of the librarian endpoint that adds a title to the catalog.
+type BookCreateRequest struct {
+	ISBN13          string   `json:"isbn_13" validate:"required,isbn13"`
+	ISBN10          string   `json:"isbn_10" validate:"omitempty,isbn10"`
+	Title           string   `json:"title" validate:"required,max=255"`
+	Authors         []string `json:"authors" validate:"required,min=1,max=20,dive,required,max=255"`
+	Edition         string   `json:"edition" validate:"max=100"`
+	Publisher       string   `json:"publisher" validate:"max=255"`
+	Language        string   `json:"language" validate:"omitempty,len=2,alpha"`
+	PublicationYear int      `json:"publication_year" validate:"omitempty,min=1,max=9999"`
+	Description     string   `json:"description" validate:"max=10000"`
+	TotalCopies     int      `json:"total_copies" validate:"min=0,max=100000"`
+}
+
+// BookUpdateRequest is the body of the librarian endpoint that edits the metadata of a title.
+// Only the fields that are present are updated.
+type BookUpdateRequest struct {
+	ISBN13          *string   `json:"isbn_13" validate:"omitempty,isbn13"`
+	ISBN10          *string   `json:"isbn_10" validate:"omitempty,isbn10"`
+	Title           *string   `json:"title" validate:"omitempty,min=1,max=255"`
+	Authors         *[]string `json:"authors" validate:"omitempty,min=1,max=20,dive,required,max=255"`
+	Edition         *string   `json:"edition" validate:"omitempty,max=100"`
+	Publisher       *string   `json:"publisher" validate:"omitempty,max=255"`
+	Language        *string   `json:"language" validate:"omitempty,len=2,alpha"`
+	PublicationYear *int      `json:"publication_year" validate:"omitempty,min=1,max=9999"`
+	Description     *string   `json:"description" validate:"omitempty,max=10000"`
+}
+
+// BookCopiesRequest is the body of the librarian endpoint that adds (positive) or removes (negative) copies of a title.
+// Only copies that are on the shelf can be removed.
+type BookCopiesRequest struct {
+	Delta int `json:"delta" validate:"required,min=-100000,max=100000"`
+}
+
+// CopiesDrift is a book whose available copies disagree with its total copies less its active loans
+// and the copies reserved by hold offers.
+type CopiesDrift struct {
+	UUID              uuid.UUID `json:"uuid"`
+	ISBN13            string    `json:"isbn_13"`
+	Title             string    `json:"title"`
+	TotalCopies       int       `json:"total_copies"`
+	AvailableCopies   int       `json:"available_copies"`
+	ActiveLoans       int       `json:"active_loans"`
+	OpenOffers        int       `json:"open_offers"`
+	ExpectedAvailable int       `json:"expected_available"`
+	OverLent          bool      `json:"over_lent"` // More active loans than copies, which only a librarian can resolve
+	Fixed             bool      `json:"fixed"`
+	OfferedHolds      int       `json:"offered_holds,omitempty"` // Waiting holds offered the copies that the fix restored
 }
@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Hold statuses. A hold waits in the queue of its title until a copy is offered to it; the offer is then
+// fulfilled by borrowing the copy, or expires and passes the copy on to the next hold in the queue.
+const (
+	HoldStatusWaiting   = "waiting"
+	HoldStatusOffered   = "offered"
+	HoldStatusFulfilled = "fulfilled"
+	HoldStatusCancelled = "cancelled"
+	HoldStatusExpired   = "expired"
+)
+
+type HoldDetail struct {
+	UUID           uuid.UUID  `json:"uuid"`
+	BookUUID       uuid.UUID  `json:"book_uuid"`
+	BookTitle      string     `json:"book_title"`
+	Status         string     `json:"status"`
+	Position       int        `json:"position,omitempty"` // 1 is next in line; omitted once a copy is offered
+	PlacedAt       time.Time  `json:"placed_at"`
+	OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty"` // Borrow the copy before then or it passes on
+}
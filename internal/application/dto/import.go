@@ -0,0 +1,87 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ImportActionCreate    = "create"
+	ImportActionUpdate    = "update"
+	ImportActionUnchanged = "unchanged"
+	ImportActionWithdraw  = "withdraw"
+	ImportActionSkipped   = "skipped"
+	ImportActionError     = "error"
+)
+
+// ImportRecord is a catalog record read by an import parser.
+// Empty fields are left untouched when the record updates an existing title.
+type ImportRecord struct {
+	Line            int      `json:"line"` // Line of a CSV file or position of a MARC record, starting at 1
+	ISBN13          string   `json:"isbn_13" validate:"required,isbn13"`
+	ISBN10          string   `json:"isbn_10" validate:"omitempty,isbn10"`
+	Title           string   `json:"title" validate:"required,max=255"`
+	Authors         []string `json:"authors" validate:"max=20,dive,required,max=255"`
+	Edition         string   `json:"edition" validate:"max=100"`
+	Publisher       string   `json:"publisher" validate:"max=255"`
+	Language        string   `json:"language" validate:"omitempty,len=2,alpha"`
+	PublicationYear int      `json:"publication_year" validate:"omitempty,min=1,max=9999"`
+	Description     string   `json:"description" validate:"max=10000"`
+	TotalCopies     *int     `json:"total_copies" validate:"omitempty,min=0,max=100000"` // nil keeps the copies of an existing title
+
+	Delete bool          `json:"delete"` // The source withdrew the title, e.g. an ONIX delete notification
+	Source *ImportSource `json:"source,omitempty"`
+}
+
+// ImportSource identifies the feed message that delivered a record, so that records from older messages are skipped.
+type ImportSource struct {
+	Sender           string    `json:"sender"`
+	MessageID        string    `json:"message_id"`
+	SentAt           time.Time `json:"sent_at"`
+	RecordReference  string    `json:"record_reference"` // The sender's key of the record, stable across messages
+	NotificationType string    `json:"notification_type"`
+}
+
+// ImportRecordError reports a record that could not be read or validated.
+type ImportRecordError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type ImportRecordResult struct {
+	Line            int           `json:"line"`
+	BookUUID        *uuid.UUID    `json:"uuid,omitempty"` // Not set on a dry run
+	ISBN13          string        `json:"isbn_13,omitempty"`
+	Title           string        `json:"title,omitempty"`
+	RecordReference string        `json:"record_reference,omitempty"`
+	Action          string        `json:"action"`
+	Changes         []FieldChange `json:"changes,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// ImportReport is the per-record outcome of an import. On a dry run it is the diff that the import would apply.
+type ImportReport struct {
+	Format    string               `json:"format"`
+	DryRun    bool                 `json:"dry_run"`
+	Created   int                  `json:"created"`
+	Updated   int                  `json:"updated"`
+	Unchanged int                  `json:"unchanged"`
+	Withdrawn int                  `json:"withdrawn"`
+	Skipped   int                  `json:"skipped"` // Records from a feed message older than the one that last touched them
+	Failed    int                  `json:"failed"`
+	Records   []ImportRecordResult `json:"records"`
+}
+
+// ImportRequest holds the query parameters of the catalog import upload.
+type ImportRequest struct {
+	Format        string `query:"format" validate:"oneof=csv marc21 marcxml onix"`
+	DryRun        bool   `query:"dry_run"`
+	ColumnMapping string `query:"map" validate:"max=1000"` // CSV only, e.g. `isbn_13=ISBN,title=Book Title`
+}
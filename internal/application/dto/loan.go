@@ -1,10 +1,65 @@
 package dto
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Loan statuses that the loans of a user can be filtered by.
+const (
+	LoanStatusActive   = "active"
+	LoanStatusReturned = "returned" // Returned, expired or withdrawn
+	LoanStatusAll      = "all"
+)
+
+// Kinds of the events in the history of a loan.
+const (
+	LoanEventBorrowed  = "borrowed"
+	LoanEventExtended  = "extended"
+	LoanEventReturned  = "returned"
+	LoanEventExpired   = "expired"
+	LoanEventWithdrawn = "withdrawn"
+)
 
 type LoanDetail struct {
-	BookTitle      string    `json:"book_title"`
-	NameOfBorrower string    `json:"name_of_borrower"`
-	LoanDate       time.Time `json:"loan_date"`
-	ReturnDate     time.Time `json:"return_date"`
+	UUID             uuid.UUID  `json:"uuid"`
+	BookUUID         uuid.UUID  `json:"book_uuid"`
+	BookTitle        string     `json:"book_title"`
+	NameOfBorrower   string     `json:"name_of_borrower"`
+	LoanDate         time.Time  `json:"loan_date"`
+	ReturnDate       time.Time  `json:"return_date"` // The due date, or the date it was due when the loan ended
+	IsExtended       bool       `json:"is_extended"`
+	RenewalCount     int        `json:"renewal_count"`
+	CanExtend        bool       `json:"can_extend"`                  // Under the loan policy of the borrower
+	ExtensionRefusal string     `json:"extension_refusal,omitempty"` // Why an active loan cannot be extended
+	ReturnedAt       *time.Time `json:"returned_at,omitempty"`
+	ReturnReason     string     `json:"return_reason,omitempty"` // returned, expired or withdrawn
+}
+
+// LoanListRequest holds the query parameters of the loans of the current user.
+type LoanListRequest struct {
+	Status string `query:"status" validate:"oneof=active returned all"`
+	Limit  int    `query:"limit" validate:"min=1,max=100"`
+	Offset int    `query:"offset" validate:"min=0"`
+}
+
+type LoanListResponse struct {
+	Total int          `json:"total"`
+	Loans []LoanDetail `json:"loans"`
+}
+
+// LoanEvent is an entry in the history of a loan.
+type LoanEvent struct {
+	Kind               string     `json:"kind"`
+	OccurredAt         time.Time  `json:"occurred_at"`
+	ActorUserID        *int64     `json:"actor_user_id,omitempty"` // Absent when the loan was expired or withdrawn
+	RequestID          string     `json:"request_id"`
+	PreviousReturnDate *time.Time `json:"previous_return_date,omitempty"` // The due date before the event
+	ReturnDate         time.Time  `json:"return_date"`                    // The due date after the event
+}
+
+type LoanHistoryResponse struct {
+	LoanUUID uuid.UUID   `json:"loan_uuid"`
+	Events   []LoanEvent `json:"events"`
 }
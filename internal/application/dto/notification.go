@@ -0,0 +1,24 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of loan notifications.
+const (
+	LoanNotificationDueSoon  = "due_soon"
+	LoanNotificationExtended = "extended"
+	LoanNotificationExpired  = "expired"
+)
+
+// LoanNotification is an email that is due to the borrower of a loan.
+type LoanNotification struct {
+	LoanUUID       uuid.UUID
+	Kind           string
+	ReturnDate     time.Time // The due date the notification is about
+	Email          string
+	NameOfBorrower string
+	BookTitle      string
+}
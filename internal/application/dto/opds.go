@@ -0,0 +1,165 @@
+package dto
+
+import "encoding/xml"
+
+// Media types of OPDS 1.2 (Atom) and OPDS 2.0 (JSON) documents.
+const (
+	OPDSNavigationFeedType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	OPDSAcquisitionFeedType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	OPDSEntryType           = "application/atom+xml;type=entry;profile=opds-catalog"
+	OpenSearchType          = "application/opensearchdescription+xml"
+	OPDS2FeedType           = "application/opds+json"
+	OPDS2PublicationType    = "application/opds-publication+json"
+
+	OPDSRelBorrow = "http://opds-spec.org/acquisition/borrow"
+)
+
+// OPDSSearchRequest holds the query parameters of the OPDS search feeds, see OpenSearchDescription.
+type OPDSSearchRequest struct {
+	Query string `query:"q" validate:"required,max=200"`
+	Page  int    `query:"page" validate:"min=1,max=1000"`
+}
+
+/********************
+ *    OPDS 1.2     *
+ ********************/
+
+// AtomFeed is an OPDS 1.2 navigation or acquisition feed. Namespaced elements are written with their prefix.
+type AtomFeed struct {
+	XMLName         xml.Name    `xml:"feed"`
+	Xmlns           string      `xml:"xmlns,attr"`
+	XmlnsDC         string      `xml:"xmlns:dc,attr"`
+	XmlnsOPDS       string      `xml:"xmlns:opds,attr"`
+	XmlnsOpenSearch string      `xml:"xmlns:opensearch,attr"`
+	ID              string      `xml:"id"`
+	Title           string      `xml:"title"`
+	Updated         string      `xml:"updated"`
+	TotalResults    *int        `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage    int         `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex      int         `xml:"opensearch:startIndex,omitempty"`
+	Links           []AtomLink  `xml:"link"`
+	Entries         []AtomEntry `xml:"entry"`
+}
+
+// AtomEntry is a navigation entry or a book. The namespace attributes are only set on a standalone entry document.
+type AtomEntry struct {
+	XMLName    xml.Name     `xml:"entry"`
+	Xmlns      string       `xml:"xmlns,attr,omitempty"`
+	XmlnsDC    string       `xml:"xmlns:dc,attr,omitempty"`
+	XmlnsOPDS  string       `xml:"xmlns:opds,attr,omitempty"`
+	ID         string       `xml:"id"`
+	Title      string       `xml:"title"`
+	Updated    string       `xml:"updated"`
+	Authors    []AtomAuthor `xml:"author"`
+	Identifier string       `xml:"dc:identifier,omitempty"`
+	Language   string       `xml:"dc:language,omitempty"`
+	Publisher  string       `xml:"dc:publisher,omitempty"`
+	Issued     string       `xml:"dc:issued,omitempty"`
+	Summary    string       `xml:"summary,omitempty"`
+	Content    *AtomContent `xml:"content"`
+	Links      []AtomLink   `xml:"link"`
+}
+
+type AtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type AtomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type AtomLink struct {
+	Rel          string            `xml:"rel,attr,omitempty"`
+	Href         string            `xml:"href,attr"`
+	Type         string            `xml:"type,attr,omitempty"`
+	Title        string            `xml:"title,attr,omitempty"`
+	Availability *OPDSAvailability `xml:"opds:availability"`
+	Copies       *OPDSCopies       `xml:"opds:copies"`
+}
+
+// OPDSAvailability is `available` or `unavailable` on a borrow link, and `ready` on the entry of a loan.
+type OPDSAvailability struct {
+	Status string `xml:"status,attr"`
+	Since  string `xml:"since,attr,omitempty"`
+	Until  string `xml:"until,attr,omitempty"`
+}
+
+type OPDSCopies struct {
+	Total     int `xml:"total,attr"`
+	Available int `xml:"available,attr"`
+}
+
+type OpenSearchDescription struct {
+	XMLName        xml.Name      `xml:"OpenSearchDescription"`
+	Xmlns          string        `xml:"xmlns,attr"`
+	ShortName      string        `xml:"ShortName"`
+	Description    string        `xml:"Description"`
+	InputEncoding  string        `xml:"InputEncoding"`
+	OutputEncoding string        `xml:"OutputEncoding"`
+	URL            OpenSearchURL `xml:"Url"`
+}
+
+type OpenSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+/********************
+ *    OPDS 2.0     *
+ ********************/
+
+type OPDS2Feed struct {
+	Metadata     OPDS2FeedMetadata  `json:"metadata"`
+	Links        []OPDS2Link        `json:"links"`
+	Navigation   []OPDS2Link        `json:"navigation,omitempty"`
+	Publications []OPDS2Publication `json:"publications,omitempty"`
+}
+
+type OPDS2FeedMetadata struct {
+	Title         string `json:"title"`
+	NumberOfItems *int   `json:"numberOfItems,omitempty"`
+	ItemsPerPage  int    `json:"itemsPerPage,omitempty"`
+	CurrentPage   int    `json:"currentPage,omitempty"`
+}
+
+type OPDS2Link struct {
+	Rel        string               `json:"rel,omitempty"`
+	Href       string               `json:"href"`
+	Type       string               `json:"type,omitempty"`
+	Title      string               `json:"title,omitempty"`
+	Templated  bool                 `json:"templated,omitempty"`
+	Properties *OPDS2LinkProperties `json:"properties,omitempty"`
+}
+
+type OPDS2LinkProperties struct {
+	Availability *OPDS2Availability `json:"availability,omitempty"`
+	Copies       *OPDS2Copies       `json:"copies,omitempty"`
+}
+
+type OPDS2Availability struct {
+	State string `json:"state"`
+	Since string `json:"since,omitempty"`
+	Until string `json:"until,omitempty"`
+}
+
+type OPDS2Copies struct {
+	Total     int `json:"total"`
+	Available int `json:"available"`
+}
+
+type OPDS2Publication struct {
+	Metadata OPDS2PublicationMetadata `json:"metadata"`
+	Links    []OPDS2Link              `json:"links"`
+}
+
+type OPDS2PublicationMetadata struct {
+	Type        string   `json:"@type"`
+	Identifier  string   `json:"identifier"`
+	Title       string   `json:"title"`
+	Author      []string `json:"author,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Publisher   string   `json:"publisher,omitempty"`
+	Published   string   `json:"published,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
@@ -0,0 +1,28 @@
+package dto
+
+// FullTextSearchRequest holds the query parameters of the full-text search.
+// The query accepts web search syntax: "quoted phrases", `or` and `-excluded` terms.
+type FullTextSearchRequest struct {
+	Query    string `query:"q" validate:"required,max=200"`
+	Language string `query:"lang" validate:"omitempty,len=2,alpha"` // ISO 639-1 code used for stemming and filtering
+	Limit    int    `query:"limit" validate:"min=1,max=50"`
+	Offset   int    `query:"offset" validate:"min=0,max=1000"`
+}
+
+// FullTextHighlights holds the matched fields with the query terms wrapped in <mark> tags.
+type FullTextHighlights struct {
+	Title       string `json:"title,omitempty"`
+	Authors     string `json:"authors,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type FullTextSearchHit struct {
+	Book       BookDetail         `json:"book"`
+	Rank       float64            `json:"rank"`
+	Highlights FullTextHighlights `json:"highlights"`
+}
+
+type FullTextSearchResponse struct {
+	Total int                 `json:"total"`
+	Hits  []FullTextSearchHit `json:"hits"`
+}
@@ -0,0 +1,16 @@
+package dto
+
+import "time"
+
+// SessionDetail is a session of the user, as listed for them to revoke.
+type SessionDetail struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Current   bool      `json:"current"` // The session of this request
+}
+
+type SessionListResponse struct {
+	Sessions []SessionDetail `json:"sessions"`
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// APITokenService lets users create, list and revoke the personal access tokens of their scripts and integrations.
+type APITokenService interface {
+	CreateAPITokenHandler(c *fiber.Ctx) error
+	CreateAPIToken(requestID string, userDetail dto.UserDetail, tokenRequest dto.APITokenCreateRequest,
+	) (*dto.APITokenCreateResponse, *apperrors.RestErr)
+
+	GetAPITokensHandler(c *fiber.Ctx) error
+	GetAPITokens(requestID string, userID int64) (*dto.APITokenListResponse, *apperrors.RestErr)
+
+	RevokeAPITokenHandler(c *fiber.Ctx) error
+	RevokeAPIToken(requestID string, userID int64, tokenUUID uuid.UUID) *apperrors.RestErr
+}
@@ -11,3 +11,9 @@ type GoogleOAuth2Service interface {
 	Callback(c *fiber.Ctx) error
 	SaveUserToRDBMS(user *dto.GoogleOAuth2UserRes) (int64, *apperrors.RestErr)
 }
+
+// OIDCService logs users in with the OpenID Connect provider named in the path.
+type OIDCService interface {
+	Login(c *fiber.Ctx) error
+	Callback(c *fiber.Ctx) error
+}
@@ -10,4 +10,10 @@ import (
 type BookService interface {
 	GetBookByTitleHandler(c *fiber.Ctx) error
 	GetBookByTitle(requestID string, bookRequest dto.BookRequest) (*dto.BookDetail, *apperrors.RestErr)
+
+	ListBooksHandler(c *fiber.Ctx) error
+	ListBooks(requestID string, params dto.BookListRequest) (*dto.BookListResponse, *apperrors.RestErr)
+
+	SearchBooksHandler(c *fiber.Ctx) error
+	SearchBooks(requestID string, params dto.BookSearchRequest) ([]dto.BookSearchResult, *apperrors.RestErr)
 }
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// CatalogService defines the interface for librarians to administer the catalog (e.g., adding, editing or withdrawing books).
+type CatalogService interface {
+	CreateBookHandler(c *fiber.Ctx) error
+	CreateBook(requestID string, book dto.BookCreateRequest) (*dto.BookDetail, *apperrors.RestErr)
+
+	UpdateBookHandler(c *fiber.Ctx) error
+	UpdateBook(requestID string, bookUUID uuid.UUID, book dto.BookUpdateRequest) (*dto.BookDetail, *apperrors.RestErr)
+
+	AdjustCopiesHandler(c *fiber.Ctx) error
+	AdjustCopies(requestID string, bookUUID uuid.UUID, delta int) (*dto.BookDetail, *apperrors.RestErr)
+
+	WithdrawBookHandler(c *fiber.Ctx) error
+	WithdrawBook(requestID string, bookUUID uuid.UUID, force bool) *apperrors.RestErr
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/gofiber/fiber/v2"
+)
+
+// HoldService defines the interface for queueing patrons for titles that have no copies available.
+type HoldService interface {
+	PlaceHoldHandler(c *fiber.Ctx) error
+	PlaceHold(requestID string, userID int64, bookRequest dto.BookRequest) (*dto.HoldDetail, *apperrors.RestErr)
+
+	CancelHoldHandler(c *fiber.Ctx) error
+	CancelHold(requestID string, userID int64, bookRequest dto.BookRequest) *apperrors.RestErr
+
+	GetHoldsHandler(c *fiber.Ctx) error
+	GetHolds(requestID string, userID int64) ([]dto.HoldDetail, *apperrors.RestErr)
+}
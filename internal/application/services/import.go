@@ -0,0 +1,15 @@
+package services
+
+import (
+	"io"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CatalogImportService defines the interface for loading catalog records in bulk (e.g., from CSV or MARC files).
+type CatalogImportService interface {
+	ImportBooksHandler(c *fiber.Ctx) error
+	ImportBooks(requestID string, format string, columnMapping map[string]string, source io.Reader, dryRun bool) (*dto.ImportReport, *apperrors.RestErr)
+}
@@ -4,16 +4,24 @@ import (
 	"github.com/DarrelA/e-lib/internal/apperrors"
 	"github.com/DarrelA/e-lib/internal/application/dto"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // LoanService defines the interface for managing loan-related operations (e.g., creating, retrieving, or processing loans).
 type LoanService interface {
 	BorrowBookHandler(c *fiber.Ctx) error
 	BorrowBook(requestID string, userDetail dto.UserDetail, bookRequest dto.BookRequest) (*dto.LoanDetail, *apperrors.RestErr)
+	BorrowBookByUUID(requestID string, userDetail dto.UserDetail, bookUUID uuid.UUID) (*dto.LoanDetail, *apperrors.RestErr)
 
 	ExtendBookLoanHandler(c *fiber.Ctx) error
 	ExtendBookLoan(requestID string, userID int64, bookRequest dto.BookRequest) (*dto.LoanDetail, *apperrors.RestErr)
 
 	ReturnBookHandler(c *fiber.Ctx) error
 	ReturnBook(requestID string, userID int64, bookRequest dto.BookRequest) *apperrors.RestErr
+
+	GetLoansHandler(c *fiber.Ctx) error
+	GetLoans(requestID string, userID int64, params dto.LoanListRequest) (*dto.LoanListResponse, *apperrors.RestErr)
+
+	GetLoanHistoryHandler(c *fiber.Ctx) error
+	GetLoanHistory(requestID string, userID int64, loanUUID uuid.UUID) (*dto.LoanHistoryResponse, *apperrors.RestErr)
 }
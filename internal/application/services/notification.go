@@ -0,0 +1,9 @@
+package services
+
+import "github.com/DarrelA/e-lib/internal/apperrors"
+
+// NotificationService defines the interface for emailing patrons about their loans (e.g., due soon, extended or expired).
+type NotificationService interface {
+	// SendLoanNotifications sends up to batchSize due notifications and returns how many it claimed.
+	SendLoanNotifications(requestID string, batchSize int) (int, *apperrors.RestErr)
+}
@@ -0,0 +1,22 @@
+package services
+
+import "github.com/gofiber/fiber/v2"
+
+// OPDSService defines the interface for the OPDS catalog feeds that e-reader apps browse (e.g., Thorium or KOReader).
+// Each feed is served as OPDS 1.2 (Atom) and as OPDS 2.0 (JSON).
+type OPDSService interface {
+	NavigationFeedHandler(c *fiber.Ctx) error
+	NavigationFeedV2Handler(c *fiber.Ctx) error
+
+	BooksFeedHandler(c *fiber.Ctx) error
+	BooksFeedV2Handler(c *fiber.Ctx) error
+
+	SearchFeedHandler(c *fiber.Ctx) error
+	SearchFeedV2Handler(c *fiber.Ctx) error
+	OpenSearchDescriptionHandler(c *fiber.Ctx) error
+
+	BorrowEntryHandler(c *fiber.Ctx) error
+	BorrowEntryV2Handler(c *fiber.Ctx) error
+	BorrowHandler(c *fiber.Ctx) error
+	BorrowV2Handler(c *fiber.Ctx) error
+}
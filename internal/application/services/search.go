@@ -0,0 +1,13 @@
+package services
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchService defines the interface for full-text search across the catalog.
+type SearchService interface {
+	SearchHandler(c *fiber.Ctx) error
+	Search(requestID string, params dto.FullTextSearchRequest) (*dto.FullTextSearchResponse, *apperrors.RestErr)
+}
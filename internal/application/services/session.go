@@ -0,0 +1,18 @@
+package services
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SessionService lets users log out, and list and revoke their sessions on other devices.
+type SessionService interface {
+	LogoutHandler(c *fiber.Ctx) error
+
+	GetSessionsHandler(c *fiber.Ctx) error
+	GetSessions(userID int64, currentID string) (*dto.SessionListResponse, *apperrors.RestErr)
+
+	RevokeSessionHandler(c *fiber.Ctx) error
+	RevokeSessionsHandler(c *fiber.Ctx) error
+}
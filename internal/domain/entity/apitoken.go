@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+)
+
+// Scopes of personal access tokens. A token reaches only the routes of its scopes, while a session reaches
+// every route of its user. The catalog is read without logging in, so it needs no scope to read.
+const (
+	ScopeCatalogWrite = "catalog:write" // Also requires the librarian role
+	ScopeLoansRead    = "loans:read"
+	ScopeLoansWrite   = "loans:write"
+	ScopeHoldsRead    = "holds:read"
+	ScopeHoldsWrite   = "holds:write"
+)
+
+// APITokenPrefix starts every personal access token, so that a leaked one is easy to recognize.
+const APITokenPrefix = "elib_pat_"
+
+// APIToken is a personal access token that authenticated a request.
+type APIToken struct {
+	UUID   string
+	UserID int64
+	Scopes []string
+}
+
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// HashAPIToken returns what is stored of a token. Tokens are random, so an unsalted SHA-256 is enough to
+// keep a leaked table from being used to authenticate.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -11,3 +11,13 @@ type GoogleOAuth2User struct {
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
+
+// OAuth2LoginState is kept from the redirect to the provider until its callback, under the state of the login.
+type OAuth2LoginState struct {
+	Provider     string `json:"provider"`              // The callback of another provider cannot complete the login
+	LoginID      string `json:"login_id"`              // Also in the pre-auth cookie, which binds the login to its browser
+	CodeVerifier string `json:"code_verifier"`         // PKCE verifier of the authorization code
+	Nonce        string `json:"nonce,omitempty"`       // Expected in the ID token of an OpenID Connect login
+	RedirectTo   string `json:"redirect_to,omitempty"` // Where the user goes after the login
+	RememberMe   bool   `json:"remember_me,omitempty"` // Starts a session with the longer remember me timeouts
+}
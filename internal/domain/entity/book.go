@@ -7,9 +7,24 @@ import (
 )
 
 type Book struct {
-	UUID            *uuid.UUID `json:"uuid"`  // Primary Key
-	Title           string     `json:"title"` // Unique
-	AvailableCopies int        `json:"available_copies"`
+	UUID            *uuid.UUID `json:"uuid"`    // Primary Key
+	ISBN13          string     `json:"isbn_13"` // Unique
+	ISBN10          string     `json:"isbn_10"` // Unique, optional
+	Title           string     `json:"title"`
+	Authors         []string   `json:"authors"` // Names in title page order, see BookAuthors
+	Edition         string     `json:"edition"`
+	Publisher       string     `json:"publisher"`
+	Language        string     `json:"language"` // ISO 639-1 code
+	PublicationYear int        `json:"publication_year"`
+	Description     string     `json:"description"`
+	TotalCopies     int        `json:"total_copies"`     // Copies (or licences) the library owns
+	AvailableCopies int        `json:"available_copies"` // TotalCopies less the active loans
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
 }
+
+type Author struct {
+	ID        int64     `json:"id"`   // Primary Key
+	Name      string    `json:"name"` // Unique
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 type (
 	EnvConfig struct {
 		AppEnv              string
@@ -9,6 +11,9 @@ type (
 		PostgresDBConfig    *PostgresDBConfig
 		RedisDBConfig       *RedisDBConfig
 		OAuth2Config        *OAuth2Config
+		OIDCProviders       []*OIDCProviderConfig
+		SessionConfig       *SessionConfig
+		SMTPConfig          *SMTPConfig
 	}
 
 	PostgresDBConfig struct {
@@ -30,5 +35,35 @@ type (
 		GoogleClientID     string
 		GoogleClientSecret string
 		Scopes             []string
+		RedirectAllowList  []string // Origins that a login may redirect to afterwards, besides paths of this server
+	}
+
+	// OIDCProviderConfig is an OpenID Connect provider, whose endpoints and keys are read from the
+	// discovery document of its issuer.
+	OIDCProviderConfig struct {
+		Name         string // Used in the login URLs and recorded as the provider of its users
+		IssuerURL    string
+		ClientID     string
+		ClientSecret string // Optional for a public client, which relies on PKCE alone
+		RedirectURL  string
+		Scopes       []string
+		TrustEmail   bool // Links the first login of a subject to the user who has its verified email
+	}
+
+	// SessionConfig bounds a session by its idle timeout, which activity restarts, and by its absolute
+	// lifetime from the login, which nothing extends.
+	SessionConfig struct {
+		IdleTimeout                time.Duration
+		AbsoluteLifetime           time.Duration
+		RememberMeIdleTimeout      time.Duration
+		RememberMeAbsoluteLifetime time.Duration
+	}
+
+	SMTPConfig struct {
+		Host     string
+		Port     string
+		Username string // Optional; the server is used without authentication when empty
+		Password string
+		From     string
 	}
 )
@@ -0,0 +1,9 @@
+package entity
+
+// IdempotencyRecord remembers a request that carried an Idempotency-Key, and its response once it completes.
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`  // Hash of the method, path and body of the request
+	StatusCode  int    `json:"status_code"`  // 0 while the request is in progress
+	ContentType string `json:"content_type"` // Of the response
+	Body        []byte `json:"body"`         // Of the response
+}
@@ -7,11 +7,22 @@ import (
 )
 
 type Loan struct {
-	UUID           uuid.UUID `json:"uuid"`
-	UserID         int64     `json:"user_id"`   // Foreign key to User
-	BookUUID       uuid.UUID `json:"book_uuid"` // Foreign key to Book
-	NameOfBorrower string    `json:"name_of_borrower"`
-	LoanDate       time.Time `json:"loan_date"`
-	ReturnDate     time.Time `json:"return_date"`
-	IsReturned     bool      `json:"is_returned"`
+	UUID           uuid.UUID  `json:"uuid"`
+	UserID         int64      `json:"user_id"`   // Foreign key to User
+	BookUUID       uuid.UUID  `json:"book_uuid"` // Foreign key to Book
+	NameOfBorrower string     `json:"name_of_borrower"`
+	LoanDate       time.Time  `json:"loan_date"`
+	ReturnDate     time.Time  `json:"return_date"`
+	IsExtended     bool       `json:"is_extended"`
+	RenewalCount   int        `json:"renewal_count"` // Extensions so far, capped by the LoanPolicy of the borrower
+	IsReturned     bool       `json:"is_returned"`
+	ReturnedAt     *time.Time `json:"returned_at,omitempty"`
+	ReturnReason   string     `json:"return_reason,omitempty"` // One of the LoanReturnReason constants
 }
+
+// Reasons a loan ended: returned by the patron, expired at its due date, or closed when the title was withdrawn.
+const (
+	LoanReturnReasonReturned  = "returned"
+	LoanReturnReasonExpired   = "expired"
+	LoanReturnReasonWithdrawn = "withdrawn"
+)
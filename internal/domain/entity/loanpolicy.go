@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	PatronCategoryStandard = "standard"
+)
+
+// Reasons a book cannot be borrowed or a loan cannot be extended, returned to clients as the code of the refusal.
+const (
+	BorrowRefusedAlreadyBorrowed = "already_borrowed"
+	BorrowRefusedMaxActiveLoans  = "max_active_loans_reached"
+	BorrowRefusedNoCopyAvailable = "no_copy_available"
+
+	ExtensionRefusedLoanOverdue          = "loan_overdue"
+	ExtensionRefusedMaxRenewals          = "max_renewals_reached"
+	ExtensionRefusedTitleInDemand        = "title_in_demand"
+	ExtensionRefusedOutsideRenewalWindow = "outside_renewal_window"
+)
+
+// LoanPolicy holds the lending rules of a patron category, see the LoanPolicies table.
+type LoanPolicy struct {
+	PatronCategory    string `json:"patron_category"`     // Primary Key
+	LoanDays          int    `json:"loan_days"`           // Length of a new loan
+	ExtensionDays     int    `json:"extension_days"`      // Days an extension adds to the due date
+	MaxRenewals       int    `json:"max_renewals"`        // Extensions allowed per loan
+	MaxActiveLoans    int    `json:"max_active_loans"`    // Loans a patron may hold at the same time
+	RenewalWindowDays int    `json:"renewal_window_days"` // How close to the due date a loan can be extended; 0 is any time
+	HoldOfferHours    int    `json:"hold_offer_hours"`    // How long a copy offered to a hold is reserved for the patron
+}
+
+// InRenewalWindow reports whether a loan due at returnDate is close enough to its due date to be extended.
+func (p LoanPolicy) InRenewalWindow(returnDate time.Time, now time.Time) bool {
+	if p.RenewalWindowDays == 0 {
+		return true
+	}
+	return !now.Before(returnDate.AddDate(0, 0, -p.RenewalWindowDays))
+}
+
+// ExtensionRefusal returns why an active loan with renewalCount extensions, due at returnDate, cannot be
+// extended now, or "" when it can. A title is in demand when more patrons wait for it than it has copies available.
+func (p LoanPolicy) ExtensionRefusal(renewalCount int, returnDate time.Time, now time.Time, inDemand bool) string {
+	switch {
+	case !returnDate.After(now):
+		return ExtensionRefusedLoanOverdue
+	case renewalCount >= p.MaxRenewals:
+		return ExtensionRefusedMaxRenewals
+	case inDemand:
+		return ExtensionRefusedTitleInDemand
+	case !p.InRenewalWindow(returnDate, now):
+		return ExtensionRefusedOutsideRenewalWindow
+	}
+	return ""
+}
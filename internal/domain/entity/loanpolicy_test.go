@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoanPolicyExtensionRefusal(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	anyTime := LoanPolicy{MaxRenewals: 2}
+	lastWeek := LoanPolicy{MaxRenewals: 1, RenewalWindowDays: 7}
+
+	tests := []struct {
+		description  string
+		policy       LoanPolicy
+		renewalCount int
+		returnDate   time.Time
+		inDemand     bool
+		expected     string
+	}{
+		{"No window allows extending right after borrowing", anyTime, 0, now.AddDate(0, 0, 28), false, ""},
+		{"Renewals left", anyTime, 1, now.AddDate(0, 0, 1), false, ""},
+		{"No renewals left", anyTime, 2, now.AddDate(0, 0, 1), false, ExtensionRefusedMaxRenewals},
+		{"Overdue", anyTime, 0, now.Add(-time.Minute), false, ExtensionRefusedLoanOverdue},
+		{"Patrons are waiting for the title", anyTime, 0, now.AddDate(0, 0, 1), true, ExtensionRefusedTitleInDemand},
+		{"Before the window opens", lastWeek, 0, now.AddDate(0, 0, 8), false, ExtensionRefusedOutsideRenewalWindow},
+		{"As the window opens", lastWeek, 0, now.AddDate(0, 0, 7), false, ""},
+		{"Inside the window", lastWeek, 0, now.AddDate(0, 0, 2), false, ""},
+		{"Demand outweighs the window", lastWeek, 0, now.AddDate(0, 0, 2), true, ExtensionRefusedTitleInDemand},
+		{"No renewals allowed", LoanPolicy{}, 0, now.AddDate(0, 0, 2), false, ExtensionRefusedMaxRenewals},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			refusal := test.policy.ExtensionRefusal(test.renewalCount, test.returnDate, now, test.inDemand)
+			assert.Equal(t, test.expected, refusal)
+		})
+	}
+}
@@ -1,6 +1,34 @@
 package entity
 
+import "time"
+
 type Session struct {
-	UserID    string `json:"userID"`
-	CreatedAt string `json:"createdAt"` // store CreatedAt as Unix timestamp string
+	ID         string `json:"id"` // Identifies the session to its user, who never sees the session ID of another cookie
+	UserID     string `json:"userID"`
+	CreatedAt  string `json:"createdAt"` // store CreatedAt as Unix timestamp string
+	ExpiresAt  string `json:"expiresAt"` // Unix timestamp string of the end of the absolute lifetime
+	RememberMe bool   `json:"rememberMe"`
+	Role       string `json:"role"` // Of the user when the session ID was issued; a change rotates the session ID
+	IP         string `json:"ip"`
+	UserAgent  string `json:"userAgent"`
+}
+
+// SessionClient is the client that a session is started from.
+type SessionClient struct {
+	IP        string
+	UserAgent string
+}
+
+// Timeouts returns the idle timeout and absolute lifetime of a session.
+func (sc *SessionConfig) Timeouts(rememberMe bool) (time.Duration, time.Duration) {
+	if rememberMe {
+		return sc.RememberMeIdleTimeout, sc.RememberMeAbsoluteLifetime
+	}
+	return sc.IdleTimeout, sc.AbsoluteLifetime
+}
+
+// SessionTTL is how long a session lives from now without activity: its idle timeout, but never past its
+// absolute expiry. It is not positive once the session has expired.
+func SessionTTL(idleTimeout time.Duration, expiresAt time.Time, now time.Time) time.Duration {
+	return min(idleTimeout, expiresAt.Sub(now))
 }
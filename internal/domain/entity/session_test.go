@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionTTL(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	config := &SessionConfig{
+		IdleTimeout: 30 * time.Minute, AbsoluteLifetime: 24 * time.Hour,
+		RememberMeIdleTimeout: 7 * 24 * time.Hour, RememberMeAbsoluteLifetime: 30 * 24 * time.Hour,
+	}
+
+	tests := []struct {
+		description string
+		rememberMe  bool
+		expiresAt   time.Time
+		expected    time.Duration
+	}{
+		{"Activity restarts the idle timeout", false, now.Add(12 * time.Hour), 30 * time.Minute},
+		{"The idle timeout stops at the absolute expiry", false, now.Add(10 * time.Minute), 10 * time.Minute},
+		{"An expired session has no time left", false, now.Add(-time.Second), -time.Second},
+		{"Remember me idles for longer", true, now.Add(20 * 24 * time.Hour), 7 * 24 * time.Hour},
+		{"Remember me stops at its absolute expiry too", true, now.Add(2 * 24 * time.Hour), 2 * 24 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			idleTimeout, _ := config.Timeouts(test.rememberMe)
+			assert.Equal(t, test.expected, SessionTTL(idleTimeout, test.expiresAt, now))
+		})
+	}
+}
@@ -4,10 +4,17 @@ import (
 	"time"
 )
 
+const (
+	RolePatron    = "patron"
+	RoleLibrarian = "librarian"
+)
+
 type User struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             int64     `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`            // RolePatron or RoleLibrarian
+	PatronCategory string    `json:"patron_category"` // Foreign key to LoanPolicy
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/google/uuid"
+)
+
+type APITokenRepository interface {
+	CreateAPIToken(requestID string, userID int64, tokenRequest dto.APITokenCreateRequest, tokenHash string,
+	) (*dto.APITokenDetail, *apperrors.RestErr)
+	GetAPITokens(requestID string, userID int64) ([]dto.APITokenDetail, *apperrors.RestErr) // Newest first
+	RevokeAPIToken(requestID string, userID int64, tokenUUID uuid.UUID) *apperrors.RestErr
+
+	// GetAPITokenByHash finds a token that is neither revoked nor expired, and records that it was used.
+	GetAPITokenByHash(tokenHash string) (*entity.APIToken, *apperrors.RestErr)
+}
@@ -3,8 +3,25 @@ package repository
 import (
 	"github.com/DarrelA/e-lib/internal/apperrors"
 	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/google/uuid"
 )
 
 type BookRepository interface {
+	// GetBook looks up a book by title, ignoring case. A title shared by several editions is a conflict.
 	GetBook(requestID string, title string) (*dto.BookDetail, *apperrors.RestErr)
+	ListBooks(requestID string, params dto.BookListRequest) (*dto.BookListResponse, *apperrors.RestErr)
+	SearchBooks(requestID string, params dto.BookSearchRequest) ([]dto.BookSearchResult, *apperrors.RestErr)
+
+	GetBookByUUID(requestID string, bookUUID uuid.UUID) (*dto.BookDetail, *apperrors.RestErr)
+	GetBookByISBN(requestID string, isbn string) (*dto.BookDetail, *apperrors.RestErr)
+	CreateBook(requestID string, book dto.BookCreateRequest) (*dto.BookDetail, *apperrors.RestErr)
+	UpdateBook(requestID string, bookUUID uuid.UUID, book dto.BookUpdateRequest) (*dto.BookDetail, *apperrors.RestErr)
+	AdjustCopies(requestID string, bookUUID uuid.UUID, delta int) (*dto.BookDetail, *apperrors.RestErr)
+	WithdrawBook(requestID string, bookUUID uuid.UUID, force bool) *apperrors.RestErr
+
+	// ImportBooks upserts the records by ISBN-13. A dry run reports the changes without saving them.
+	ImportBooks(requestID string, records []dto.ImportRecord, dryRun bool) (*dto.ImportReport, *apperrors.RestErr)
+
+	// ReconcileCopies reports the books whose available copies drifted from their active loans, and fixes them if asked.
+	ReconcileCopies(requestID string, fix bool) ([]dto.CopiesDrift, *apperrors.RestErr)
 }
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/google/uuid"
+)
+
+type HoldRepository interface {
+	// PlaceHold queues the user for the next copy of a title that has none available.
+	PlaceHold(requestID string, userID int64, bookDetail *dto.BookDetail) (*dto.HoldDetail, *apperrors.RestErr)
+	// CancelHold leaves the queue; a copy that was offered to the user passes on to the next hold.
+	CancelHold(requestID string, userID int64, bookUUID uuid.UUID) *apperrors.RestErr
+	GetHold(requestID string, userID int64, bookUUID uuid.UUID) (*dto.HoldDetail, *apperrors.RestErr)
+	GetHolds(requestID string, userID int64) ([]dto.HoldDetail, *apperrors.RestErr)
+
+	// ExpireHoldOffers expires up to batchSize lapsed offers and passes their copies on. It returns how many expired.
+	ExpireHoldOffers(requestID string, batchSize int) (int, *apperrors.RestErr)
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+)
+
+type IdempotencyRepository interface {
+	// Reserve claims key for a request with the given fingerprint. It returns nil when the key is new,
+	// or the record of the request that claimed the key first.
+	Reserve(key string, fingerprint string) (*entity.IdempotencyRecord, *apperrors.RestErr)
+
+	// Complete stores the response of the request that reserved key, for the repeats of the request to replay.
+	Complete(key string, record *entity.IdempotencyRecord) *apperrors.RestErr
+
+	// Release forgets key, so that the request can be retried.
+	Release(key string) *apperrors.RestErr
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"io"
+
+	"github.com/DarrelA/e-lib/internal/application/dto"
+)
+
+// CatalogParser reads catalog records from a source format.
+// Records that cannot be read are returned as errors, so that one bad record does not abort the whole import.
+type CatalogParser interface {
+	Parse(r io.Reader) ([]dto.ImportRecord, []dto.ImportRecordError)
+}
+
+// CatalogParserFactory returns the parser of a source format.
+// The column mapping (field name to column header) is only used by tabular formats such as CSV.
+type CatalogParserFactory func(format string, columnMapping map[string]string) (CatalogParser, error)
@@ -10,4 +10,11 @@ type LoanRepository interface {
 	BorrowBook(requestID string, userDetail dto.UserDetail, bookDetail *dto.BookDetail) (*dto.LoanDetail, *apperrors.RestErr)
 	ExtendBookLoan(requestID string, user_id int64, bookDetail *dto.BookDetail) (*dto.LoanDetail, *apperrors.RestErr)
 	ReturnBook(requestID string, user_id int64, book_uuid uuid.UUID) *apperrors.RestErr
+	GetLoans(requestID string, userID int64, params dto.LoanListRequest) (*dto.LoanListResponse, *apperrors.RestErr)
+
+	// GetLoanHistory returns the events of a loan of the user, oldest first.
+	GetLoanHistory(requestID string, userID int64, loanUUID uuid.UUID) (*dto.LoanHistoryResponse, *apperrors.RestErr)
+
+	// ExpireLoans returns up to batchSize loans that are past their due date. It returns how many expired.
+	ExpireLoans(requestID string, batchSize int) (int, *apperrors.RestErr)
 }
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+)
+
+type NotificationRepository interface {
+	// ClaimLoanNotifications claims up to batchSize notifications that are due, including failed ones to retry,
+	// so that no other instance sends them.
+	ClaimLoanNotifications(requestID string, batchSize int) ([]dto.LoanNotification, *apperrors.RestErr)
+	MarkLoanNotificationSent(requestID string, notification dto.LoanNotification) *apperrors.RestErr
+	MarkLoanNotificationFailed(requestID string, notification dto.LoanNotification, reason string) *apperrors.RestErr
+}
+
+// Mailer sends plain text emails.
+type Mailer interface {
+	SendMail(to string, subject string, body string) error
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+)
+
+type OAuth2StateRepository interface {
+	// SaveLoginState keeps the state of a login until its callback, for a limited time.
+	SaveLoginState(state string, loginState *entity.OAuth2LoginState) *apperrors.RestErr
+
+	// TakeLoginState returns the state of a login and forgets it, so that it is used once.
+	// It returns nil when the state is unknown or has expired.
+	TakeLoginState(state string) (*entity.OAuth2LoginState, *apperrors.RestErr)
+}
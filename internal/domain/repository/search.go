@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/google/uuid"
+)
+
+// SearchRepository is the port of the full-text search subsystem.
+// Any engine that can index the book metadata and answer queries against it can be plugged in behind it.
+type SearchRepository interface {
+	// IndexBooks (re)indexes the given books, or the whole catalog when no book is given.
+	IndexBooks(requestID string, bookUUIDs ...uuid.UUID) *apperrors.RestErr
+	RemoveBooks(requestID string, bookUUIDs ...uuid.UUID) *apperrors.RestErr
+	SearchBooks(requestID string, params dto.FullTextSearchRequest) (*dto.FullTextSearchResponse, *apperrors.RestErr)
+}
@@ -1,11 +1,18 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/DarrelA/e-lib/internal/apperrors"
 	"github.com/DarrelA/e-lib/internal/domain/entity"
 )
 
 type SessionRepository interface {
-	NewSession(userID int64) (string, *apperrors.RestErr)
+	NewSession(userID int64, role string, client entity.SessionClient, rememberMe bool) (string, time.Duration, *apperrors.RestErr)
 	GetSessionData(sessionID string) (*entity.Session, *apperrors.RestErr)
+	RefreshSession(sessionID string, session *entity.Session, role string) (string, time.Duration, *apperrors.RestErr)
+	GetUserSessions(userID int64) ([]entity.Session, *apperrors.RestErr) // Newest first
+	DeleteSession(userID int64, id string) *apperrors.RestErr
+	DeleteSessionData(sessionID string) *apperrors.RestErr                      // By the session ID, for a session without an ID
+	DeleteUserSessions(userID int64, exceptID string) (int, *apperrors.RestErr) // All but exceptID, if set
 }
@@ -8,6 +8,8 @@ import (
 
 type UserRepository interface {
 	GetUserByID(userID int64) (dto.UserDetail, *apperrors.RestErr)
-	GetUserID(provider string, id string, email string) (int64, *apperrors.RestErr)
+	GetUserID(provider string, id string) (int64, *apperrors.RestErr)
+	GetUserIDByEmail(email string) (int64, *apperrors.RestErr)
+	LinkProvider(userID int64, user *dto.GoogleOAuth2UserRes, provider string) *apperrors.RestErr
 	SaveUser(user *dto.GoogleOAuth2UserRes, provider string) (*entity.User, *apperrors.RestErr)
 }
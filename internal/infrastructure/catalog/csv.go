@@ -0,0 +1,178 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/DarrelA/e-lib/internal/application/dto"
+)
+
+const (
+	errMsgUnknownField  = "unknown import field '%s'"
+	errMsgMissingHeader = "the CSV file has no header row"
+	errMsgMissingColumn = "column '%s' mapped to field '%s' is not in the header"
+	errMsgNoISBNColumn  = "the CSV file needs an isbn_13 or isbn_10 column"
+	errMsgInvalidISBN   = "invalid ISBN '%s'"
+	errMsgInvalidNumber = "invalid %s '%s'"
+	csvAuthorSeparator  = ";"
+)
+
+// csvFields are the import fields, which are also the default column headers.
+var csvFields = []string{
+	"isbn_13", "isbn_10", "title", "authors", "edition", "publisher",
+	"language", "publication_year", "description", "total_copies",
+}
+
+/*
+CSVParser reads a CSV file with a header row.
+By default a column is read into the field of the same name; the column mapping renames the column of a field,
+e.g. {"title": "Book Title"}. Headers are matched case-insensitively and authors are separated by semicolons.
+*/
+type CSVParser struct {
+	columnMapping map[string]string
+}
+
+func NewCSVParser(columnMapping map[string]string) (*CSVParser, error) {
+	for field := range columnMapping {
+		if !slices.Contains(csvFields, field) {
+			return nil, fmt.Errorf(errMsgUnknownField, field)
+		}
+	}
+	return &CSVParser{columnMapping}, nil
+}
+
+func (p *CSVParser) Parse(r io.Reader) ([]dto.ImportRecord, []dto.ImportRecordError) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New(errMsgMissingHeader)
+		}
+		return nil, []dto.ImportRecordError{{Line: 1, Message: err.Error()}}
+	}
+
+	columns, err := p.resolveColumns(header)
+	if err != nil {
+		return nil, []dto.ImportRecordError{{Line: 1, Message: err.Error()}}
+	}
+
+	records := []dto.ImportRecord{}
+	recordErrors := []dto.ImportRecordError{}
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.StartLine
+			}
+			recordErrors = append(recordErrors, dto.ImportRecordError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		record, err := csvRecord(row, columns)
+		if err != nil {
+			recordErrors = append(recordErrors, dto.ImportRecordError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		record.Line = line
+		records = append(records, record)
+	}
+
+	return records, recordErrors
+}
+
+// resolveColumns maps each field to the index of its column.
+func (p *CSVParser) resolveColumns(header []string) (map[string]int, error) {
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff") // UTF-8 byte order mark
+	}
+
+	columns := map[string]int{}
+	for _, field := range csvFields {
+		columnName, mapped := p.columnMapping[field]
+		if !mapped {
+			columnName = field
+		}
+
+		index := slices.IndexFunc(header, func(h string) bool {
+			return strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(columnName))
+		})
+		if index < 0 {
+			if mapped {
+				return nil, fmt.Errorf(errMsgMissingColumn, columnName, field)
+			}
+			continue
+		}
+		columns[field] = index
+	}
+
+	_, hasISBN13 := columns["isbn_13"]
+	_, hasISBN10 := columns["isbn_10"]
+	if !hasISBN13 && !hasISBN10 {
+		return nil, errors.New(errMsgNoISBNColumn)
+	}
+
+	return columns, nil
+}
+
+func csvRecord(row []string, columns map[string]int) (dto.ImportRecord, error) {
+	value := func(field string) string {
+		index, ok := columns[field]
+		if !ok || index >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[index])
+	}
+
+	record := dto.ImportRecord{
+		Title:       value("title"),
+		Edition:     value("edition"),
+		Publisher:   value("publisher"),
+		Language:    strings.ToLower(value("language")),
+		Description: value("description"),
+	}
+
+	for _, field := range []string{"isbn_13", "isbn_10"} {
+		if isbn := value(field); isbn != "" && !setISBN(&record.ISBN13, &record.ISBN10, isbn) {
+			return record, fmt.Errorf(errMsgInvalidISBN, isbn)
+		}
+	}
+
+	for _, author := range strings.Split(value("authors"), csvAuthorSeparator) {
+		if author = strings.TrimSpace(author); author != "" {
+			record.Authors = append(record.Authors, author)
+		}
+	}
+
+	if year := value("publication_year"); year != "" {
+		publicationYear, err := strconv.Atoi(year)
+		if err != nil {
+			return record, fmt.Errorf(errMsgInvalidNumber, "publication_year", year)
+		}
+		record.PublicationYear = publicationYear
+	}
+
+	if copies := value("total_copies"); copies != "" {
+		totalCopies, err := strconv.Atoi(copies)
+		if err != nil {
+			return record, fmt.Errorf(errMsgInvalidNumber, "total_copies", copies)
+		}
+		record.TotalCopies = &totalCopies
+	}
+
+	return record, nil
+}
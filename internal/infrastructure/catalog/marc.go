@@ -0,0 +1,152 @@
+package catalog
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/DarrelA/e-lib/internal/application/dto"
+)
+
+const (
+	errMsgMARCNoISBN  = "record has no valid ISBN in field 020"
+	errMsgMARCNoTitle = "record has no title in field 245"
+)
+
+// marcLanguages maps the MARC language codes (008/35-37) of the supported search languages to ISO 639-1.
+var marcLanguages = map[string]string{
+	"ara": "ar", "arm": "hy", "baq": "eu", "cat": "ca", "chi": "zh", "dan": "da", "dut": "nl", "eng": "en",
+	"fin": "fi", "fre": "fr", "ger": "de", "gre": "el", "hin": "hi", "hun": "hu", "iri": "ga", "ind": "id",
+	"ita": "it", "jpn": "ja", "kor": "ko", "lit": "lt", "nep": "ne", "nor": "no", "por": "pt", "rum": "ro",
+	"rus": "ru", "scc": "sr", "srp": "sr", "spa": "es", "swe": "sv", "tam": "ta", "tur": "tr", "yid": "yi",
+}
+
+// Trailing ISBD punctuation that MARC cataloguers put before the next subfield.
+const isbdPunctuation = " /:;,.="
+
+type marcSubfield struct {
+	code  byte
+	value string
+}
+
+type marcDataField struct {
+	tag        string
+	indicator2 byte
+	subfields  []marcSubfield
+}
+
+// marcRecord is a MARC 21 bibliographic record as read from either the binary or the XML serialization.
+type marcRecord struct {
+	controlFields map[string]string
+	dataFields    []marcDataField
+}
+
+func (f marcDataField) subfield(code byte) string {
+	for _, subfield := range f.subfields {
+		if subfield.code == code {
+			return strings.TrimSpace(subfield.value)
+		}
+	}
+	return ""
+}
+
+func (r marcRecord) fields(tag string) []marcDataField {
+	fields := []marcDataField{}
+	for _, field := range r.dataFields {
+		if field.tag == tag {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func (r marcRecord) firstSubfield(tag string, code byte) string {
+	for _, field := range r.fields(tag) {
+		if value := field.subfield(code); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+/*
+toImportRecord maps the MARC 21 fields used by the catalog:
+  - 020 $a ISBN, which may be followed by a qualifier such as "(pbk.)"
+  - 100 $a main author and 700 $a added authors
+  - 245 $a title and $b remainder of title
+  - 250 $a edition
+  - 264 (second indicator 1) or 260 $b publisher and $c date
+  - 008/07-10 date and 008/35-37 language
+  - 520 $a summary
+
+MARC records do not hold circulation data, so the copies of the title are left unchanged.
+*/
+func (r marcRecord) toImportRecord() (dto.ImportRecord, error) {
+	record := dto.ImportRecord{}
+
+	for _, field := range r.fields("020") {
+		if isbn, _, _ := strings.Cut(field.subfield('a'), " "); isbn != "" {
+			setISBN(&record.ISBN13, &record.ISBN10, isbn)
+		}
+	}
+	if record.ISBN13 == "" {
+		return record, errors.New(errMsgMARCNoISBN)
+	}
+
+	title := trimISBD(r.firstSubfield("245", 'a'))
+	if subtitle := trimISBD(r.firstSubfield("245", 'b')); subtitle != "" {
+		title += ": " + subtitle
+	}
+	if title == "" {
+		return record, errors.New(errMsgMARCNoTitle)
+	}
+	record.Title = title
+
+	for _, tag := range []string{"100", "700"} {
+		for _, field := range r.fields(tag) {
+			if author := trimISBD(field.subfield('a')); author != "" {
+				record.Authors = append(record.Authors, author)
+			}
+		}
+	}
+
+	record.Edition = trimISBD(r.firstSubfield("250", 'a'))
+	record.Description = r.firstSubfield("520", 'a')
+
+	publication := r.publicationField()
+	record.Publisher = trimISBD(publication.subfield('b'))
+
+	fixedLength := r.controlFields["008"]
+	year := ""
+	if len(fixedLength) >= 11 {
+		year = yearPattern.FindString(fixedLength[7:11])
+	}
+	if year == "" {
+		year = yearPattern.FindString(publication.subfield('c'))
+	}
+	for _, digit := range year {
+		record.PublicationYear = record.PublicationYear*10 + int(digit-'0')
+	}
+
+	if len(fixedLength) >= 38 {
+		record.Language = marcLanguages[fixedLength[35:38]]
+	}
+
+	return record, nil
+}
+
+// publicationField prefers the RDA publication statement (264 with second indicator 1) over the older 260.
+func (r marcRecord) publicationField() marcDataField {
+	for _, field := range r.fields("264") {
+		if field.indicator2 == '1' {
+			return field
+		}
+	}
+	if fields := r.fields("260"); len(fields) > 0 {
+		return fields[0]
+	}
+	return marcDataField{}
+}
+
+func trimISBD(value string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(value), isbdPunctuation))
+}
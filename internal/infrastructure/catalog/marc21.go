@@ -0,0 +1,144 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/DarrelA/e-lib/internal/application/dto"
+)
+
+const (
+	marcLeaderLength    = 24
+	marcDirectoryLength = 12
+
+	marcFieldTerminator  = 0x1E
+	marcRecordTerminator = 0x1D
+	marcSubfieldDelim    = 0x1F
+
+	errMsgMARCTruncated      = "truncated record"
+	errMsgMARCInvalidLeader  = "invalid leader"
+	errMsgMARCInvalidEntry   = "invalid directory entry for field %s"
+	errMsgMARCUnsupportedEnc = "MARC-8 encoded records with non-ASCII characters are not supported; convert the file to UTF-8"
+)
+
+/*
+MARC21Parser reads binary MARC 21 (ISO 2709) records.
+Records must be UTF-8 encoded (leader/09 `a`); MARC-8 records are accepted only when they are plain ASCII.
+*/
+type MARC21Parser struct{}
+
+func (MARC21Parser) Parse(r io.Reader) ([]dto.ImportRecord, []dto.ImportRecordError) {
+	records := []dto.ImportRecord{}
+	recordErrors := []dto.ImportRecordError{}
+
+	reader := bufio.NewReader(r)
+	for position := 1; ; position++ {
+		data, err := reader.ReadBytes(marcRecordTerminator)
+		// Line breaks between records are common in files that were edited by hand.
+		data = bytes.TrimLeft(data, "\r\n")
+		if len(data) > 0 {
+			record, parseErr := parseMARC21Record(data)
+			if parseErr == nil {
+				var importRecord dto.ImportRecord
+				importRecord, parseErr = record.toImportRecord()
+				importRecord.Line = position
+				if parseErr == nil {
+					records = append(records, importRecord)
+				}
+			}
+			if parseErr != nil {
+				recordErrors = append(recordErrors, dto.ImportRecordError{Line: position, Message: parseErr.Error()})
+			}
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				recordErrors = append(recordErrors, dto.ImportRecordError{Line: position, Message: err.Error()})
+			}
+			break
+		}
+	}
+
+	return records, recordErrors
+}
+
+func parseMARC21Record(data []byte) (marcRecord, error) {
+	record := marcRecord{controlFields: map[string]string{}}
+	if len(data) < marcLeaderLength || data[len(data)-1] != marcRecordTerminator {
+		return record, errors.New(errMsgMARCTruncated)
+	}
+
+	leader := data[:marcLeaderLength]
+	recordLength, err := strconv.Atoi(string(leader[0:5]))
+	if err != nil {
+		return record, errors.New(errMsgMARCInvalidLeader)
+	}
+	baseAddress, err := strconv.Atoi(string(leader[12:17]))
+	if err != nil || baseAddress <= marcLeaderLength || baseAddress > len(data) {
+		return record, errors.New(errMsgMARCInvalidLeader)
+	}
+	if recordLength != len(data) {
+		return record, errors.New(errMsgMARCTruncated)
+	}
+
+	isUTF8 := leader[9] == 'a'
+	if !isUTF8 && !isASCII(data) {
+		return record, errors.New(errMsgMARCUnsupportedEnc)
+	}
+
+	directory := data[marcLeaderLength : baseAddress-1]
+	for offset := 0; offset+marcDirectoryLength <= len(directory); offset += marcDirectoryLength {
+		entry := directory[offset : offset+marcDirectoryLength]
+		tag := string(entry[0:3])
+		length, lengthErr := strconv.Atoi(string(entry[3:7]))
+		start, startErr := strconv.Atoi(string(entry[7:12]))
+		end := baseAddress + start + length
+		if lengthErr != nil || startErr != nil || start < 0 || length < 1 || end > len(data) {
+			return record, fmt.Errorf(errMsgMARCInvalidEntry, tag)
+		}
+
+		// The field length includes its terminator.
+		field := data[baseAddress+start : end-1]
+		if !utf8.Valid(field) {
+			return record, fmt.Errorf(errMsgMARCInvalidEntry, tag)
+		}
+
+		if tag < "010" {
+			record.controlFields[tag] = string(field)
+			continue
+		}
+		record.dataFields = append(record.dataFields, parseMARC21DataField(tag, field))
+	}
+
+	return record, nil
+}
+
+func parseMARC21DataField(tag string, field []byte) marcDataField {
+	dataField := marcDataField{tag: tag}
+	if len(field) >= 2 {
+		dataField.indicator2 = field[1]
+		field = field[2:]
+	}
+
+	for _, subfield := range bytes.Split(field, []byte{marcSubfieldDelim}) {
+		if len(subfield) == 0 {
+			continue // Data before the first delimiter
+		}
+		dataField.subfields = append(dataField.subfields, marcSubfield{code: subfield[0], value: string(subfield[1:])})
+	}
+	return dataField
+}
+
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
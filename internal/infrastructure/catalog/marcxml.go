@@ -0,0 +1,93 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+
+	"github.com/DarrelA/e-lib/internal/application/dto"
+)
+
+type marcXMLSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcXMLRecord struct {
+	ControlFields []struct {
+		Tag   string `xml:"tag,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"controlfield"`
+	DataFields []struct {
+		Tag       string            `xml:"tag,attr"`
+		Ind2      string            `xml:"ind2,attr"`
+		Subfields []marcXMLSubfield `xml:"subfield"`
+	} `xml:"datafield"`
+}
+
+/*
+MARCXMLParser reads MARC 21 records in the MARCXML schema (http://www.loc.gov/MARC21/slim),
+either a `collection` of records or a single `record`.
+*/
+type MARCXMLParser struct{}
+
+func (MARCXMLParser) Parse(r io.Reader) ([]dto.ImportRecord, []dto.ImportRecordError) {
+	records := []dto.ImportRecord{}
+	recordErrors := []dto.ImportRecordError{}
+
+	decoder := xml.NewDecoder(r)
+	position := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				recordErrors = append(recordErrors, dto.ImportRecordError{Line: position + 1, Message: err.Error()})
+			}
+			break
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		position++
+		var xmlRecord marcXMLRecord
+		if err := decoder.DecodeElement(&xmlRecord, &start); err != nil {
+			recordErrors = append(recordErrors, dto.ImportRecordError{Line: position, Message: err.Error()})
+			break // The decoder cannot resume after malformed XML
+		}
+
+		record, err := xmlRecord.toMARCRecord().toImportRecord()
+		if err != nil {
+			recordErrors = append(recordErrors, dto.ImportRecordError{Line: position, Message: err.Error()})
+			continue
+		}
+
+		record.Line = position
+		records = append(records, record)
+	}
+
+	return records, recordErrors
+}
+
+func (x marcXMLRecord) toMARCRecord() marcRecord {
+	record := marcRecord{controlFields: map[string]string{}}
+	for _, controlField := range x.ControlFields {
+		record.controlFields[controlField.Tag] = controlField.Value
+	}
+
+	for _, xmlField := range x.DataFields {
+		dataField := marcDataField{tag: xmlField.Tag}
+		if xmlField.Ind2 != "" {
+			dataField.indicator2 = xmlField.Ind2[0]
+		}
+		for _, subfield := range xmlField.Subfields {
+			if subfield.Code != "" {
+				dataField.subfields = append(dataField.subfields, marcSubfield{code: subfield.Code[0], value: subfield.Value})
+			}
+		}
+		record.dataFields = append(record.dataFields, dataField)
+	}
+	return record
+}
@@ -0,0 +1,369 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/application/dto"
+)
+
+// ONIX code list values used by the parser.
+const (
+	onixNotificationBlockUpdate = "04" // List 1
+	onixNotificationDelete      = "05"
+
+	onixProductIDISBN10 = "02" // List 5
+	onixProductIDISBN13 = "15"
+
+	onixTitleDistinctive  = "01" // List 15
+	onixTitleLevelProduct = "01" // List 149
+
+	onixRoleAuthor     = "A01" // List 17
+	onixRoleAuthorWith = "A02"
+
+	onixLanguageOfText = "01" // List 22
+
+	onixTextDescription      = "03" // List 153
+	onixTextShortDescription = "02"
+
+	onixPublishingRolePublisher = "01" // List 45
+	onixPublishingDatePublished = "01" // List 163
+
+	errMsgONIXShortTags     = "ONIX short tags are not supported; send the message with reference tags"
+	errMsgONIXNoHeader      = "the ONIX message has no header before its products"
+	errMsgONIXNoReference   = "product has no RecordReference"
+	errMsgONIXNoISBN        = "product has no ISBN-13 or ISBN-10 identifier"
+	errMsgONIXNoTitle       = "product has no distinctive title"
+	errMsgONIXInvalidSentAt = "invalid SentDateTime '%s'"
+)
+
+var (
+	markupPattern     = regexp.MustCompile(`<[^>]*>`)
+	onixSentAtLayouts = []string{"20060102T150405Z0700", "20060102T150405", "20060102T1504Z0700", "20060102T1504", "20060102"}
+)
+
+type onixHeader struct {
+	SenderName    string `xml:"Sender>SenderName"`
+	MessageNumber string `xml:"MessageNumber"`
+	SentDateTime  string `xml:"SentDateTime"`
+}
+
+type onixTitleDetail struct {
+	TitleType    string `xml:"TitleType"`
+	TitleElement []struct {
+		TitleElementLevel  string `xml:"TitleElementLevel"`
+		TitleText          string `xml:"TitleText"`
+		TitlePrefix        string `xml:"TitlePrefix"`
+		TitleWithoutPrefix string `xml:"TitleWithoutPrefix"`
+		Subtitle           string `xml:"Subtitle"`
+	} `xml:"TitleElement"`
+}
+
+type onixContributor struct {
+	SequenceNumber     int      `xml:"SequenceNumber"`
+	ContributorRole    []string `xml:"ContributorRole"`
+	PersonName         string   `xml:"PersonName"`
+	PersonNameInverted string   `xml:"PersonNameInverted"`
+	NamesBeforeKey     string   `xml:"NamesBeforeKey"`
+	KeyNames           string   `xml:"KeyNames"`
+	CorporateName      string   `xml:"CorporateName"`
+}
+
+type onixProduct struct {
+	RecordReference   string `xml:"RecordReference"`
+	NotificationType  string `xml:"NotificationType"`
+	ProductIdentifier []struct {
+		ProductIDType string `xml:"ProductIDType"`
+		IDValue       string `xml:"IDValue"`
+	} `xml:"ProductIdentifier"`
+	DescriptiveDetail struct {
+		TitleDetail      []onixTitleDetail `xml:"TitleDetail"`
+		Contributor      []onixContributor `xml:"Contributor"`
+		EditionStatement string            `xml:"EditionStatement"`
+		EditionNumber    string            `xml:"EditionNumber"`
+		Language         []struct {
+			LanguageRole string `xml:"LanguageRole"`
+			LanguageCode string `xml:"LanguageCode"`
+		} `xml:"Language"`
+	} `xml:"DescriptiveDetail"`
+	CollateralDetail struct {
+		TextContent []struct {
+			TextType string `xml:"TextType"`
+			Text     []struct {
+				Value string `xml:",innerxml"`
+			} `xml:"Text"`
+		} `xml:"TextContent"`
+	} `xml:"CollateralDetail"`
+	PublishingDetail struct {
+		Publisher []struct {
+			PublishingRole string `xml:"PublishingRole"`
+			PublisherName  string `xml:"PublisherName"`
+		} `xml:"Publisher"`
+		PublishingDate []struct {
+			PublishingDateRole string `xml:"PublishingDateRole"`
+			Date               string `xml:"Date"`
+		} `xml:"PublishingDate"`
+	} `xml:"PublishingDetail"`
+	ProductSupply []struct {
+		SupplyDetail []struct {
+			Stock []struct {
+				OnHand *int `xml:"OnHand"`
+			} `xml:"Stock"`
+		} `xml:"SupplyDetail"`
+	} `xml:"ProductSupply"`
+}
+
+/*
+ONIXParser reads ONIX for Books 3.0 messages with reference tags.
+The stock on hand of all suppliers is the number of copies (or licences) of the title.
+Each Product becomes a record keyed by the sender and its RecordReference; a delete notification (NotificationType 05)
+becomes a record that withdraws the title. Block updates (NotificationType 04) only carry some blocks, which works
+because the import finds the title by the RecordReference and leaves the fields that a record does not provide untouched.
+*/
+type ONIXParser struct{}
+
+func (ONIXParser) Parse(r io.Reader) ([]dto.ImportRecord, []dto.ImportRecordError) {
+	records := []dto.ImportRecord{}
+	recordErrors := []dto.ImportRecordError{}
+
+	var source *dto.ImportSource
+	decoder := xml.NewDecoder(r)
+	position := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				recordErrors = append(recordErrors, dto.ImportRecordError{Line: position + 1, Message: err.Error()})
+			}
+			break
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "ONIXmessage":
+			return records, append(recordErrors, dto.ImportRecordError{Line: 1, Message: errMsgONIXShortTags})
+
+		case "Header":
+			var header onixHeader
+			if err := decoder.DecodeElement(&header, &start); err != nil {
+				return records, append(recordErrors, dto.ImportRecordError{Line: 1, Message: err.Error()})
+			}
+
+			source, err = header.toImportSource()
+			if err != nil {
+				return records, append(recordErrors, dto.ImportRecordError{Line: 1, Message: err.Error()})
+			}
+
+		case "Product":
+			position++
+			var product onixProduct
+			if err := decoder.DecodeElement(&product, &start); err != nil {
+				recordErrors = append(recordErrors, dto.ImportRecordError{Line: position, Message: err.Error()})
+				return records, recordErrors // The decoder cannot resume after malformed XML
+			}
+
+			if source == nil {
+				return records, append(recordErrors, dto.ImportRecordError{Line: position, Message: errMsgONIXNoHeader})
+			}
+
+			record, err := product.toImportRecord(*source)
+			if err != nil {
+				recordErrors = append(recordErrors, dto.ImportRecordError{Line: position, Message: err.Error()})
+				continue
+			}
+
+			record.Line = position
+			records = append(records, record)
+		}
+	}
+
+	return records, recordErrors
+}
+
+func (h onixHeader) toImportSource() (*dto.ImportSource, error) {
+	source := &dto.ImportSource{Sender: strings.TrimSpace(h.SenderName), MessageID: strings.TrimSpace(h.MessageNumber)}
+
+	sentDateTime := strings.TrimSpace(h.SentDateTime)
+	for _, layout := range onixSentAtLayouts {
+		if sentAt, err := time.Parse(layout, sentDateTime); err == nil {
+			source.SentAt = sentAt.UTC()
+			break
+		}
+	}
+	if source.SentAt.IsZero() {
+		return nil, fmt.Errorf(errMsgONIXInvalidSentAt, sentDateTime)
+	}
+
+	// Senders that do not number their messages are identified by the time they sent them.
+	if source.MessageID == "" {
+		source.MessageID = sentDateTime
+	}
+	return source, nil
+}
+
+func (p onixProduct) toImportRecord(source dto.ImportSource) (dto.ImportRecord, error) {
+	record := dto.ImportRecord{}
+
+	source.RecordReference = strings.TrimSpace(p.RecordReference)
+	source.NotificationType = strings.TrimSpace(p.NotificationType)
+	if source.RecordReference == "" {
+		return record, errors.New(errMsgONIXNoReference)
+	}
+	record.Source = &source
+
+	for _, identifierType := range []string{onixProductIDISBN13, onixProductIDISBN10} {
+		for _, identifier := range p.ProductIdentifier {
+			if identifier.ProductIDType == identifierType {
+				setISBN(&record.ISBN13, &record.ISBN10, identifier.IDValue)
+			}
+		}
+	}
+
+	// A delete notification only needs to identify the product.
+	if source.NotificationType == onixNotificationDelete {
+		record.Delete = true
+		return record, nil
+	}
+
+	// A block update only carries the blocks that changed, so it may leave out the ISBN and the title.
+	isBlockUpdate := source.NotificationType == onixNotificationBlockUpdate
+	if record.ISBN13 == "" && !isBlockUpdate {
+		return record, errors.New(errMsgONIXNoISBN)
+	}
+
+	record.Title = p.title()
+	if record.Title == "" && !isBlockUpdate {
+		return record, errors.New(errMsgONIXNoTitle)
+	}
+
+	record.Authors = p.authors()
+	record.Edition = strings.TrimSpace(p.DescriptiveDetail.EditionStatement)
+	if record.Edition == "" {
+		record.Edition = strings.TrimSpace(p.DescriptiveDetail.EditionNumber)
+	}
+
+	for _, language := range p.DescriptiveDetail.Language {
+		if language.LanguageRole == onixLanguageOfText {
+			record.Language = marcLanguages[strings.ToLower(strings.TrimSpace(language.LanguageCode))]
+			break
+		}
+	}
+
+	record.Description = p.description()
+
+	for _, publisher := range p.PublishingDetail.Publisher {
+		if publisher.PublishingRole == onixPublishingRolePublisher {
+			record.Publisher = strings.TrimSpace(publisher.PublisherName)
+			break
+		}
+	}
+
+	for _, publishingDate := range p.PublishingDetail.PublishingDate {
+		if publishingDate.PublishingDateRole == onixPublishingDatePublished {
+			record.PublicationYear, _ = strconv.Atoi(yearPattern.FindString(publishingDate.Date))
+			break
+		}
+	}
+
+	record.TotalCopies = p.stockOnHand()
+	return record, nil
+}
+
+func (p onixProduct) title() string {
+	for _, titleDetail := range p.DescriptiveDetail.TitleDetail {
+		if titleDetail.TitleType != onixTitleDistinctive {
+			continue
+		}
+
+		for _, element := range titleDetail.TitleElement {
+			if element.TitleElementLevel != onixTitleLevelProduct {
+				continue
+			}
+
+			title := strings.TrimSpace(element.TitleText)
+			if title == "" {
+				title = strings.TrimSpace(strings.TrimSpace(element.TitlePrefix) + " " + strings.TrimSpace(element.TitleWithoutPrefix))
+			}
+			if subtitle := strings.TrimSpace(element.Subtitle); title != "" && subtitle != "" {
+				title += ": " + subtitle
+			}
+			return title
+		}
+	}
+	return ""
+}
+
+func (p onixProduct) authors() []string {
+	contributors := slices.Clone(p.DescriptiveDetail.Contributor)
+	slices.SortStableFunc(contributors, func(a, b onixContributor) int { return a.SequenceNumber - b.SequenceNumber })
+
+	authors := []string{}
+	for _, contributor := range contributors {
+		if !slices.Contains(contributor.ContributorRole, onixRoleAuthor) &&
+			!slices.Contains(contributor.ContributorRole, onixRoleAuthorWith) {
+			continue
+		}
+
+		name := strings.TrimSpace(contributor.PersonName)
+		if name == "" {
+			name = strings.TrimSpace(strings.TrimSpace(contributor.NamesBeforeKey) + " " + strings.TrimSpace(contributor.KeyNames))
+		}
+		if name == "" {
+			name = strings.TrimSpace(contributor.PersonNameInverted)
+		}
+		if name == "" {
+			name = strings.TrimSpace(contributor.CorporateName)
+		}
+		if name != "" {
+			authors = append(authors, name)
+		}
+	}
+	return authors
+}
+
+// description prefers the long description and reduces XHTML text to plain text.
+func (p onixProduct) description() string {
+	for _, textType := range []string{onixTextDescription, onixTextShortDescription} {
+		for _, textContent := range p.CollateralDetail.TextContent {
+			if textContent.TextType == textType && len(textContent.Text) > 0 {
+				text := strings.TrimPrefix(strings.TrimSpace(textContent.Text[0].Value), "<![CDATA[")
+				text = strings.TrimSuffix(text, "]]>")
+				// Markup may arrive as elements or escaped, so it is stripped before and after unescaping.
+				text = html.UnescapeString(markupPattern.ReplaceAllString(text, " "))
+				return strings.Join(strings.Fields(markupPattern.ReplaceAllString(text, " ")), " ")
+			}
+		}
+	}
+	return ""
+}
+
+// stockOnHand sums the stock of all suppliers, or returns nil when the product carries no stock figures.
+func (p onixProduct) stockOnHand() *int {
+	var onHand *int
+	for _, productSupply := range p.ProductSupply {
+		for _, supplyDetail := range productSupply.SupplyDetail {
+			for _, stock := range supplyDetail.Stock {
+				if stock.OnHand == nil {
+					continue
+				}
+				if onHand == nil {
+					onHand = new(int)
+				}
+				*onHand += max(*stock.OnHand, 0)
+			}
+		}
+	}
+	return onHand
+}
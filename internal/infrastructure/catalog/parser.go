@@ -0,0 +1,89 @@
+/*
+Package catalog reads bibliographic records for the bulk catalog import.
+Each source format has its own parser; NewParser picks one by name.
+*/
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatMARC21  = "marc21"
+	FormatMARCXML = "marcxml"
+	FormatONIX    = "onix"
+
+	errMsgUnsupportedFormat = "unsupported import format '%s'"
+)
+
+var (
+	isbnNormalizer = strings.NewReplacer("-", "", " ", "")
+	isbnPattern    = regexp.MustCompile(`^(\d{9}[\dXx]|\d{13})$`)
+	yearPattern    = regexp.MustCompile(`\d{4}`)
+)
+
+// NewParser implements repository.CatalogParserFactory.
+func NewParser(format string, columnMapping map[string]string) (repository.CatalogParser, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return NewCSVParser(columnMapping)
+	case FormatMARC21:
+		return MARC21Parser{}, nil
+	case FormatMARCXML:
+		return MARCXMLParser{}, nil
+	case FormatONIX:
+		return ONIXParser{}, nil
+	default:
+		return nil, fmt.Errorf(errMsgUnsupportedFormat, format)
+	}
+}
+
+// normalizeISBN strips the hyphens and spaces of an ISBN and returns it with its length (10 or 13),
+// or a length of 0 when it is not an ISBN. A trailing check digit `x` is upper cased.
+func normalizeISBN(isbn string) (string, int) {
+	isbn = strings.ToUpper(isbnNormalizer.Replace(isbn))
+	if !isbnPattern.MatchString(isbn) {
+		return "", 0
+	}
+	return isbn, len(isbn)
+}
+
+// isbn10To13 converts an ISBN-10 to its ISBN-13 (Bookland 978 prefix) with a recomputed check digit.
+func isbn10To13(isbn10 string) string {
+	isbn13 := "978" + isbn10[:9]
+	sum := 0
+	for i, digit := range isbn13 {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(digit-'0') * weight
+	}
+	return fmt.Sprintf("%s%d", isbn13, (10-sum%10)%10)
+}
+
+// setISBN assigns an ISBN of either length, filling the ISBN-13 from an ISBN-10 when it is missing.
+func setISBN(isbn13, isbn10 *string, isbn string) bool {
+	isbn, length := normalizeISBN(isbn)
+	switch length {
+	case 13:
+		if *isbn13 == "" {
+			*isbn13 = isbn
+		}
+	case 10:
+		if *isbn10 == "" {
+			*isbn10 = isbn
+		}
+		if *isbn13 == "" {
+			*isbn13 = isbn10To13(isbn)
+		}
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+// marc21Field is a field of a test record; a data field value starts with its indicators.
+type marc21Field struct {
+	tag   string
+	value string
+}
+
+// encodeMARC21 builds a binary MARC 21 record with a UTF-8 leader.
+func encodeMARC21(fields ...marc21Field) string {
+	var directory, data strings.Builder
+	for _, field := range fields {
+		value := field.value + "\x1e"
+		fmt.Fprintf(&directory, "%s%04d%05d", field.tag, len(value), data.Len())
+		data.WriteString(value)
+	}
+	directory.WriteString("\x1e")
+
+	baseAddress := 24 + directory.Len()
+	recordLength := baseAddress + data.Len() + 1
+	leader := fmt.Sprintf("%05dnam a22%05d i 4500", recordLength, baseAddress)
+	return leader + directory.String() + data.String() + "\x1d"
+}
+
+func TestParsers(t *testing.T) {
+	copies := 3
+	annaKarenina := dto.ImportRecord{
+		Line:            1,
+		ISBN13:          "9780140449174",
+		ISBN10:          "0140449175",
+		Title:           "Anna Karenina",
+		Authors:         []string{"Tolstoy, Leo", "Pevear, Richard"},
+		Edition:         "Penguin Classics ed",
+		Publisher:       "Penguin Books",
+		Language:        "en",
+		PublicationYear: 2004,
+		Description:     "A novel of love and society.",
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		parser, err := NewParser("csv", map[string]string{"title": "Book Title", "authors": "Writers"})
+		assert.Nil(t, err)
+
+		source := "isbn_10,Book Title,Writers,total_copies\n" +
+			"0-14-044917-5,Anna Karenina,Leo Tolstoy; Richard Pevear,3\n" +
+			"12345,Bad ISBN,,1\n" +
+			"9780140449174,Copies,,many\n"
+		records, recordErrors := parser.Parse(strings.NewReader(source))
+
+		assert.Equal(t, []dto.ImportRecord{{
+			Line:        2,
+			ISBN13:      "9780140449174",
+			ISBN10:      "0140449175",
+			Title:       "Anna Karenina",
+			Authors:     []string{"Leo Tolstoy", "Richard Pevear"},
+			TotalCopies: &copies,
+		}}, records)
+		assert.Equal(t, []dto.ImportRecordError{
+			{Line: 3, Message: "invalid ISBN '12345'"},
+			{Line: 4, Message: "invalid total_copies 'many'"},
+		}, recordErrors)
+	})
+
+	t.Run("CSV with a missing mapped column", func(t *testing.T) {
+		parser, err := NewParser("csv", map[string]string{"title": "Book Title"})
+		assert.Nil(t, err)
+
+		records, recordErrors := parser.Parse(strings.NewReader("isbn_13,title\n9780140449174,Anna\n"))
+		assert.Empty(t, records)
+		assert.Equal(t, []dto.ImportRecordError{
+			{Line: 1, Message: "column 'Book Title' mapped to field 'title' is not in the header"},
+		}, recordErrors)
+	})
+
+	t.Run("MARC21", func(t *testing.T) {
+		source := encodeMARC21(
+			marc21Field{"001", "ocm12345"},
+			marc21Field{"008", "040302s2004    nyu           000 1 eng d"},
+			marc21Field{"020", "  \x1fa0140449175 (pbk.)"},
+			marc21Field{"100", "1 \x1faTolstoy, Leo,\x1fd1828-1910."},
+			marc21Field{"245", "10\x1faAnna Karenina /\x1fcLeo Tolstoy."},
+			marc21Field{"250", "  \x1faPenguin Classics ed."},
+			marc21Field{"264", " 1\x1faNew York :\x1fbPenguin Books,\x1fc2004."},
+			marc21Field{"520", "  \x1faA novel of love and society."},
+			marc21Field{"700", "1 \x1faPevear, Richard,\x1fetranslator."},
+		) + "\n" + encodeMARC21(marc21Field{"245", "10\x1faNo ISBN"}) + "00042nam"
+
+		records, recordErrors := MARC21Parser{}.Parse(strings.NewReader(source))
+		assert.Equal(t, []dto.ImportRecord{annaKarenina}, records)
+		assert.Equal(t, []dto.ImportRecordError{
+			{Line: 2, Message: errMsgMARCNoISBN},
+			{Line: 3, Message: errMsgMARCTruncated},
+		}, recordErrors)
+	})
+
+	t.Run("MARC21 with a corrupt directory entry", func(t *testing.T) {
+		record := encodeMARC21(marc21Field{"245", "10\x1faAnna Karenina"})
+		// Point the field 45 bytes before the base address.
+		corrupt := strings.Replace(record, "245001800000", "2450018-0045", 1)
+
+		records, recordErrors := MARC21Parser{}.Parse(strings.NewReader(corrupt + record))
+		assert.Equal(t, []dto.ImportRecord{}, records)
+		assert.Equal(t, []dto.ImportRecordError{
+			{Line: 1, Message: fmt.Sprintf(errMsgMARCInvalidEntry, "245")},
+			{Line: 2, Message: errMsgMARCNoISBN},
+		}, recordErrors)
+	})
+
+	t.Run("MARCXML", func(t *testing.T) {
+		source := `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000nam a2200000 i 4500</leader>
+    <controlfield tag="008">040302s2004    nyu           000 1 eng d</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">0140449175 (pbk.)</subfield></datafield>
+    <datafield tag="100" ind1="1" ind2=" "><subfield code="a">Tolstoy, Leo,</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="0"><subfield code="a">Anna Karenina /</subfield></datafield>
+    <datafield tag="250" ind1=" " ind2=" "><subfield code="a">Penguin Classics ed.</subfield></datafield>
+    <datafield tag="260" ind1=" " ind2=" "><subfield code="b">Penguin Books,</subfield></datafield>
+    <datafield tag="520" ind1=" " ind2=" "><subfield code="a">A novel of love and society.</subfield></datafield>
+    <datafield tag="700" ind1="1" ind2=" "><subfield code="a">Pevear, Richard,</subfield></datafield>
+  </record>
+  <record>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">9780140449174</subfield></datafield>
+  </record>
+</collection>`
+
+		records, recordErrors := MARCXMLParser{}.Parse(strings.NewReader(source))
+		assert.Equal(t, []dto.ImportRecord{annaKarenina}, records)
+		assert.Equal(t, []dto.ImportRecordError{{Line: 2, Message: errMsgMARCNoTitle}}, recordErrors)
+	})
+
+	t.Run("ONIX", func(t *testing.T) {
+		source := `<?xml version="1.0" encoding="UTF-8"?>
+<ONIXMessage release="3.0" xmlns="http://ns.editeur.org/onix/3.0/reference">
+  <Header>
+    <Sender><SenderName>Penguin Distribution</SenderName></Sender>
+    <MessageNumber>231</MessageNumber>
+    <SentDateTime>20240315T0930Z</SentDateTime>
+  </Header>
+  <Product>
+    <RecordReference>com.penguin.9780140449174</RecordReference>
+    <NotificationType>03</NotificationType>
+    <ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>978-0-14-044917-4</IDValue></ProductIdentifier>
+    <ProductIdentifier><ProductIDType>02</ProductIDType><IDValue>0140449175</IDValue></ProductIdentifier>
+    <DescriptiveDetail>
+      <TitleDetail>
+        <TitleType>01</TitleType>
+        <TitleElement><TitleElementLevel>01</TitleElementLevel><TitleText>Anna Karenina</TitleText></TitleElement>
+      </TitleDetail>
+      <Contributor>
+        <SequenceNumber>2</SequenceNumber><ContributorRole>B06</ContributorRole><PersonName>Richard Pevear</PersonName>
+      </Contributor>
+      <Contributor>
+        <SequenceNumber>1</SequenceNumber><ContributorRole>A01</ContributorRole>
+        <NamesBeforeKey>Leo</NamesBeforeKey><KeyNames>Tolstoy</KeyNames>
+      </Contributor>
+      <EditionStatement>Penguin Classics ed</EditionStatement>
+      <Language><LanguageRole>01</LanguageRole><LanguageCode>eng</LanguageCode></Language>
+    </DescriptiveDetail>
+    <CollateralDetail>
+      <TextContent>
+        <TextType>03</TextType>
+        <Text textformat="05"><p xmlns="http://www.w3.org/1999/xhtml">A novel of <em>love</em> &amp; society.</p></Text>
+      </TextContent>
+    </CollateralDetail>
+    <PublishingDetail>
+      <Publisher><PublishingRole>01</PublishingRole><PublisherName>Penguin Books</PublisherName></Publisher>
+      <PublishingDate><PublishingDateRole>01</PublishingDateRole><Date>20040527</Date></PublishingDate>
+    </PublishingDetail>
+    <ProductSupply>
+      <SupplyDetail><Stock><OnHand>2</OnHand></Stock></SupplyDetail>
+      <SupplyDetail><Stock><OnHand>1</OnHand></Stock></SupplyDetail>
+    </ProductSupply>
+  </Product>
+  <Product>
+    <RecordReference>com.penguin.9780140447934</RecordReference>
+    <NotificationType>05</NotificationType>
+  </Product>
+  <Product>
+    <RecordReference>com.penguin.9780140449174</RecordReference>
+    <NotificationType>04</NotificationType>
+    <ProductSupply><SupplyDetail><Stock><OnHand>5</OnHand></Stock></SupplyDetail></ProductSupply>
+  </Product>
+  <Product>
+    <NotificationType>03</NotificationType>
+  </Product>
+</ONIXMessage>`
+
+		sentAt := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+		source03 := dto.ImportSource{
+			Sender: "Penguin Distribution", MessageID: "231", SentAt: sentAt,
+			RecordReference: "com.penguin.9780140449174", NotificationType: "03",
+		}
+		source05 := source03
+		source05.RecordReference, source05.NotificationType = "com.penguin.9780140447934", "05"
+		source04 := source03
+		source04.NotificationType = "04"
+		stockOnHand := 5
+
+		onixRecord := annaKarenina
+		onixRecord.Authors = []string{"Leo Tolstoy"}
+		onixRecord.Description = "A novel of love & society."
+		onixRecord.TotalCopies = &copies
+		onixRecord.Source = &source03
+
+		records, recordErrors := ONIXParser{}.Parse(strings.NewReader(source))
+		assert.Equal(t, []dto.ImportRecord{
+			onixRecord,
+			{Line: 2, Delete: true, Source: &source05},
+			{Line: 3, Authors: []string{}, TotalCopies: &stockOnHand, Source: &source04}, // A block update of the stock only
+		}, records)
+		assert.Equal(t, []dto.ImportRecordError{{Line: 4, Message: errMsgONIXNoReference}}, recordErrors)
+	})
+
+	t.Run("Unsupported format", func(t *testing.T) {
+		_, err := NewParser("epub", nil)
+		assert.EqualError(t, err, "unsupported import format 'epub'")
+	})
+}
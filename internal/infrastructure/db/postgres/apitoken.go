@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	errMsgAPITokenNameTaken = "a token with this name already exists"
+	errMsgAPITokenNotFound  = "token not found"
+	errMsgAPITokenInvalid   = "invalid or expired token"
+)
+
+const apiTokenDetailColumns = " uuid, name, scopes, created_at, expires_at, last_used_at "
+
+func apiTokenDetailScanTargets(tokenDetail *dto.APITokenDetail) []any {
+	return []any{
+		&tokenDetail.UUID, &tokenDetail.Name, &tokenDetail.Scopes,
+		&tokenDetail.CreatedAt, &tokenDetail.ExpiresAt, &tokenDetail.LastUsedAt,
+	}
+}
+
+type APITokenRepository struct {
+	dbpool *pgxpool.Pool
+}
+
+func NewAPITokenRepository(dbpool *pgxpool.Pool) repository.APITokenRepository {
+	return &APITokenRepository{dbpool}
+}
+
+func (ar APITokenRepository) CreateAPIToken(requestID string, userID int64, tokenRequest dto.APITokenCreateRequest,
+	tokenHash string,
+) (*dto.APITokenDetail, *apperrors.RestErr) {
+	tokenDetail := &dto.APITokenDetail{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	const queryInsertToken = `
+		INSERT INTO personalaccesstokens (uuid, user_id, name, token_hash, scopes, expires_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4,
+			CASE WHEN $5::integer > 0 THEN NOW() + make_interval(days => $5::integer) END)
+		RETURNING` + apiTokenDetailColumns
+	err := ar.dbpool.QueryRow(ctx, queryInsertToken,
+		userID, tokenRequest.Name, tokenHash, tokenRequest.Scopes, tokenRequest.ExpiresInDays,
+	).Scan(apiTokenDetailScanTargets(tokenDetail)...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, apperrors.NewConflictError(errMsgAPITokenNameTaken)
+		}
+
+		log.Error().Err(err).Msg("failed to insert personal access token")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return tokenDetail, nil
+}
+
+func (ar APITokenRepository) GetAPITokens(requestID string, userID int64) ([]dto.APITokenDetail, *apperrors.RestErr) {
+	tokens := []dto.APITokenDetail{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	// Expired tokens are listed too, so that the user can tell why a script stopped working.
+	const queryGetTokens = "SELECT" + apiTokenDetailColumns + `
+		FROM personalaccesstokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC, uuid
+	`
+	rows, err := ar.dbpool.Query(ctx, queryGetTokens, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query personal access tokens")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tokenDetail dto.APITokenDetail
+		if err := rows.Scan(apiTokenDetailScanTargets(&tokenDetail)...); err != nil {
+			log.Error().Err(err).Msg("failed to scan personal access token")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		tokens = append(tokens, tokenDetail)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed to iterate personal access tokens")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return tokens, nil
+}
+
+func (ar APITokenRepository) RevokeAPIToken(requestID string, userID int64, tokenUUID uuid.UUID) *apperrors.RestErr {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	const queryRevokeToken = `
+		UPDATE personalaccesstokens SET revoked_at = NOW()
+		WHERE uuid = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+	commandTag, err := ar.dbpool.Exec(ctx, queryRevokeToken, tokenUUID, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to revoke personal access token")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return apperrors.NewNotFoundError(errMsgAPITokenNotFound)
+	}
+
+	return nil
+}
+
+func (ar APITokenRepository) GetAPITokenByHash(tokenHash string) (*entity.APIToken, *apperrors.RestErr) {
+	apiToken := &entity.APIToken{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// last_used_at is written at most once a minute, so that a busy script does not update the row on every request.
+	const queryGetToken = `
+		WITH token AS (
+			SELECT uuid, user_id, scopes, last_used_at FROM personalaccesstokens
+			WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		), used AS (
+			UPDATE personalaccesstokens p SET last_used_at = NOW()
+			FROM token
+			WHERE p.uuid = token.uuid AND (token.last_used_at IS NULL OR token.last_used_at < NOW() - interval '1 minute')
+		)
+		SELECT uuid::text, user_id, scopes FROM token
+	`
+	err := ar.dbpool.QueryRow(ctx, queryGetToken, tokenHash).Scan(&apiToken.UUID, &apiToken.UserID, &apiToken.Scopes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.NewUnauthorizedError(errMsgAPITokenInvalid)
+		}
+
+		log.Error().Err(err).Msg("failed to query personal access token")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return apiToken, nil
+}
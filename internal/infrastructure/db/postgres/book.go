@@ -3,17 +3,55 @@ package postgres
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/DarrelA/e-lib/internal/apperrors"
 	"github.com/DarrelA/e-lib/internal/application/dto"
 	"github.com/DarrelA/e-lib/internal/domain/entity"
 	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	errMsgInvalidSortColumn = "invalid sort column"
+	errMsgInvalidCursor     = "invalid cursor"
+	errMsgInvalidSearchMode = "invalid search mode"
+
+	errMsgBookNotFound            = "book not found"
+	errMsgAmbiguousTitle          = "%d editions share this title; use the uuid or isbn of the book"
+	errMsgISBNAlreadyExists       = "a book with this ISBN already exists"
+	errMsgNotEnoughCopiesToRemove = "cannot remove more copies than the %d available"
+	errMsgBookHasActiveLoans      = "book has %d active loan(s); withdraw with force=true to close them"
+)
+
+// bookDetailColumns selects a dto.BookDetail from `books b`, see bookDetailScanTargets for the matching scan targets.
+const bookDetailColumns = `
+	b.uuid, b.isbn_13, COALESCE(b.isbn_10, ''), b.title,
+	COALESCE((
+		SELECT array_agg(a.name ORDER BY ba.position)
+		FROM BookAuthors ba JOIN Authors a ON a.id = ba.author_id
+		WHERE ba.book_uuid = b.uuid
+	), '{}'),
+	b.edition, b.publisher, b.language, COALESCE(b.publication_year, 0), b.description, b.total_copies, b.available_copies`
+
+func bookDetailScanTargets(bookDetail *dto.BookDetail) []any {
+	return []any{
+		&bookDetail.UUID, &bookDetail.ISBN13, &bookDetail.ISBN10, &bookDetail.Title,
+		&bookDetail.Authors,
+		&bookDetail.Edition, &bookDetail.Publisher, &bookDetail.Language, &bookDetail.PublicationYear,
+		&bookDetail.Description, &bookDetail.TotalCopies, &bookDetail.AvailableCopies,
+	}
+}
+
 type BookRepository struct {
 	dbpool *pgxpool.Pool
 }
@@ -23,29 +61,657 @@ func NewBookRepository(dbpool *pgxpool.Pool) repository.BookRepository {
 }
 
 func (br BookRepository) GetBook(requestID string, title string) (*dto.BookDetail, *apperrors.RestErr) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	// Titles are not unique, so a title shared by several editions is refused rather than guessed.
+	const queryGetBook = "SELECT" + bookDetailColumns + ", COUNT(*) OVER () FROM books b WHERE lower(b.title)=lower($1) AND b.withdrawn_at IS NULL ORDER BY b.created_at, b.uuid LIMIT 1;"
 	bookDetail := &dto.BookDetail{}
+	var editions int
+	err := br.dbpool.QueryRow(ctx, queryGetBook, title).Scan(append(bookDetailScanTargets(bookDetail), &editions)...)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError(errMsgBookNotFound)
+		}
+
+		log.Error().Err(err).Msg("")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	if editions > 1 {
+		return nil, apperrors.NewConflictError(fmt.Sprintf(errMsgAmbiguousTitle, editions))
+	}
+
+	return bookDetail, nil
+}
+
+// bookCursor is the opaque keyset position handed to clients as `next_cursor` and `prev_cursor`.
+// It is bound to the sort column and order so that a cursor cannot be replayed against a different listing.
+type bookCursor struct {
+	SortBy    string    `json:"s"`
+	Order     string    `json:"o"`
+	SortKey   string    `json:"k"`
+	UUID      uuid.UUID `json:"u"`
+	Backwards bool      `json:"b,omitempty"`
+}
+
+// Whitelist of sortable columns and the cast used to compare the cursor's sort key against them.
+var bookSortColumns = map[string]string{
+	"title":            "text",
+	"created_at":       "timestamptz",
+	"available_copies": "integer",
+}
+
+func encodeBookCursor(cursor bookCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeBookCursor(encoded string) (*bookCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := &bookCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func (br BookRepository) ListBooks(requestID string, params dto.BookListRequest) (*dto.BookListResponse, *apperrors.RestErr) {
+	castType, ok := bookSortColumns[params.SortBy]
+	if !ok {
+		return nil, apperrors.NewBadRequestError(errMsgInvalidSortColumn)
+	}
+
+	var cursor *bookCursor
+	if params.Cursor != "" {
+		var err error
+		cursor, err = decodeBookCursor(params.Cursor)
+		if err != nil || cursor.SortBy != params.SortBy || cursor.Order != params.Order {
+			return nil, apperrors.NewBadRequestError(errMsgInvalidCursor)
+		}
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
 
-	const queryGetBook = "SELECT uuid, title, available_copies FROM books WHERE title=$1;"
-	err := br.dbpool.QueryRow(ctx, queryGetBook, title).
-		Scan(&bookDetail.UUID, &bookDetail.Title, &bookDetail.AvailableCopies)
+	// Paging backwards walks the index in the opposite direction and the page is reversed afterwards.
+	descending := params.Order == "desc"
+	backwards := cursor != nil && cursor.Backwards
+	if backwards {
+		descending = !descending
+	}
+
+	comparison, direction := ">", "ASC"
+	if descending {
+		comparison, direction = "<", "DESC"
+	}
+
+	args := []any{}
+	conditions := []string{"b.withdrawn_at IS NULL"}
+	if params.Available {
+		conditions = append(conditions, "b.available_copies > 0")
+	}
+	if cursor != nil {
+		args = append(args, cursor.SortKey, cursor.UUID)
+		conditions = append(conditions, fmt.Sprintf("(b.%s, b.uuid) %s ($1::%s, $2)", params.SortBy, comparison, castType))
+	}
+
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	// Fetch one extra row to find out whether another page exists in the direction of travel.
+	args = append(args, params.Limit+1)
+	queryListBooks := fmt.Sprintf(`
+		SELECT %[1]s, b.%[2]s::text
+		FROM books b
+		%[3]s
+		ORDER BY b.%[2]s %[4]s, b.uuid %[4]s
+		LIMIT $%[5]d
+	`, bookDetailColumns, params.SortBy, where, direction, len(args))
 
+	rows, err := br.dbpool.Query(ctx, queryListBooks, args...)
 	if err != nil {
-		if err == context.DeadlineExceeded {
+		if errors.Is(err, context.DeadlineExceeded) {
 			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
 			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
 		}
 
-		if err == pgx.ErrNoRows {
-			return nil, apperrors.NewBadRequestError(err.Error())
+		log.Error().Err(err).Msg("failed to list books")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	defer rows.Close()
+
+	books := []dto.BookDetail{}
+	sortKeys := []string{}
+	for rows.Next() {
+		var bookDetail dto.BookDetail
+		var sortKey string
+		if err := rows.Scan(append(bookDetailScanTargets(&bookDetail), &sortKey)...); err != nil {
+			log.Error().Err(err).Msg("failed to scan book")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		books = append(books, bookDetail)
+		sortKeys = append(sortKeys, sortKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed to iterate books")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	hasMore := len(books) > params.Limit
+	if hasMore {
+		books = books[:params.Limit]
+		sortKeys = sortKeys[:params.Limit]
+	}
+
+	if backwards {
+		slices.Reverse(books)
+		slices.Reverse(sortKeys)
+	}
+
+	bookListResponse := &dto.BookListResponse{Books: books}
+	if len(books) == 0 {
+		return bookListResponse, nil
+	}
+
+	newCursor := func(i int, backwards bool) string {
+		return encodeBookCursor(bookCursor{
+			SortBy:    params.SortBy,
+			Order:     params.Order,
+			SortKey:   sortKeys[i],
+			UUID:      books[i].UUID,
+			Backwards: backwards,
+		})
+	}
+
+	last := len(books) - 1
+	if backwards {
+		// Coming from a later page, so there is always a next page; a previous one only if more rows were found.
+		bookListResponse.NextCursor = newCursor(last, false)
+		if hasMore {
+			bookListResponse.PrevCursor = newCursor(0, true)
+		}
+	} else {
+		if hasMore {
+			bookListResponse.NextCursor = newCursor(last, false)
+		}
+		if cursor != nil {
+			bookListResponse.PrevCursor = newCursor(0, true)
+		}
+	}
+
+	return bookListResponse, nil
+}
+
+// Escapes the LIKE wildcards so that user input is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Title filters per search mode. `%` and `<%` are the pg_trgm similarity and word similarity operators.
+var bookSearchFilters = map[string]string{
+	"prefix":    "title LIKE $2 || '%'",
+	"substring": "title LIKE '%' || $2 || '%'",
+	"fuzzy":     "(title LIKE '%' || $2 || '%' OR title % $1 OR $1 <% title)",
+}
+
+func (br BookRepository) SearchBooks(requestID string, params dto.BookSearchRequest) ([]dto.BookSearchResult, *apperrors.RestErr) {
+	filter, ok := bookSearchFilters[params.Mode]
+	if !ok {
+		return nil, apperrors.NewBadRequestError(errMsgInvalidSearchMode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	// Exact matches rank first, then prefix and substring matches, and finally trigram similarity.
+	querySearchBooks := fmt.Sprintf(`
+		SELECT %s, ranked.score
+		FROM (
+			SELECT uuid, title,
+				CASE
+					WHEN title = $1 THEN 1.0
+					WHEN title LIKE $2 || '%%' THEN 0.9
+					WHEN title LIKE '%%' || $2 || '%%' THEN 0.6 + 0.3 * similarity(title, $1)
+					ELSE GREATEST(similarity(title, $1), word_similarity($1, title)) * 0.6
+				END::float8 AS score
+			FROM books
+			WHERE withdrawn_at IS NULL AND %s
+			ORDER BY score DESC, title
+			LIMIT $3
+		) ranked
+		JOIN books b ON b.uuid = ranked.uuid
+		ORDER BY ranked.score DESC, ranked.title
+	`, bookDetailColumns, filter)
+
+	rows, err := br.dbpool.Query(ctx, querySearchBooks, params.Title, likeEscaper.Replace(params.Title), params.Limit)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		log.Error().Err(err).Msg("failed to search books")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	defer rows.Close()
+
+	bookSearchResults := []dto.BookSearchResult{}
+	for rows.Next() {
+		var result dto.BookSearchResult
+		if err := rows.Scan(append(bookDetailScanTargets(&result.BookDetail), &result.Score)...); err != nil {
+			log.Error().Err(err).Msg("failed to scan book")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		bookSearchResults = append(bookSearchResults, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed to iterate books")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return bookSearchResults, nil
+}
+
+// saveBookAuthors replaces the authors of a book, creating the authors that do not exist yet.
+func saveBookAuthors(ctx context.Context, tx pgx.Tx, bookUUID uuid.UUID, authors []string) error {
+	const execDeleteBookAuthors = "DELETE FROM BookAuthors WHERE book_uuid = $1"
+	if _, err := tx.Exec(ctx, execDeleteBookAuthors, bookUUID); err != nil {
+		return fmt.Errorf("error deleting authors of book '%s': %w", bookUUID, err)
+	}
+
+	// DO UPDATE instead of DO NOTHING so that RETURNING yields the id of an existing author too.
+	const queryUpsertAuthor = `
+		INSERT INTO Authors (name, created_at) VALUES ($1, NOW())
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`
+	const execInsertBookAuthor = `
+		INSERT INTO BookAuthors (book_uuid, author_id, position) VALUES ($1, $2, $3)
+		ON CONFLICT (book_uuid, author_id) DO NOTHING
+	`
+	for position, author := range authors {
+		author = strings.TrimSpace(author)
+		if author == "" {
+			continue
+		}
+
+		var authorID int64
+		if err := tx.QueryRow(ctx, queryUpsertAuthor, author).Scan(&authorID); err != nil {
+			return fmt.Errorf("error saving author '%s': %w", author, err)
+		}
+
+		if _, err := tx.Exec(ctx, execInsertBookAuthor, bookUUID, authorID, position); err != nil {
+			return fmt.Errorf("error linking author '%s' to book '%s': %w", author, bookUUID, err)
+		}
+	}
+
+	return nil
+}
+
+func (br BookRepository) GetBookByUUID(requestID string, bookUUID uuid.UUID) (*dto.BookDetail, *apperrors.RestErr) {
+	bookDetail := &dto.BookDetail{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	const queryGetBookByUUID = "SELECT" + bookDetailColumns + " FROM books b WHERE b.uuid=$1 AND b.withdrawn_at IS NULL;"
+	err := br.dbpool.QueryRow(ctx, queryGetBookByUUID, bookUUID).Scan(bookDetailScanTargets(bookDetail)...)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError(errMsgBookNotFound)
+		}
+
+		log.Error().Err(err).Msg("")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return bookDetail, nil
+}
+
+// GetBookByISBN looks up a book by its ISBN-13 or ISBN-10, without hyphens.
+func (br BookRepository) GetBookByISBN(requestID string, isbn string) (*dto.BookDetail, *apperrors.RestErr) {
+	bookDetail := &dto.BookDetail{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	const queryGetBookByISBN = "SELECT" + bookDetailColumns + " FROM books b WHERE (b.isbn_13=$1 OR b.isbn_10=$1) AND b.withdrawn_at IS NULL LIMIT 1;"
+	err := br.dbpool.QueryRow(ctx, queryGetBookByISBN, isbn).Scan(bookDetailScanTargets(bookDetail)...)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError(errMsgBookNotFound)
 		}
 
 		log.Error().Err(err).Msg("")
-		return nil, apperrors.NewInternalServerError(err.Error())
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
 	}
 
 	return bookDetail, nil
 }
+
+func (br BookRepository) CreateBook(requestID string, book dto.BookCreateRequest) (*dto.BookDetail, *apperrors.RestErr) {
+	var bookUUID uuid.UUID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	restErr := runInTransaction(ctx, br.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		const queryInsertBook = `
+			INSERT INTO Books (uuid, isbn_13, isbn_10, title, edition, publisher, language, publication_year,
+				description, total_copies, available_copies, created_at, updated_at)
+			VALUES (gen_random_uuid(), $1, NULLIF($2, ''), lower($3), $4, $5, COALESCE(NULLIF($6, ''), 'en'), NULLIF($7, 0),
+				$8, $9, $9, NOW(), NOW())
+			RETURNING uuid
+		`
+		err := tx.QueryRow(ctx, queryInsertBook,
+			book.ISBN13, book.ISBN10, book.Title, book.Edition, book.Publisher,
+			strings.ToLower(book.Language), book.PublicationYear, book.Description, book.TotalCopies).
+			Scan(&bookUUID)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return apperrors.NewConflictError(errMsgISBNAlreadyExists)
+			}
+
+			log.Error().Err(err).Msg("failed to insert book")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if err := saveBookAuthors(ctx, tx, bookUUID, book.Authors); err != nil {
+			log.Error().Err(err).Msg("")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		return nil
+	})
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	log.Info().Msgf("book '%s' (%s) has been added to the catalog", book.Title, bookUUID)
+	return br.GetBookByUUID(requestID, bookUUID)
+}
+
+func (br BookRepository) UpdateBook(requestID string, bookUUID uuid.UUID, book dto.BookUpdateRequest) (*dto.BookDetail, *apperrors.RestErr) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	args := []any{bookUUID}
+	assignments := []string{"updated_at = NOW()"}
+	set := func(column string, expression string, value any) {
+		args = append(args, value)
+		assignments = append(assignments, fmt.Sprintf("%s = %s", column, fmt.Sprintf(expression, len(args))))
+	}
+
+	if book.ISBN13 != nil {
+		set("isbn_13", "$%d", *book.ISBN13)
+	}
+	if book.ISBN10 != nil {
+		set("isbn_10", "NULLIF($%d, '')", *book.ISBN10)
+	}
+	if book.Title != nil {
+		set("title", "lower($%d)", *book.Title)
+	}
+	if book.Edition != nil {
+		set("edition", "$%d", *book.Edition)
+	}
+	if book.Publisher != nil {
+		set("publisher", "$%d", *book.Publisher)
+	}
+	if book.Language != nil {
+		set("language", "$%d", strings.ToLower(*book.Language))
+	}
+	if book.PublicationYear != nil {
+		set("publication_year", "NULLIF($%d, 0)", *book.PublicationYear)
+	}
+	if book.Description != nil {
+		set("description", "$%d", *book.Description)
+	}
+
+	restErr := runInTransaction(ctx, br.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		execUpdateBook := fmt.Sprintf("UPDATE books SET %s WHERE uuid = $1 AND withdrawn_at IS NULL",
+			strings.Join(assignments, ", "))
+		commandTag, err := tx.Exec(ctx, execUpdateBook, args...)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return apperrors.NewConflictError(errMsgISBNAlreadyExists)
+			}
+
+			log.Error().Err(err).Msg("failed to update book")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if commandTag.RowsAffected() == 0 {
+			return apperrors.NewNotFoundError(errMsgBookNotFound)
+		}
+
+		if book.Authors != nil {
+			if err := saveBookAuthors(ctx, tx, bookUUID, *book.Authors); err != nil {
+				log.Error().Err(err).Msg("")
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+		}
+		return nil
+	})
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	return br.GetBookByUUID(requestID, bookUUID)
+}
+
+func (br BookRepository) AdjustCopies(requestID string, bookUUID uuid.UUID, delta int) (*dto.BookDetail, *apperrors.RestErr) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	restErr := runInTransaction(ctx, br.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		var availableCopies int
+		const queryLockBook = "SELECT available_copies FROM books WHERE uuid = $1 AND withdrawn_at IS NULL FOR UPDATE"
+		err := tx.QueryRow(ctx, queryLockBook, bookUUID).Scan(&availableCopies)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return apperrors.NewNotFoundError(errMsgBookNotFound)
+			}
+
+			log.Error().Err(err).Msg("failed to lock book")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if availableCopies+delta < 0 {
+			return apperrors.NewConflictError(fmt.Sprintf(errMsgNotEnoughCopiesToRemove, availableCopies))
+		}
+
+		const execAdjustCopies = `
+			UPDATE books
+			SET total_copies = total_copies + $2, available_copies = available_copies + $2, updated_at = NOW()
+			WHERE uuid = $1
+		`
+		if _, err := tx.Exec(ctx, execAdjustCopies, bookUUID, delta); err != nil {
+			log.Error().Err(err).Msg("failed to adjust available copies")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if delta > 0 {
+			if _, err := offerAvailableCopies(ctx, tx, bookUUID); err != nil {
+				log.Error().Err(err).Msg("failed to offer the added copies")
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+		}
+		return nil
+	})
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	return br.GetBookByUUID(requestID, bookUUID)
+}
+
+func (br BookRepository) WithdrawBook(requestID string, bookUUID uuid.UUID, force bool) *apperrors.RestErr {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	return runInTransaction(ctx, br.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		var activeLoanCount int
+		// Locking the book keeps new loans from being created while the title is being withdrawn.
+		const queryLockBookAndCountLoans = `
+			SELECT (SELECT COUNT(*) FROM loans WHERE book_uuid = b.uuid AND is_returned = FALSE)
+			FROM books b
+			WHERE b.uuid = $1 AND b.withdrawn_at IS NULL
+			FOR UPDATE
+		`
+		err := tx.QueryRow(ctx, queryLockBookAndCountLoans, bookUUID).Scan(&activeLoanCount)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return apperrors.NewNotFoundError(errMsgBookNotFound)
+			}
+
+			log.Error().Err(err).Msg("failed to count active loans")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if activeLoanCount > 0 {
+			if !force {
+				return apperrors.NewConflictError(fmt.Sprintf(errMsgBookHasActiveLoans, activeLoanCount))
+			}
+
+			const execCloseActiveLoans = `
+				WITH closed AS (
+					UPDATE loans SET is_returned = TRUE, returned_at = NOW(), return_reason = 'withdrawn'
+					WHERE book_uuid = $1 AND is_returned = FALSE
+					RETURNING uuid, return_date
+				)
+				INSERT INTO loanevents (loan_uuid, kind, request_id, previous_return_date, return_date)
+				SELECT uuid, 'withdrawn', $2, return_date, return_date FROM closed
+			`
+			if _, err := tx.Exec(ctx, execCloseActiveLoans, bookUUID, requestID); err != nil {
+				log.Error().Err(err).Msg("failed to close active loans")
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+			log.Warn().Msgf("closed %d active loan(s) of book '%s' to withdraw it", activeLoanCount, bookUUID)
+		}
+
+		const execWithdrawBook = "UPDATE books SET withdrawn_at = NOW(), updated_at = NOW() WHERE uuid = $1"
+		if _, err := tx.Exec(ctx, execWithdrawBook, bookUUID); err != nil {
+			log.Error().Err(err).Msg("failed to withdraw book")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if err := cancelOpenHolds(ctx, tx, bookUUID); err != nil {
+			log.Error().Err(err).Msg("failed to cancel holds")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		return nil
+	})
+}
+
+func (br BookRepository) ReconcileCopies(requestID string, fix bool) ([]dto.CopiesDrift, *apperrors.RestErr) {
+	copiesDrifts := []dto.CopiesDrift{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	restErr := runInTransaction(ctx, br.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		// Locking the drifted books keeps loans from changing their counters until they are fixed.
+		const queryCopiesDrifts = `
+			SELECT b.uuid, b.isbn_13, b.title, b.total_copies, b.available_copies, loans.active_loans, offers.open_offers
+			FROM books b
+			CROSS JOIN LATERAL (
+				SELECT COUNT(*)::int AS active_loans FROM loans l WHERE l.book_uuid = b.uuid AND l.is_returned = FALSE
+			) loans
+			CROSS JOIN LATERAL (
+				SELECT COUNT(*)::int AS open_offers FROM holds h WHERE h.book_uuid = b.uuid AND h.status = 'offered'
+			) offers
+			WHERE b.withdrawn_at IS NULL
+				AND (b.available_copies <> GREATEST(b.total_copies - loans.active_loans - offers.open_offers, 0)
+					OR b.total_copies < loans.active_loans + offers.open_offers)
+			ORDER BY b.title, b.uuid
+			FOR UPDATE OF b
+		`
+		rows, err := tx.Query(ctx, queryCopiesDrifts)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to query copies drift")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		for rows.Next() {
+			var drift dto.CopiesDrift
+			err := rows.Scan(&drift.UUID, &drift.ISBN13, &drift.Title, &drift.TotalCopies, &drift.AvailableCopies,
+				&drift.ActiveLoans, &drift.OpenOffers)
+			if err != nil {
+				rows.Close()
+				log.Error().Err(err).Msg("failed to scan copies drift")
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+
+			drift.ExpectedAvailable = max(drift.TotalCopies-drift.ActiveLoans-drift.OpenOffers, 0)
+			drift.OverLent = drift.TotalCopies < drift.ActiveLoans+drift.OpenOffers
+			copiesDrifts = append(copiesDrifts, drift)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			log.Error().Err(err).Msg("failed to iterate copies drift")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if !fix {
+			return nil
+		}
+
+		const execFixAvailableCopies = "UPDATE books SET available_copies = $2, updated_at = NOW() WHERE uuid = $1"
+		for i, drift := range copiesDrifts {
+			if drift.AvailableCopies == drift.ExpectedAvailable {
+				continue // Over-lent with nothing on the shelf
+			}
+
+			if _, err := tx.Exec(ctx, execFixAvailableCopies, drift.UUID, drift.ExpectedAvailable); err != nil {
+				log.Error().Err(err).Msgf("failed to fix available copies of book '%s'", drift.UUID)
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+			copiesDrifts[i].Fixed = true
+
+			// Restored copies go to the hold queue first, as returned copies do.
+			if drift.ExpectedAvailable > drift.AvailableCopies {
+				offeredHolds, err := offerAvailableCopies(ctx, tx, drift.UUID)
+				if err != nil {
+					log.Error().Err(err).Msgf("failed to offer the restored copies of book '%s'", drift.UUID)
+					return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+				}
+				copiesDrifts[i].OfferedHolds = offeredHolds
+			}
+		}
+		return nil
+	})
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	log.Info().Msgf("found %d book(s) with drifted available copies", len(copiesDrifts))
+	return copiesDrifts, nil
+}
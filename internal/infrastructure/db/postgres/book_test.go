@@ -0,0 +1,105 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// findCopiesDrift reconciles the copies and returns the drift of the book, or nil when it has none.
+func findCopiesDrift(t *testing.T, bookRepository BookRepository, bookUUID uuid.UUID, fix bool) *dto.CopiesDrift {
+	copiesDrifts, restErr := bookRepository.ReconcileCopies("stress", fix)
+	require.Nil(t, restErr)
+	for _, drift := range copiesDrifts {
+		if drift.UUID == bookUUID {
+			return &drift
+		}
+	}
+	return nil
+}
+
+func TestReconcileCopies(t *testing.T) {
+	dbpool := connectStressDB(t)
+	bookRepository := BookRepository{dbpool}
+	loanRepository := LoanRepository{dbpool}
+	holdRepository := HoldRepository{dbpool}
+
+	bookDetail := createStressBook(t, bookRepository, 3)
+	userDetails := createStressUsers(t, dbpool, 4)
+
+	setAvailableCopies := func(copies int) {
+		const execSetAvailableCopies = "UPDATE books SET available_copies = $2 WHERE uuid = $1"
+		_, err := dbpool.Exec(context.Background(), execSetAvailableCopies, bookDetail.UUID, copies)
+		require.NoError(t, err)
+	}
+	getAvailableCopies := func() int {
+		stockedBook, restErr := bookRepository.GetBookByUUID("stress", bookDetail.UUID)
+		require.Nil(t, restErr)
+		return stockedBook.AvailableCopies
+	}
+
+	for _, userDetail := range userDetails[:2] {
+		_, restErr := loanRepository.BorrowBook("stress", userDetail, bookDetail)
+		require.Nil(t, restErr)
+	}
+	assert.Nil(t, findCopiesDrift(t, bookRepository, bookDetail.UUID, false))
+
+	// A lost copy leaves the book looking unavailable, so the next patrons place holds.
+	setAvailableCopies(0)
+	for _, userDetail := range userDetails[2:] {
+		_, restErr := holdRepository.PlaceHold("stress", userDetail.ID, bookDetail)
+		require.Nil(t, restErr)
+	}
+
+	t.Run("Report the copies missing from the shelf without fixing them", func(t *testing.T) {
+		drift := findCopiesDrift(t, bookRepository, bookDetail.UUID, false)
+		require.NotNil(t, drift)
+		assert.Equal(t, dto.CopiesDrift{
+			UUID: bookDetail.UUID, ISBN13: bookDetail.ISBN13, Title: bookDetail.Title,
+			TotalCopies: 3, AvailableCopies: 0, ActiveLoans: 2, OpenOffers: 0, ExpectedAvailable: 1,
+		}, *drift)
+		assert.Equal(t, 0, getAvailableCopies())
+	})
+
+	t.Run("Fix restores the missing copy and offers it to the first hold", func(t *testing.T) {
+		drift := findCopiesDrift(t, bookRepository, bookDetail.UUID, true)
+		require.NotNil(t, drift)
+		assert.Equal(t, 1, drift.ExpectedAvailable)
+		assert.True(t, drift.Fixed)
+		assert.Equal(t, 1, drift.OfferedHolds)
+
+		// The restored copy is reserved for the offer, so none is left on the shelf.
+		assert.Equal(t, 0, getAvailableCopies())
+		holdDetail, restErr := holdRepository.GetHold("stress", userDetails[2].ID, bookDetail.UUID)
+		require.Nil(t, restErr)
+		assert.Equal(t, "offered", holdDetail.Status)
+		holdDetail, restErr = holdRepository.GetHold("stress", userDetails[3].ID, bookDetail.UUID)
+		require.Nil(t, restErr)
+		assert.Equal(t, "waiting", holdDetail.Status)
+		assertNoCopiesDrift(t, bookRepository, bookDetail.UUID)
+	})
+
+	t.Run("Offered holds count against the available copies", func(t *testing.T) {
+		setAvailableCopies(2)
+
+		drift := findCopiesDrift(t, bookRepository, bookDetail.UUID, false)
+		require.NotNil(t, drift)
+		assert.Equal(t, 2, drift.ActiveLoans)
+		assert.Equal(t, 1, drift.OpenOffers)
+		assert.Equal(t, 0, drift.ExpectedAvailable)
+		assert.False(t, drift.OverLent)
+
+		drift = findCopiesDrift(t, bookRepository, bookDetail.UUID, true)
+		require.NotNil(t, drift)
+		assert.True(t, drift.Fixed)
+		assert.Equal(t, 0, drift.OfferedHolds)
+		assert.Equal(t, 0, getAvailableCopies())
+		assertNoCopiesDrift(t, bookRepository, bookDetail.UUID)
+	})
+}
@@ -2,11 +2,15 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/DarrelA/e-lib/internal/apperrors"
 	"github.com/DarrelA/e-lib/internal/domain/entity"
 	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 )
@@ -19,6 +23,8 @@ const (
 
 	infoMsgRollbackTransactionSuccess  = "transaction rollback successfully"
 	infoMsgCommittedTransactionSuccess = "transaction committed successfully"
+
+	pgErrCodeUniqueViolation = "23505"
 )
 
 /*
@@ -79,3 +85,34 @@ func (p *PostgresDB) Disconnect() {
 		log.Info().Msg("PostgreSQL database connection closed")
 	}
 }
+
+// runInTransaction commits when txFunc succeeds and rolls back when it returns an error.
+func runInTransaction(ctx context.Context, dbpool *pgxpool.Pool, txFunc func(tx pgx.Tx) *apperrors.RestErr) *apperrors.RestErr {
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg(errMsgFailedToBeginTransaction)
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	if restErr := txFunc(tx); restErr != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+		} else {
+			log.Info().Msg(infoMsgRollbackTransactionSuccess)
+		}
+		return restErr
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg(errMsgFailedToCommitTransaction)
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	log.Info().Msg(infoMsgCommittedTransactionSuccess)
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgErrCodeUniqueViolation
+}
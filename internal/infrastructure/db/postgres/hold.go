@@ -0,0 +1,355 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	errMsgBookAvailable     = "book has copies available; borrow it instead"
+	errMsgHoldOnLoanedBook  = "user has already borrowed this book"
+	errMsgHoldAlreadyPlaced = "user already has a hold on this book"
+	errMsgNoOpenHold        = "no hold found for this user and book"
+)
+
+// The position in the queue counts the waiting holds placed before the hold, itself included.
+const holdDetailColumns = `
+	h.uuid, h.book_uuid, b.title, h.status, h.placed_at, h.offer_expires_at,
+	CASE WHEN h.status = 'waiting' THEN (
+		SELECT COUNT(*) FROM holds q
+		WHERE q.book_uuid = h.book_uuid AND q.status = 'waiting' AND (q.placed_at, q.uuid) <= (h.placed_at, h.uuid)
+	) ELSE 0 END
+`
+
+func holdDetailScanTargets(holdDetail *dto.HoldDetail) []any {
+	return []any{
+		&holdDetail.UUID, &holdDetail.BookUUID, &holdDetail.BookTitle, &holdDetail.Status,
+		&holdDetail.PlacedAt, &holdDetail.OfferExpiresAt, &holdDetail.Position,
+	}
+}
+
+type HoldRepository struct {
+	dbpool *pgxpool.Pool
+}
+
+func NewHoldRepository(dbpool *pgxpool.Pool) repository.HoldRepository {
+	return &HoldRepository{dbpool}
+}
+
+func (hr HoldRepository) PlaceHold(requestID string, userID int64, bookDetail *dto.BookDetail) (*dto.HoldDetail, *apperrors.RestErr) {
+	holdDetail := &dto.HoldDetail{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	restErr := runInTransaction(ctx, hr.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		var availableCopies, activeLoanCount int
+		// Locking the book keeps a returned copy from being offered before the hold joins the queue.
+		const queryLockBook = `
+			SELECT b.available_copies,
+				(SELECT COUNT(*) FROM loans WHERE user_id = $2 AND book_uuid = b.uuid AND is_returned = FALSE)
+			FROM books b
+			WHERE b.uuid = $1 AND b.withdrawn_at IS NULL
+			FOR UPDATE
+		`
+		err := tx.QueryRow(ctx, queryLockBook, bookDetail.UUID, userID).Scan(&availableCopies, &activeLoanCount)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return apperrors.NewNotFoundError(errMsgBookNotFound)
+			}
+
+			log.Error().Err(err).Msg("failed to lock book")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if activeLoanCount > 0 {
+			return apperrors.NewConflictError(errMsgHoldOnLoanedBook)
+		}
+
+		if availableCopies > 0 {
+			return apperrors.NewConflictError(errMsgBookAvailable)
+		}
+
+		var holdUUID uuid.UUID
+		const queryInsertHold = "INSERT INTO holds (uuid, user_id, book_uuid) VALUES (gen_random_uuid(), $1, $2) RETURNING uuid"
+		if err := tx.QueryRow(ctx, queryInsertHold, userID, bookDetail.UUID).Scan(&holdUUID); err != nil {
+			if isUniqueViolation(err) {
+				return apperrors.NewConflictError(errMsgHoldAlreadyPlaced)
+			}
+
+			log.Error().Err(err).Msg("failed to insert hold")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		const queryGetHold = "SELECT" + holdDetailColumns + "FROM holds h JOIN books b ON b.uuid = h.book_uuid WHERE h.uuid = $1"
+		if err := tx.QueryRow(ctx, queryGetHold, holdUUID).Scan(holdDetailScanTargets(holdDetail)...); err != nil {
+			log.Error().Err(err).Msg("failed to query hold")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		return nil
+	})
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	return holdDetail, nil
+}
+
+func (hr HoldRepository) CancelHold(requestID string, userID int64, bookUUID uuid.UUID) *apperrors.RestErr {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	return runInTransaction(ctx, hr.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		var previousStatus string
+		const queryCancelHold = `
+			WITH open_hold AS (
+				SELECT uuid, status FROM holds
+				WHERE user_id = $1 AND book_uuid = $2 AND status IN ('waiting', 'offered')
+				FOR UPDATE
+			)
+			UPDATE holds h SET status = 'cancelled', closed_at = NOW()
+			FROM open_hold
+			WHERE h.uuid = open_hold.uuid
+			RETURNING open_hold.status
+		`
+		err := tx.QueryRow(ctx, queryCancelHold, userID, bookUUID).Scan(&previousStatus)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return apperrors.NewNotFoundError(errMsgNoOpenHold)
+			}
+
+			log.Error().Err(err).Msg("failed to cancel hold")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if previousStatus == dto.HoldStatusOffered {
+			if err := releaseCopies(ctx, tx, bookUUID, 1); err != nil {
+				log.Error().Err(err).Msg("failed to pass on the offered copy")
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+		}
+		return nil
+	})
+}
+
+func (hr HoldRepository) GetHold(requestID string, userID int64, bookUUID uuid.UUID) (*dto.HoldDetail, *apperrors.RestErr) {
+	holdDetail := &dto.HoldDetail{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	const queryGetHold = "SELECT" + holdDetailColumns + `
+		FROM holds h JOIN books b ON b.uuid = h.book_uuid
+		WHERE h.user_id = $1 AND h.book_uuid = $2 AND h.status IN ('waiting', 'offered')
+	`
+	err := hr.dbpool.QueryRow(ctx, queryGetHold, userID, bookUUID).Scan(holdDetailScanTargets(holdDetail)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError(errMsgNoOpenHold)
+		}
+
+		log.Error().Err(err).Msg("failed to query hold")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return holdDetail, nil
+}
+
+func (hr HoldRepository) GetHolds(requestID string, userID int64) ([]dto.HoldDetail, *apperrors.RestErr) {
+	holds := []dto.HoldDetail{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	const queryGetHolds = "SELECT" + holdDetailColumns + `
+		FROM holds h JOIN books b ON b.uuid = h.book_uuid
+		WHERE h.user_id = $1 AND h.status IN ('waiting', 'offered')
+		ORDER BY h.placed_at, h.uuid
+	`
+	rows, err := hr.dbpool.Query(ctx, queryGetHolds, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query holds")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var holdDetail dto.HoldDetail
+		if err := rows.Scan(holdDetailScanTargets(&holdDetail)...); err != nil {
+			log.Error().Err(err).Msg("failed to scan hold")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		holds = append(holds, holdDetail)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed to iterate holds")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return holds, nil
+}
+
+func (hr HoldRepository) ExpireHoldOffers(requestID string, batchSize int) (int, *apperrors.RestErr) {
+	var expiredCount int
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	restErr := runInTransaction(ctx, hr.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		// SKIP LOCKED lets several instances expire different offers at the same time.
+		const queryExpireOffers = `
+			WITH lapsed AS (
+				SELECT uuid FROM holds
+				WHERE status = 'offered' AND offer_expires_at <= NOW()
+				ORDER BY offer_expires_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			UPDATE holds h SET status = 'expired', closed_at = NOW()
+			FROM lapsed
+			WHERE h.uuid = lapsed.uuid
+			RETURNING h.book_uuid
+		`
+		rows, err := tx.Query(ctx, queryExpireOffers, batchSize)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to expire hold offers")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		releasedCopies := map[uuid.UUID]int{}
+		for rows.Next() {
+			var bookUUID uuid.UUID
+			if err := rows.Scan(&bookUUID); err != nil {
+				rows.Close()
+				log.Error().Err(err).Msg("failed to scan expired hold offer")
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+			releasedCopies[bookUUID]++
+			expiredCount++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			log.Error().Err(err).Msg("failed to iterate expired hold offers")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if err := releaseCopiesInOrder(ctx, tx, releasedCopies); err != nil {
+			log.Error().Err(err).Msg("failed to pass on the offered copies")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		return nil
+	})
+	if restErr != nil {
+		return 0, restErr
+	}
+
+	if expiredCount > 0 {
+		log.Info().Msgf("expired %d hold offer(s)", expiredCount)
+	}
+	return expiredCount, nil
+}
+
+// releaseCopies puts returned copies, or copies whose offer lapsed, back on the shelf and offers them to the queue.
+func releaseCopies(ctx context.Context, tx pgx.Tx, bookUUID uuid.UUID, copies int) error {
+	const execReleaseCopies = "UPDATE books SET available_copies = available_copies + $2 WHERE uuid = $1 AND withdrawn_at IS NULL"
+	if _, err := tx.Exec(ctx, execReleaseCopies, bookUUID, copies); err != nil {
+		return fmt.Errorf("error releasing copies of book '%s': %w", bookUUID, err)
+	}
+
+	_, err := offerAvailableCopies(ctx, tx, bookUUID)
+	return err
+}
+
+// releaseCopiesInOrder releases the copies of several books, locking the books in a fixed order
+// so that concurrent batches cannot deadlock on them.
+func releaseCopiesInOrder(ctx context.Context, tx pgx.Tx, copiesByBook map[uuid.UUID]int) error {
+	bookUUIDs := make([]uuid.UUID, 0, len(copiesByBook))
+	for bookUUID := range copiesByBook {
+		bookUUIDs = append(bookUUIDs, bookUUID)
+	}
+	slices.SortFunc(bookUUIDs, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+
+	for _, bookUUID := range bookUUIDs {
+		if err := releaseCopies(ctx, tx, bookUUID, copiesByBook[bookUUID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// offerAvailableCopies offers the available copies of a book to the waiting holds, first come first served.
+// An offered copy is reserved for the patron, so it no longer counts as available.
+func offerAvailableCopies(ctx context.Context, tx pgx.Tx, bookUUID uuid.UUID) (int, error) {
+	var availableCopies int
+	const queryLockBook = "SELECT available_copies FROM books WHERE uuid = $1 AND withdrawn_at IS NULL FOR UPDATE"
+	if err := tx.QueryRow(ctx, queryLockBook, bookUUID).Scan(&availableCopies); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error locking book '%s': %w", bookUUID, err)
+	}
+
+	// The copy stays reserved for as long as the loan policy of the patron it is offered to allows.
+	const queryOfferNextHold = `
+		UPDATE holds h SET status = 'offered', offered_at = NOW(), offer_expires_at = NOW() + make_interval(hours => (
+			SELECT p.hold_offer_hours FROM users u
+			JOIN loanpolicies p ON p.patron_category = u.patron_category
+			WHERE u.id = h.user_id
+		))
+		WHERE h.uuid = (
+			SELECT uuid FROM holds
+			WHERE book_uuid = $1 AND status = 'waiting'
+			ORDER BY placed_at, uuid
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING user_id
+	`
+	offeredCount := 0
+	for ; offeredCount < availableCopies; offeredCount++ {
+		var userID int64
+		err := tx.QueryRow(ctx, queryOfferNextHold, bookUUID).Scan(&userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				break
+			}
+			return 0, fmt.Errorf("error offering book '%s': %w", bookUUID, err)
+		}
+		log.Info().Msgf("offered a copy of book '%s' to user %d", bookUUID, userID)
+	}
+
+	if offeredCount > 0 {
+		const execReserveCopies = "UPDATE books SET available_copies = available_copies - $2 WHERE uuid = $1"
+		if _, err := tx.Exec(ctx, execReserveCopies, bookUUID, offeredCount); err != nil {
+			return 0, fmt.Errorf("error reserving copies of book '%s': %w", bookUUID, err)
+		}
+	}
+	return offeredCount, nil
+}
+
+// cancelOpenHolds closes the queue of a book that is withdrawn from the catalog.
+func cancelOpenHolds(ctx context.Context, tx pgx.Tx, bookUUID uuid.UUID) error {
+	const execCancelOpenHolds = "UPDATE holds SET status = 'cancelled', closed_at = NOW() WHERE book_uuid = $1 AND status IN ('waiting', 'offered')"
+	if _, err := tx.Exec(ctx, execCancelOpenHolds, bookUUID); err != nil {
+		return fmt.Errorf("error cancelling the holds of book '%s': %w", bookUUID, err)
+	}
+	return nil
+}
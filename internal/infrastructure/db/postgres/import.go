@@ -0,0 +1,434 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	importTimeout = 5 * time.Minute
+
+	errMsgImportBookWithdrawn  = "book has been withdrawn from the catalog"
+	errMsgImportISBN10Conflict = "the ISBN-10 belongs to another book"
+	errMsgImportFailed         = "failed to save record"
+	errMsgImportBookOnLoan     = "book has %d active loan(s) and cannot be withdrawn until they are returned"
+	errMsgImportCopiesOnLoan   = "cannot reduce the copies below the %d on loan"
+	errMsgImportUnknownBook    = "a record without an ISBN-13 and a title must update a book that was imported before"
+
+	onixNotificationDelete = "05" // The NotificationType of a feed record that withdrew its title
+)
+
+/*
+ImportBooks runs the whole import in one transaction with a savepoint per record,
+so that a failed record is reported and skipped without undoing the others.
+A dry run rolls the transaction back at the end, which makes its report the exact diff of a real run.
+
+Records from a feed (see dto.ImportSource) are tracked in `BookFeedRecords`;
+a record from a message older than the one that last touched it is skipped.
+*/
+func (br BookRepository) ImportBooks(requestID string, records []dto.ImportRecord, dryRun bool) (*dto.ImportReport, *apperrors.RestErr) {
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	tx, err := br.dbpool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg(errMsgFailedToBeginTransaction)
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	defer func() {
+		// Rollback is a no-op after a successful commit.
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error().Err(err).Msg(errMsgFailedToRollbackTransaction)
+		}
+	}()
+
+	report := &dto.ImportReport{DryRun: dryRun, Records: []dto.ImportRecordResult{}}
+	for _, record := range records {
+		result := importBook(ctx, tx, record)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(ctx.Err()).Msg(errMsgContextTimeout)
+			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		switch result.Action {
+		case dto.ImportActionCreate:
+			report.Created++
+		case dto.ImportActionUpdate:
+			report.Updated++
+		case dto.ImportActionUnchanged:
+			report.Unchanged++
+		case dto.ImportActionWithdraw:
+			report.Withdrawn++
+		case dto.ImportActionSkipped:
+			report.Skipped++
+		case dto.ImportActionError:
+			report.Failed++
+		}
+
+		if dryRun {
+			result.BookUUID = nil
+		}
+		report.Records = append(report.Records, result)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg(errMsgFailedToCommitTransaction)
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	log.Info().Msgf("imported %d record(s): %d created, %d updated, %d unchanged, %d withdrawn, %d skipped, %d failed",
+		len(records), report.Created, report.Updated, report.Unchanged, report.Withdrawn, report.Skipped, report.Failed)
+	return report, nil
+}
+
+// importRecordError is a record failure whose message is reported as is.
+type importRecordError string
+
+func (e importRecordError) Error() string { return string(e) }
+
+// importBook upserts or withdraws one record within its own savepoint.
+func importBook(ctx context.Context, tx pgx.Tx, record dto.ImportRecord) dto.ImportRecordResult {
+	result := dto.ImportRecordResult{Line: record.Line, ISBN13: record.ISBN13, Title: strings.ToLower(record.Title)}
+	if record.Source != nil {
+		result.RecordReference = record.Source.RecordReference
+	}
+	fail := func(message string) dto.ImportRecordResult {
+		result.Action = dto.ImportActionError
+		result.Changes = nil
+		result.Error = message
+		return result
+	}
+
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create savepoint")
+		return fail(errMsgImportFailed)
+	}
+
+	// The book of a feed record is found by the sender's record reference when the record carries no ISBN.
+	var feedBookUUID *uuid.UUID
+	withdrawnByFeed := false
+	if record.Source != nil {
+		feedRecord, err := lockBookFeedRecord(ctx, savepoint, *record.Source)
+		if err != nil {
+			_ = savepoint.Rollback(ctx)
+			log.Error().Err(err).Msgf("failed to import record %d", record.Line)
+			return fail(errMsgImportFailed)
+		}
+
+		if feedRecord != nil {
+			if record.Source.SentAt.Before(feedRecord.sentAt) {
+				_ = savepoint.Rollback(ctx)
+				result.Action = dto.ImportActionSkipped
+				return result
+			}
+			feedBookUUID = feedRecord.bookUUID
+			withdrawnByFeed = feedRecord.notificationType == onixNotificationDelete && feedRecord.bookUUID != nil
+		}
+	}
+
+	var bookUUID uuid.UUID
+	var saveErr error
+	existing, isWithdrawn, err := lockImportedBook(ctx, savepoint, record.ISBN13, feedBookUUID)
+	switch {
+	case err != nil:
+		saveErr = err
+
+	case record.Delete:
+		// Deleting a title that is already withdrawn, or was never imported, is a no-op so that feeds can be replayed.
+		result.Action = dto.ImportActionUnchanged
+		if existing != nil {
+			bookUUID = existing.UUID
+			result.ISBN13, result.Title = existing.ISBN13, existing.Title
+			if !isWithdrawn {
+				result.Action = dto.ImportActionWithdraw
+				saveErr = withdrawImportedBook(ctx, savepoint, bookUUID)
+			}
+		}
+
+	case existing == nil:
+		// A partial record, such as an ONIX block update, cannot create a book.
+		if record.ISBN13 == "" || record.Title == "" {
+			_ = savepoint.Rollback(ctx)
+			return fail(errMsgImportUnknownBook)
+		}
+
+		result.Action = dto.ImportActionCreate
+		bookUUID, saveErr = insertImportedBook(ctx, savepoint, record)
+
+	// Only the feed that withdrew a title can reinstate it, with a newer message; a librarian's withdrawal stands.
+	case isWithdrawn && (!withdrawnByFeed || existing.UUID != *feedBookUUID):
+		_ = savepoint.Rollback(ctx)
+		return fail(errMsgImportBookWithdrawn)
+
+	default:
+		bookUUID = existing.UUID
+		merged := mergeImportRecord(*existing, record)
+		result.ISBN13, result.Title = existing.ISBN13, merged.Title
+		result.Changes = diffBookDetail(*existing, merged)
+		if isWithdrawn {
+			result.Changes = append(result.Changes, dto.FieldChange{Field: "withdrawn", Old: "true", New: "false"})
+		}
+		if len(result.Changes) == 0 {
+			result.Action = dto.ImportActionUnchanged
+			break
+		}
+
+		// Copies that are added or removed change the shelf by the same amount, as with AdjustCopies.
+		merged.AvailableCopies += merged.TotalCopies - existing.TotalCopies
+		if merged.AvailableCopies < 0 {
+			_ = savepoint.Rollback(ctx)
+			return fail(fmt.Sprintf(errMsgImportCopiesOnLoan, existing.TotalCopies-existing.AvailableCopies))
+		}
+
+		result.Action = dto.ImportActionUpdate
+		if isWithdrawn {
+			saveErr = reinstateImportedBook(ctx, savepoint, bookUUID)
+		}
+		if saveErr == nil {
+			saveErr = updateImportedBook(ctx, savepoint, merged, !slices.Equal(existing.Authors, merged.Authors))
+		}
+		if saveErr == nil && (isWithdrawn || merged.TotalCopies > existing.TotalCopies) {
+			_, saveErr = offerAvailableCopies(ctx, savepoint, bookUUID)
+		}
+	}
+
+	if saveErr == nil && record.Source != nil {
+		saveErr = saveBookFeedRecord(ctx, savepoint, *record.Source, bookUUID)
+	}
+
+	if saveErr != nil {
+		_ = savepoint.Rollback(ctx)
+		var recordErr importRecordError
+		if errors.As(saveErr, &recordErr) {
+			return fail(recordErr.Error())
+		}
+		if isUniqueViolation(saveErr) {
+			return fail(errMsgImportISBN10Conflict)
+		}
+
+		log.Error().Err(saveErr).Msgf("failed to import record %d", record.Line)
+		return fail(errMsgImportFailed)
+	}
+
+	if err := savepoint.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to release savepoint")
+		return fail(errMsgImportFailed)
+	}
+
+	if bookUUID != uuid.Nil {
+		result.BookUUID = &bookUUID
+	}
+	return result
+}
+
+// lockImportedBook finds a book by ISBN-13, or by its UUID when the ISBN-13 is unknown, including withdrawn books.
+// It returns nil when neither matches.
+func lockImportedBook(ctx context.Context, tx pgx.Tx, isbn13 string, bookUUID *uuid.UUID) (*dto.BookDetail, bool, error) {
+	bookDetail := &dto.BookDetail{}
+	var isWithdrawn bool
+
+	const queryLockImportedBook = "SELECT" + bookDetailColumns + `, b.withdrawn_at IS NOT NULL
+		FROM books b
+		WHERE ($1 <> '' AND b.isbn_13 = $1) OR b.uuid = $2
+		ORDER BY b.isbn_13 = $1 DESC
+		LIMIT 1
+		FOR UPDATE`
+	err := tx.QueryRow(ctx, queryLockImportedBook, isbn13, bookUUID).
+		Scan(append(bookDetailScanTargets(bookDetail), &isWithdrawn)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("error locking book '%s': %w", isbn13, err)
+	}
+
+	return bookDetail, isWithdrawn, nil
+}
+
+// withdrawImportedBook withdraws a title unless it is on loan; unlike WithdrawBook, a feed never closes loans.
+func withdrawImportedBook(ctx context.Context, tx pgx.Tx, bookUUID uuid.UUID) error {
+	var activeLoanCount int
+	const queryCountActiveLoans = "SELECT COUNT(*) FROM loans WHERE book_uuid = $1 AND is_returned = FALSE"
+	if err := tx.QueryRow(ctx, queryCountActiveLoans, bookUUID).Scan(&activeLoanCount); err != nil {
+		return fmt.Errorf("error counting active loans of book '%s': %w", bookUUID, err)
+	}
+
+	if activeLoanCount > 0 {
+		return importRecordError(fmt.Sprintf(errMsgImportBookOnLoan, activeLoanCount))
+	}
+
+	const execWithdrawBook = "UPDATE books SET withdrawn_at = NOW(), updated_at = NOW() WHERE uuid = $1"
+	if _, err := tx.Exec(ctx, execWithdrawBook, bookUUID); err != nil {
+		return err
+	}
+
+	return cancelOpenHolds(ctx, tx, bookUUID)
+}
+
+// reinstateImportedBook returns a title that a feed withdrew to the catalog.
+func reinstateImportedBook(ctx context.Context, tx pgx.Tx, bookUUID uuid.UUID) error {
+	const execReinstateBook = "UPDATE books SET withdrawn_at = NULL, updated_at = NOW() WHERE uuid = $1"
+	_, err := tx.Exec(ctx, execReinstateBook, bookUUID)
+	return err
+}
+
+type bookFeedRecord struct {
+	bookUUID         *uuid.UUID
+	sentAt           time.Time
+	notificationType string // Of the message that last touched the record
+}
+
+func lockBookFeedRecord(ctx context.Context, tx pgx.Tx, source dto.ImportSource) (*bookFeedRecord, error) {
+	feedRecord := &bookFeedRecord{}
+	const queryLockBookFeedRecord = `
+		SELECT book_uuid, message_sent_at, notification_type FROM BookFeedRecords
+		WHERE sender = $1 AND record_reference = $2
+		FOR UPDATE
+	`
+	err := tx.QueryRow(ctx, queryLockBookFeedRecord, source.Sender, source.RecordReference).
+		Scan(&feedRecord.bookUUID, &feedRecord.sentAt, &feedRecord.notificationType)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error locking feed record '%s' of '%s': %w", source.RecordReference, source.Sender, err)
+	}
+
+	return feedRecord, nil
+}
+
+// saveBookFeedRecord remembers the message that last touched a feed record.
+func saveBookFeedRecord(ctx context.Context, tx pgx.Tx, source dto.ImportSource, bookUUID uuid.UUID) error {
+	var nullableBookUUID *uuid.UUID
+	if bookUUID != uuid.Nil {
+		nullableBookUUID = &bookUUID
+	}
+
+	const execUpsertBookFeedRecord = `
+		INSERT INTO BookFeedRecords (sender, record_reference, book_uuid, message_id, message_sent_at,
+			notification_type, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (sender, record_reference) DO UPDATE
+		SET book_uuid = COALESCE(EXCLUDED.book_uuid, BookFeedRecords.book_uuid),
+			message_id = EXCLUDED.message_id,
+			message_sent_at = EXCLUDED.message_sent_at,
+			notification_type = EXCLUDED.notification_type,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := tx.Exec(ctx, execUpsertBookFeedRecord, source.Sender, source.RecordReference, nullableBookUUID,
+		source.MessageID, source.SentAt, source.NotificationType)
+	if err != nil {
+		return fmt.Errorf("error saving feed record '%s' of '%s': %w", source.RecordReference, source.Sender, err)
+	}
+	return nil
+}
+
+func insertImportedBook(ctx context.Context, tx pgx.Tx, record dto.ImportRecord) (uuid.UUID, error) {
+	var bookUUID uuid.UUID
+	totalCopies := 0
+	if record.TotalCopies != nil {
+		totalCopies = *record.TotalCopies
+	}
+
+	const queryInsertBook = `
+		INSERT INTO Books (uuid, isbn_13, isbn_10, title, edition, publisher, language, publication_year,
+			description, total_copies, available_copies, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1, NULLIF($2, ''), lower($3), $4, $5, COALESCE(NULLIF($6, ''), 'en'), NULLIF($7, 0),
+			$8, $9, $9, NOW(), NOW())
+		RETURNING uuid
+	`
+	err := tx.QueryRow(ctx, queryInsertBook,
+		record.ISBN13, record.ISBN10, record.Title, record.Edition, record.Publisher,
+		strings.ToLower(record.Language), record.PublicationYear, record.Description, totalCopies).
+		Scan(&bookUUID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return bookUUID, saveBookAuthors(ctx, tx, bookUUID, record.Authors)
+}
+
+func updateImportedBook(ctx context.Context, tx pgx.Tx, book dto.BookDetail, authorsChanged bool) error {
+	const execUpdateBook = `
+		UPDATE books
+		SET isbn_10 = NULLIF($2, ''), title = $3, edition = $4, publisher = $5, language = $6,
+			publication_year = NULLIF($7, 0), description = $8, total_copies = $9, available_copies = $10,
+			updated_at = NOW()
+		WHERE uuid = $1
+	`
+	_, err := tx.Exec(ctx, execUpdateBook,
+		book.UUID, book.ISBN10, book.Title, book.Edition, book.Publisher, book.Language,
+		book.PublicationYear, book.Description, book.TotalCopies, book.AvailableCopies)
+	if err != nil {
+		return err
+	}
+
+	if authorsChanged {
+		return saveBookAuthors(ctx, tx, book.UUID, book.Authors)
+	}
+	return nil
+}
+
+// mergeImportRecord overlays the fields that the record provides on the existing book.
+func mergeImportRecord(book dto.BookDetail, record dto.ImportRecord) dto.BookDetail {
+	overlay := func(field *string, value string) {
+		if value != "" {
+			*field = value
+		}
+	}
+
+	overlay(&book.ISBN10, record.ISBN10)
+	overlay(&book.Title, strings.ToLower(record.Title))
+	overlay(&book.Edition, record.Edition)
+	overlay(&book.Publisher, record.Publisher)
+	overlay(&book.Language, strings.ToLower(record.Language))
+	overlay(&book.Description, record.Description)
+	if len(record.Authors) > 0 {
+		book.Authors = record.Authors
+	}
+	if record.PublicationYear != 0 {
+		book.PublicationYear = record.PublicationYear
+	}
+	if record.TotalCopies != nil {
+		book.TotalCopies = *record.TotalCopies
+	}
+	return book
+}
+
+func diffBookDetail(old, new dto.BookDetail) []dto.FieldChange {
+	changes := []dto.FieldChange{}
+	compare := func(field string, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, dto.FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	compare("isbn_10", old.ISBN10, new.ISBN10)
+	compare("title", old.Title, new.Title)
+	compare("authors", strings.Join(old.Authors, "; "), strings.Join(new.Authors, "; "))
+	compare("edition", old.Edition, new.Edition)
+	compare("publisher", old.Publisher, new.Publisher)
+	compare("language", old.Language, new.Language)
+	compare("publication_year", strconv.Itoa(old.PublicationYear), strconv.Itoa(new.PublicationYear))
+	compare("description", old.Description, new.Description)
+	compare("total_copies", strconv.Itoa(old.TotalCopies), strconv.Itoa(new.TotalCopies))
+	return changes
+}
@@ -0,0 +1,74 @@
+//go:build integration
+
+package postgres
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportFeedWithdrawal(t *testing.T) {
+	dbpool := connectStressDB(t)
+	bookRepository := BookRepository{dbpool}
+
+	// feedRecord is a record of a message of its own test sender, sent minutes after the first message.
+	sender := "Stress Feed " + uuid.NewString()
+	isbn13 := fmt.Sprintf("979%010d", time.Now().UnixNano()%10_000_000_000)
+	copies := 2
+	feedRecord := func(minutes int, notificationType string) dto.ImportRecord {
+		record := dto.ImportRecord{
+			Line: 1, ISBN13: isbn13, Title: "Feed Test " + isbn13, TotalCopies: &copies,
+			Source: &dto.ImportSource{
+				Sender: sender, MessageID: fmt.Sprint(minutes), RecordReference: "feed.test." + isbn13,
+				SentAt: time.Date(2024, 3, 15, 9, minutes, 0, 0, time.UTC), NotificationType: notificationType,
+			},
+		}
+		if notificationType == onixNotificationDelete {
+			record.Delete = true
+		}
+		return record
+	}
+	importRecord := func(t *testing.T, record dto.ImportRecord) dto.ImportRecordResult {
+		report, restErr := bookRepository.ImportBooks("stress", []dto.ImportRecord{record}, false)
+		require.Nil(t, restErr)
+		require.Len(t, report.Records, 1)
+		return report.Records[0]
+	}
+
+	created := importRecord(t, feedRecord(0, "03"))
+	require.Equal(t, dto.ImportActionCreate, created.Action)
+	bookUUID := *created.BookUUID
+	t.Cleanup(func() {
+		if restErr := bookRepository.WithdrawBook("stress", bookUUID, true); restErr != nil {
+			t.Logf("failed to withdraw book '%s': %s", bookUUID, restErr.Message)
+		}
+	})
+
+	t.Run("A newer record of the feed that withdrew a title reinstates it", func(t *testing.T) {
+		assert.Equal(t, dto.ImportActionWithdraw, importRecord(t, feedRecord(1, "05")).Action)
+		_, restErr := bookRepository.GetBookByUUID("stress", bookUUID)
+		require.NotNil(t, restErr)
+
+		reinstated := importRecord(t, feedRecord(2, "03"))
+		assert.Equal(t, dto.ImportActionUpdate, reinstated.Action)
+		assert.Equal(t, []dto.FieldChange{{Field: "withdrawn", Old: "true", New: "false"}}, reinstated.Changes)
+
+		bookDetail, restErr := bookRepository.GetBookByUUID("stress", bookUUID)
+		require.Nil(t, restErr)
+		assert.Equal(t, copies, bookDetail.AvailableCopies)
+	})
+
+	t.Run("A title that a librarian withdrew stays withdrawn", func(t *testing.T) {
+		require.Nil(t, bookRepository.WithdrawBook("stress", bookUUID, false))
+
+		refused := importRecord(t, feedRecord(3, "03"))
+		assert.Equal(t, dto.ImportActionError, refused.Action)
+		assert.Equal(t, errMsgImportBookWithdrawn, refused.Error)
+	})
+}
@@ -0,0 +1,228 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The stress tests run against the Postgres of the POSTGRES_* environment variables, as `make stress` does,
+// and are skipped without one.
+func connectStressDB(t *testing.T) *pgxpool.Pool {
+	if os.Getenv("POSTGRES_HOST") == "" {
+		t.Skip("POSTGRES_HOST is not set")
+	}
+
+	connString := fmt.Sprintf(
+		"user=%s password=%s host=%s port=%s dbname=%s sslmode=%s pool_max_conns=20",
+		os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_PASSWORD"),
+		os.Getenv("POSTGRES_HOST"), os.Getenv("POSTGRES_PORT"),
+		os.Getenv("POSTGRES_DB"), os.Getenv("POSTGRES_SSLMODE"),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dbpool, err := pgxpool.New(ctx, connString)
+	require.NoError(t, err)
+	t.Cleanup(dbpool.Close)
+
+	require.NoError(t, executeSchema(ctx, dbpool, "../../../../config/schema.elib.sql"))
+	return dbpool
+}
+
+func createStressBook(t *testing.T, bookRepository BookRepository, copies int) *dto.BookDetail {
+	isbn13 := fmt.Sprintf("979%010d", time.Now().UnixNano()%10_000_000_000)
+	bookDetail, restErr := bookRepository.CreateBook("stress", dto.BookCreateRequest{
+		ISBN13:      isbn13,
+		Title:       "Stress Test " + isbn13,
+		Authors:     []string{"Stress Tester"},
+		TotalCopies: copies,
+	})
+	require.Nil(t, restErr)
+
+	t.Cleanup(func() {
+		if restErr := bookRepository.WithdrawBook("stress", bookDetail.UUID, true); restErr != nil {
+			t.Logf("failed to withdraw book '%s': %s", bookDetail.UUID, restErr.Message)
+		}
+	})
+	return bookDetail
+}
+
+func createStressUsers(t *testing.T, dbpool *pgxpool.Pool, count int) []dto.UserDetail {
+	ctx := context.Background()
+	prefix := uuid.NewString()
+	userDetails := make([]dto.UserDetail, count)
+	for i := range userDetails {
+		userDetails[i].Name = fmt.Sprintf("Stress Tester %d", i)
+		const queryInsertUser = "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id"
+		err := dbpool.QueryRow(ctx, queryInsertUser, userDetails[i].Name, fmt.Sprintf("%s-%d@stress.test", prefix, i)).
+			Scan(&userDetails[i].ID)
+		require.NoError(t, err)
+	}
+
+	t.Cleanup(func() {
+		for _, userDetail := range userDetails {
+			if _, err := dbpool.Exec(ctx, "DELETE FROM holds WHERE user_id = $1", userDetail.ID); err != nil {
+				t.Logf("failed to delete holds of user %d: %v", userDetail.ID, err)
+			}
+			const execDeleteLoanEvents = "DELETE FROM loanevents WHERE loan_uuid IN (SELECT uuid FROM loans WHERE user_id = $1)"
+			if _, err := dbpool.Exec(ctx, execDeleteLoanEvents, userDetail.ID); err != nil {
+				t.Logf("failed to delete loan events of user %d: %v", userDetail.ID, err)
+			}
+			if _, err := dbpool.Exec(ctx, "DELETE FROM loans WHERE user_id = $1", userDetail.ID); err != nil {
+				t.Logf("failed to delete loans of user %d: %v", userDetail.ID, err)
+			}
+			if _, err := dbpool.Exec(ctx, "DELETE FROM users WHERE id = $1", userDetail.ID); err != nil {
+				t.Logf("failed to delete user %d: %v", userDetail.ID, err)
+			}
+		}
+	})
+	return userDetails
+}
+
+// runConcurrently starts all calls at once and returns their errors by index.
+func runConcurrently(count int, call func(i int) *apperrors.RestErr) []*apperrors.RestErr {
+	restErrs := make([]*apperrors.RestErr, count)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			restErrs[i] = call(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return restErrs
+}
+
+func assertNoCopiesDrift(t *testing.T, bookRepository BookRepository, bookUUIDs ...uuid.UUID) {
+	copiesDrifts, restErr := bookRepository.ReconcileCopies("stress", false)
+	require.Nil(t, restErr)
+	for _, drift := range copiesDrifts {
+		assert.NotContains(t, bookUUIDs, drift.UUID, "book '%s' drifted: %+v", drift.UUID, drift)
+	}
+}
+
+func TestInventoryUnderConcurrency(t *testing.T) {
+	dbpool := connectStressDB(t)
+	bookRepository := BookRepository{dbpool}
+	loanRepository := LoanRepository{dbpool}
+
+	t.Run("Concurrent borrows lend each copy once and returns put every copy back", func(t *testing.T) {
+		const copies, patrons = 5, 40
+		bookDetail := createStressBook(t, bookRepository, copies)
+		userDetails := createStressUsers(t, dbpool, patrons)
+
+		restErrs := runConcurrently(patrons, func(i int) *apperrors.RestErr {
+			_, restErr := loanRepository.BorrowBook("stress", userDetails[i], bookDetail)
+			return restErr
+		})
+
+		var borrowers []dto.UserDetail
+		for i, restErr := range restErrs {
+			if restErr == nil {
+				borrowers = append(borrowers, userDetails[i])
+				continue
+			}
+			assert.Equal(t, fiber.StatusConflict, restErr.Status)
+			assert.Equal(t, entity.BorrowRefusedNoCopyAvailable, restErr.Code)
+		}
+		assert.Len(t, borrowers, copies)
+
+		stockedBook, restErr := bookRepository.GetBookByUUID("stress", bookDetail.UUID)
+		require.Nil(t, restErr)
+		assert.Equal(t, 0, stockedBook.AvailableCopies)
+		assertNoCopiesDrift(t, bookRepository, bookDetail.UUID)
+
+		restErrs = runConcurrently(len(borrowers), func(i int) *apperrors.RestErr {
+			return loanRepository.ReturnBook("stress", borrowers[i].ID, bookDetail.UUID)
+		})
+		for _, restErr := range restErrs {
+			assert.Nil(t, restErr)
+		}
+
+		stockedBook, restErr = bookRepository.GetBookByUUID("stress", bookDetail.UUID)
+		require.Nil(t, restErr)
+		assert.Equal(t, stockedBook.TotalCopies, stockedBook.AvailableCopies)
+		assertNoCopiesDrift(t, bookRepository, bookDetail.UUID)
+
+		// Each lent copy has exactly its borrowed and returned events.
+		var eventCount int
+		const queryCountLoanEvents = `
+			SELECT COUNT(*) FROM loanevents e JOIN loans l ON l.uuid = e.loan_uuid WHERE l.book_uuid = $1
+		`
+		require.NoError(t, dbpool.QueryRow(context.Background(), queryCountLoanEvents, bookDetail.UUID).Scan(&eventCount))
+		assert.Equal(t, 2*copies, eventCount)
+	})
+
+	t.Run("Concurrent borrows of one book by one patron lend it once", func(t *testing.T) {
+		const attempts = 10
+		bookDetail := createStressBook(t, bookRepository, attempts)
+		userDetail := createStressUsers(t, dbpool, 1)[0]
+
+		restErrs := runConcurrently(attempts, func(i int) *apperrors.RestErr {
+			_, restErr := loanRepository.BorrowBook("stress", userDetail, bookDetail)
+			return restErr
+		})
+
+		lentCount := 0
+		for _, restErr := range restErrs {
+			if restErr == nil {
+				lentCount++
+				continue
+			}
+			assert.Equal(t, fiber.StatusConflict, restErr.Status)
+			assert.Equal(t, entity.BorrowRefusedAlreadyBorrowed, restErr.Code)
+		}
+		assert.Equal(t, 1, lentCount)
+		assertNoCopiesDrift(t, bookRepository, bookDetail.UUID)
+	})
+
+	t.Run("Concurrent borrows of one patron stop at the loan limit", func(t *testing.T) {
+		userDetail := createStressUsers(t, dbpool, 1)[0]
+		policy, err := getLoanPolicy(context.Background(), dbpool, userDetail.ID)
+		require.NoError(t, err)
+
+		bookCount := policy.MaxActiveLoans + 5
+		bookDetails := make([]*dto.BookDetail, bookCount)
+		bookUUIDs := make([]uuid.UUID, bookCount)
+		for i := range bookDetails {
+			bookDetails[i] = createStressBook(t, bookRepository, 1)
+			bookUUIDs[i] = bookDetails[i].UUID
+		}
+
+		restErrs := runConcurrently(bookCount, func(i int) *apperrors.RestErr {
+			_, restErr := loanRepository.BorrowBook("stress", userDetail, bookDetails[i])
+			return restErr
+		})
+
+		lentCount := 0
+		for _, restErr := range restErrs {
+			if restErr == nil {
+				lentCount++
+				continue
+			}
+			assert.Equal(t, entity.BorrowRefusedMaxActiveLoans, restErr.Code)
+		}
+		assert.Equal(t, policy.MaxActiveLoans, lentCount)
+		assertNoCopiesDrift(t, bookRepository, bookUUIDs...)
+	})
+}
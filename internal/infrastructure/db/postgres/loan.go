@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/DarrelA/e-lib/internal/apperrors"
@@ -16,9 +17,65 @@ import (
 )
 
 const (
-	errMsgNoActiveLoan = "no active loan found for this user and book"
+	errMsgNoActiveLoan         = "no active loan found for this user and book"
+	errMsgAlreadyBorrowed      = "user has already borrowed this book"
+	errMsgMaxActiveLoans       = "user already has the maximum of %d active loans"
+	errMsgNoCopyAvailable      = "no copy of this book is available"
+	errMsgLoanOverdue          = "loan is overdue"
+	errMsgMaxRenewals          = "loan has already been extended the maximum of %d time(s)"
+	errMsgTitleInDemand        = "other patrons are waiting for this book"
+	errMsgOutsideRenewalWindow = "loan can only be extended in the %d day(s) before it is due"
 )
 
+// loanDetailColumns are scanned by loanDetailScanTargets together with the loanState; the loans table is aliased as l.
+// A title is in demand when more holds wait for it than it has copies available.
+const loanDetailColumns = `
+	l.uuid, l.book_uuid, l.name_of_borrower, l.loan_date, l.return_date, l.is_extended, l.renewal_count,
+	l.returned_at, COALESCE(l.return_reason, ''), NOT l.is_returned AS is_active,
+	(SELECT count(*) FROM holds h WHERE h.book_uuid = l.book_uuid AND h.status = 'waiting')
+		> (SELECT b.available_copies FROM books b WHERE b.uuid = l.book_uuid) AS in_demand`
+
+// loanState holds the columns that decide whether a loan can be extended.
+type loanState struct {
+	isActive bool
+	inDemand bool
+}
+
+func loanDetailScanTargets(loanDetail *dto.LoanDetail, state *loanState) []any {
+	return []any{
+		&loanDetail.UUID, &loanDetail.BookUUID, &loanDetail.NameOfBorrower, &loanDetail.LoanDate, &loanDetail.ReturnDate,
+		&loanDetail.IsExtended, &loanDetail.RenewalCount, &loanDetail.ReturnedAt, &loanDetail.ReturnReason,
+		&state.isActive, &state.inDemand,
+	}
+}
+
+// applyLoanPolicy sets whether an active loan can still be extended under the policy of its borrower, and why not.
+func applyLoanPolicy(loanDetail *dto.LoanDetail, state loanState, policy *entity.LoanPolicy, now time.Time) {
+	if !state.isActive {
+		return
+	}
+
+	loanDetail.ExtensionRefusal = policy.ExtensionRefusal(loanDetail.RenewalCount, loanDetail.ReturnDate, now, state.inDemand)
+	loanDetail.CanExtend = loanDetail.ExtensionRefusal == ""
+}
+
+// extensionRefusalError explains an extension refusal of the policy, with the refusal as its code.
+func extensionRefusalError(refusal string, policy *entity.LoanPolicy) *apperrors.RestErr {
+	var message string
+	switch refusal {
+	case entity.ExtensionRefusedLoanOverdue:
+		message = errMsgLoanOverdue
+	case entity.ExtensionRefusedMaxRenewals:
+		message = fmt.Sprintf(errMsgMaxRenewals, policy.MaxRenewals)
+	case entity.ExtensionRefusedTitleInDemand:
+		message = errMsgTitleInDemand
+	case entity.ExtensionRefusedOutsideRenewalWindow:
+		message = fmt.Sprintf(errMsgOutsideRenewalWindow, policy.RenewalWindowDays)
+	}
+
+	return apperrors.NewForbiddenError(message).WithCode(refusal)
+}
+
 type LoanRepository struct {
 	dbpool *pgxpool.Pool
 }
@@ -30,7 +87,7 @@ func NewLoanRepository(dbpool *pgxpool.Pool) repository.LoanRepository {
 func (lr LoanRepository) BorrowBook(requestID string, userDetail dto.UserDetail, bookDetail *dto.BookDetail) (
 	*dto.LoanDetail, *apperrors.RestErr) {
 
-	var existingLoanCount int
+	var existingLoanCount, activeLoanCount int
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -55,11 +112,25 @@ func (lr LoanRepository) BorrowBook(requestID string, userDetail dto.UserDetail,
 		}
 	}()
 
-	const queryCheckExistingLoan = "SELECT COUNT(*) FROM loans WHERE user_id = $1 AND book_uuid = $2 AND is_returned = FALSE"
-	err = tx.QueryRow(ctx, queryCheckExistingLoan, userDetail.ID, bookDetail.UUID).Scan(&existingLoanCount)
+	// The user is locked before the book, so that two borrows of the same user cannot both pass the loan limit.
+	policy, err := lockLoanPolicy(ctx, tx, userDetail.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get loan policy")
+		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+		}
+		return nil, rErr
+	}
+
+	const queryCheckExistingLoan = `
+		SELECT COUNT(*) FILTER (WHERE book_uuid = $2), COUNT(*)
+		FROM loans WHERE user_id = $1 AND is_returned = FALSE
+	`
+	err = tx.QueryRow(ctx, queryCheckExistingLoan, userDetail.ID, bookDetail.UUID).Scan(&existingLoanCount, &activeLoanCount)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			existingLoanCount = 0
+			existingLoanCount, activeLoanCount = 0, 0
 		} else {
 			log.Error().Err(err).Msg("failed to check existing loan")
 			rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
@@ -71,17 +142,45 @@ func (lr LoanRepository) BorrowBook(requestID string, userDetail dto.UserDetail,
 	}
 
 	if existingLoanCount > 0 {
-		rErr := apperrors.NewBadRequestError("user has already borrowed this book")
+		rErr := apperrors.NewConflictError(errMsgAlreadyBorrowed).WithCode(entity.BorrowRefusedAlreadyBorrowed)
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+		}
+		return nil, rErr
+	}
+
+	if activeLoanCount >= policy.MaxActiveLoans {
+		rErr := apperrors.NewForbiddenError(fmt.Sprintf(errMsgMaxActiveLoans, policy.MaxActiveLoans)).
+			WithCode(entity.BorrowRefusedMaxActiveLoans)
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
 		}
 		return nil, rErr
 	}
 
-	const execDecrementAvailableCopies = "UPDATE books SET available_copies = available_copies - 1 WHERE uuid = $1 AND available_copies > 0"
-	_, err = tx.Exec(ctx, execDecrementAvailableCopies, bookDetail.UUID)
+	// The availability checked by the caller may be stale, so it is checked again under the lock of the book.
+	var availableCopies int
+	const queryLockBook = "SELECT available_copies FROM books WHERE uuid = $1 AND withdrawn_at IS NULL FOR UPDATE"
+	err = tx.QueryRow(ctx, queryLockBook, bookDetail.UUID).Scan(&availableCopies)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to decrement available copies")
+		var rErr *apperrors.RestErr
+		if errors.Is(err, pgx.ErrNoRows) {
+			rErr = apperrors.NewNotFoundError(errMsgBookNotFound)
+		} else {
+			log.Error().Err(err).Msg("failed to lock book")
+			rErr = apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+		}
+		return nil, rErr
+	}
+
+	// A copy offered to the user's hold is already reserved, so claiming it leaves the shelf as it is.
+	const execFulfilHold = "UPDATE holds SET status = 'fulfilled', closed_at = NOW() WHERE user_id = $1 AND book_uuid = $2 AND status = 'offered'"
+	cmdTag, err := tx.Exec(ctx, execFulfilHold, userDetail.ID, bookDetail.UUID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to fulfil hold")
 		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
@@ -89,17 +188,58 @@ func (lr LoanRepository) BorrowBook(requestID string, userDetail dto.UserDetail,
 		return nil, rErr
 	}
 
+	if cmdTag.RowsAffected() == 0 {
+		const execDecrementAvailableCopies = "UPDATE books SET available_copies = available_copies - 1 WHERE uuid = $1 AND available_copies > 0"
+		cmdTag, err = tx.Exec(ctx, execDecrementAvailableCopies, bookDetail.UUID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to decrement available copies")
+			rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			if rbErr := tx.Rollback(ctx); rbErr != nil {
+				log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+			}
+			return nil, rErr
+		}
+
+		// Another patron took the last copy after the caller looked the book up.
+		if cmdTag.RowsAffected() == 0 {
+			log.Warn().Msgf("no copy of book '%s' left to lend (%d available)", bookDetail.UUID, availableCopies)
+			rErr := apperrors.NewConflictError(errMsgNoCopyAvailable).WithCode(entity.BorrowRefusedNoCopyAvailable)
+			if rbErr := tx.Rollback(ctx); rbErr != nil {
+				log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+			}
+			return nil, rErr
+		}
+	}
+
 	const queryInsertLoan = `
-		INSERT INTO loans (uuid, user_id, book_uuid, name_of_borrower, loan_date, return_date)
-		VALUES (gen_random_uuid(), $1, $2, $3, NOW(), NOW() + interval '4 weeks')
-		returning name_of_borrower, loan_date, return_date
-	`
+		INSERT INTO loans AS l (uuid, user_id, book_uuid, name_of_borrower, loan_date, return_date)
+		VALUES (gen_random_uuid(), $1, $2, $3, NOW(), NOW() + make_interval(days => $4))
+		returning` + loanDetailColumns
 	loanDetail := &dto.LoanDetail{BookTitle: bookDetail.Title}
-	err = tx.QueryRow(ctx, queryInsertLoan, userDetail.ID, bookDetail.UUID, userDetail.Name).
-		Scan(&loanDetail.NameOfBorrower, &loanDetail.LoanDate, &loanDetail.ReturnDate)
+	var state loanState
+	err = tx.QueryRow(ctx, queryInsertLoan, userDetail.ID, bookDetail.UUID, userDetail.Name, policy.LoanDays).
+		Scan(loanDetailScanTargets(loanDetail, &state)...)
+
+	if err != nil {
+		var rErr *apperrors.RestErr
+		// The unique index on active loans catches a concurrent borrow of the same book that passed the check above.
+		if isUniqueViolation(err) {
+			rErr = apperrors.NewConflictError(errMsgAlreadyBorrowed).WithCode(entity.BorrowRefusedAlreadyBorrowed)
+		} else {
+			log.Error().Err(err).Msg("failed to insert loan")
+			rErr = apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+		}
+		return nil, rErr
+	}
 
+	err = insertLoanEvent(ctx, tx, loanDetail.UUID, dto.LoanEvent{
+		Kind: dto.LoanEventBorrowed, ActorUserID: &userDetail.ID, RequestID: requestID, ReturnDate: loanDetail.ReturnDate,
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("failed to insert loan")
+		log.Error().Err(err).Msg("failed to record loan event")
 		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
@@ -114,12 +254,13 @@ func (lr LoanRepository) BorrowBook(requestID string, userDetail dto.UserDetail,
 	}
 	err = nil // clear the err, so Rollback wont be executed.
 
+	applyLoanPolicy(loanDetail, state, policy, time.Now())
 	return loanDetail, nil
 }
 
 func (lr LoanRepository) ExtendBookLoan(requestID string, user_id int64, bookDetail *dto.BookDetail) (*dto.LoanDetail, *apperrors.RestErr) {
 	loanDetail := &dto.LoanDetail{BookTitle: bookDetail.Title}
-	var isExtended bool
+	var state loanState
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -144,40 +285,59 @@ func (lr LoanRepository) ExtendBookLoan(requestID string, user_id int64, bookDet
 		}
 	}()
 
-	const queryCheckIsExtended = "SELECT is_extended FROM loans WHERE user_id = $1 AND book_uuid = $2 AND is_returned = FALSE"
-	err = tx.QueryRow(ctx, queryCheckIsExtended, user_id, bookDetail.UUID).Scan(&isExtended)
+	policy, err := getLoanPolicy(ctx, tx, user_id)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get loan policy")
+		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+		}
+		return nil, rErr
+	}
+
+	// The loan is locked until the extension commits, so that two extensions cannot both pass the checks.
+	const queryCheckRenewals = `
+		SELECT` + loanDetailColumns + `
+		FROM loans l
+		WHERE l.user_id = $1 AND l.book_uuid = $2 AND l.is_returned = FALSE
+		FOR UPDATE OF l
+	`
+	err = tx.QueryRow(ctx, queryCheckRenewals, user_id, bookDetail.UUID).Scan(loanDetailScanTargets(loanDetail, &state)...)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			isExtended = false
-		} else {
-			log.Error().Err(err).Msg("failed to check if loan is extended")
-			rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			rErr := apperrors.NewNotFoundError(errMsgNoActiveLoan)
 			if rbErr := tx.Rollback(ctx); rbErr != nil {
 				log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
 			}
 			return nil, rErr
 		}
+		log.Error().Err(err).Msg("failed to check the renewals of the loan")
+		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+		}
+		return nil, rErr
 	}
 
-	if isExtended {
-		rErr := apperrors.NewBadRequestError("user has already borrowed and extended this book")
+	applyLoanPolicy(loanDetail, state, policy, time.Now())
+	if !loanDetail.CanExtend {
+		rErr := extensionRefusalError(loanDetail.ExtensionRefusal, policy)
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
 		}
 		return nil, rErr
 	}
 
+	previousReturnDate := loanDetail.ReturnDate
 	const queryExtendReturnDate = `
-		UPDATE loans 
-		SET return_date = return_date + interval '3 weeks', is_extended = TRUE
+		UPDATE loans l
+		SET return_date = return_date + make_interval(days => $3), is_extended = TRUE, renewal_count = renewal_count + 1
 		WHERE user_id = $1 
 			AND book_uuid = $2 
-			AND is_returned = FALSE 
-			AND is_extended = FALSE
-		returning name_of_borrower, loan_date, return_date
-	`
-	err = tx.QueryRow(ctx, queryExtendReturnDate, user_id, bookDetail.UUID).
-		Scan(&loanDetail.NameOfBorrower, &loanDetail.LoanDate, &loanDetail.ReturnDate)
+			AND is_returned = FALSE
+		returning` + loanDetailColumns
+	err = tx.QueryRow(ctx, queryExtendReturnDate, user_id, bookDetail.UUID, policy.ExtensionDays).
+		Scan(loanDetailScanTargets(loanDetail, &state)...)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -195,6 +355,19 @@ func (lr LoanRepository) ExtendBookLoan(requestID string, user_id int64, bookDet
 		return nil, rErr
 	}
 
+	err = insertLoanEvent(ctx, tx, loanDetail.UUID, dto.LoanEvent{
+		Kind: dto.LoanEventExtended, ActorUserID: &user_id, RequestID: requestID,
+		PreviousReturnDate: &previousReturnDate, ReturnDate: loanDetail.ReturnDate,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to record loan event")
+		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+		}
+		return nil, rErr
+	}
+
 	err = tx.Commit(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg(errMsgFailedToCommitTransaction)
@@ -202,6 +375,7 @@ func (lr LoanRepository) ExtendBookLoan(requestID string, user_id int64, bookDet
 	}
 	err = nil // clear the err, so Rollback wont be executed.
 
+	applyLoanPolicy(loanDetail, state, policy, time.Now())
 	return loanDetail, nil
 }
 
@@ -250,9 +424,22 @@ func (lr LoanRepository) ReturnBook(requestID string, user_id int64, book_uuid u
 		return rErr
 	}
 
-	const execSetIsReturned = "UPDATE loans SET is_returned = TRUE WHERE uuid = $1 AND is_returned = FALSE"
-	_, err = tx.Exec(ctx, execSetIsReturned, loanID)
+	var returnDate time.Time
+	const querySetIsReturned = `
+		UPDATE loans SET is_returned = TRUE, returned_at = NOW(), return_reason = 'returned'
+		WHERE uuid = $1 AND is_returned = FALSE
+		RETURNING return_date
+	`
+	err = tx.QueryRow(ctx, querySetIsReturned, loanID).Scan(&returnDate)
 	if err != nil {
+		// The loan may have expired since it was looked up, in which case its copy is already back.
+		if errors.Is(err, pgx.ErrNoRows) {
+			rErr := apperrors.NewBadRequestError(errMsgNoActiveLoan)
+			if rbErr := tx.Rollback(ctx); rbErr != nil {
+				log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+			}
+			return rErr
+		}
 		log.Error().Err(err).Msg("Failed to set is_returned")
 		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
@@ -261,8 +448,21 @@ func (lr LoanRepository) ReturnBook(requestID string, user_id int64, book_uuid u
 		return rErr
 	}
 
-	const execIncrementAvailableCopies = "UPDATE books SET available_copies = available_copies + 1 WHERE uuid = $1 AND available_copies > 0"
-	_, err = tx.Exec(ctx, execIncrementAvailableCopies, book_uuid)
+	err = insertLoanEvent(ctx, tx, loanID, dto.LoanEvent{
+		Kind: dto.LoanEventReturned, ActorUserID: &user_id, RequestID: requestID,
+		PreviousReturnDate: &returnDate, ReturnDate: returnDate,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to record loan event")
+		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
+		}
+		return rErr
+	}
+
+	// The returned copy goes to the next hold in the queue, if any, before it goes back on the shelf.
+	err = releaseCopies(ctx, tx, book_uuid, 1)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to increment available copies")
 		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
@@ -281,3 +481,133 @@ func (lr LoanRepository) ReturnBook(requestID string, user_id int64, book_uuid u
 
 	return nil
 }
+
+func (lr LoanRepository) ExpireLoans(requestID string, batchSize int) (int, *apperrors.RestErr) {
+	var expiredCount int
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	restErr := runInTransaction(ctx, lr.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		// SKIP LOCKED lets several instances expire different loans at the same time,
+		// and skips a loan that its patron is returning or extending right now.
+		const queryExpireLoans = `
+			WITH due AS (
+				SELECT uuid FROM loans
+				WHERE is_returned = FALSE AND return_date <= NOW()
+				ORDER BY return_date
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			),
+			expired AS (
+				UPDATE loans l SET is_returned = TRUE, returned_at = NOW(), return_reason = 'expired'
+				FROM due
+				WHERE l.uuid = due.uuid
+				RETURNING l.uuid, l.book_uuid, l.return_date
+			),
+			events AS (
+				INSERT INTO loanevents (loan_uuid, kind, request_id, previous_return_date, return_date)
+				SELECT uuid, 'expired', $2, return_date, return_date FROM expired
+			)
+			SELECT book_uuid FROM expired
+		`
+		rows, err := tx.Query(ctx, queryExpireLoans, batchSize, requestID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to expire loans")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		returnedCopies := map[uuid.UUID]int{}
+		for rows.Next() {
+			var bookUUID uuid.UUID
+			if err := rows.Scan(&bookUUID); err != nil {
+				rows.Close()
+				log.Error().Err(err).Msg("failed to scan expired loan")
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+			returnedCopies[bookUUID]++
+			expiredCount++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			log.Error().Err(err).Msg("failed to iterate expired loans")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		if err := releaseCopiesInOrder(ctx, tx, returnedCopies); err != nil {
+			log.Error().Err(err).Msg("failed to restore the copies of expired loans")
+			return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		return nil
+	})
+	if restErr != nil {
+		return 0, restErr
+	}
+
+	if expiredCount > 0 {
+		log.Info().Msgf("expired %d loan(s)", expiredCount)
+	}
+	return expiredCount, nil
+}
+
+func (lr LoanRepository) GetLoans(requestID string, userID int64, params dto.LoanListRequest) (*dto.LoanListResponse, *apperrors.RestErr) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	policy, err := getLoanPolicy(ctx, lr.dbpool, userID)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		log.Error().Err(err).Msg("failed to get loan policy")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	// Active loans come first, by due date; ended loans follow, most recently ended first.
+	const queryGetLoans = `
+		SELECT b.title,` + loanDetailColumns + `, count(*) OVER () AS total
+		FROM loans l
+		JOIN books b ON b.uuid = l.book_uuid
+		WHERE l.user_id = $1
+			AND ($2 = 'all' OR l.is_returned = ($2 = 'returned'))
+		ORDER BY l.is_returned, CASE WHEN l.is_returned THEN NULL ELSE l.return_date END,
+			COALESCE(l.returned_at, l.return_date) DESC, l.uuid
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := lr.dbpool.Query(ctx, queryGetLoans, userID, params.Status, params.Limit, params.Offset)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		log.Error().Err(err).Msg("failed to get loans")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	loanList := &dto.LoanListResponse{Loans: []dto.LoanDetail{}}
+	for rows.Next() {
+		var loanDetail dto.LoanDetail
+		var state loanState
+		scanTargets := append([]any{&loanDetail.BookTitle}, loanDetailScanTargets(&loanDetail, &state)...)
+		if err := rows.Scan(append(scanTargets, &loanList.Total)...); err != nil {
+			log.Error().Err(err).Msg("failed to scan loan")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		applyLoanPolicy(&loanDetail, state, policy, now)
+		loanList.Loans = append(loanList.Loans, loanDetail)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed to iterate loans")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return loanList, nil
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const errMsgLoanNotFound = "loan not found"
+
+// insertLoanEvent appends an event to the history of a loan, in the transaction that changed the loan.
+func insertLoanEvent(ctx context.Context, tx pgx.Tx, loanUUID uuid.UUID, event dto.LoanEvent) error {
+	const execInsertLoanEvent = `
+		INSERT INTO loanevents (loan_uuid, kind, actor_user_id, request_id, previous_return_date, return_date)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := tx.Exec(ctx, execInsertLoanEvent, loanUUID, event.Kind, event.ActorUserID, event.RequestID,
+		event.PreviousReturnDate, event.ReturnDate)
+	if err != nil {
+		return fmt.Errorf("error recording the %s event of loan '%s': %w", event.Kind, loanUUID, err)
+	}
+
+	return nil
+}
+
+func (lr LoanRepository) GetLoanHistory(requestID string, userID int64, loanUUID uuid.UUID) (*dto.LoanHistoryResponse, *apperrors.RestErr) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	// A loan of another user is not found, so that its uuid does not reveal that it exists.
+	var exists bool
+	const queryLoanExists = "SELECT TRUE FROM loans WHERE uuid = $1 AND user_id = $2"
+	if err := lr.dbpool.QueryRow(ctx, queryLoanExists, loanUUID, userID).Scan(&exists); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError(errMsgLoanNotFound)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		log.Error().Err(err).Msg("failed to get loan")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	const queryGetLoanEvents = `
+		SELECT kind, occurred_at, actor_user_id, request_id, previous_return_date, return_date
+		FROM loanevents
+		WHERE loan_uuid = $1
+		ORDER BY id
+	`
+	rows, err := lr.dbpool.Query(ctx, queryGetLoanEvents, loanUUID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get loan events")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	defer rows.Close()
+
+	loanHistory := &dto.LoanHistoryResponse{LoanUUID: loanUUID, Events: []dto.LoanEvent{}}
+	for rows.Next() {
+		var event dto.LoanEvent
+		err := rows.Scan(&event.Kind, &event.OccurredAt, &event.ActorUserID, &event.RequestID,
+			&event.PreviousReturnDate, &event.ReturnDate)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan loan event")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		loanHistory.Events = append(loanHistory.Events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed to iterate loan events")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return loanHistory, nil
+}
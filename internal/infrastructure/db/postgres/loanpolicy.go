@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/jackc/pgx/v5"
+)
+
+const queryGetLoanPolicy = `
+	SELECT p.patron_category, p.loan_days, p.extension_days, p.max_renewals, p.max_active_loans, p.renewal_window_days,
+		p.hold_offer_hours
+	FROM users u
+	JOIN loanpolicies p ON p.patron_category = u.patron_category
+	WHERE u.id = $1
+`
+
+// rowQuerier is implemented by both the pool and a transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// getLoanPolicy returns the loan policy of the patron category of the user.
+func getLoanPolicy(ctx context.Context, db rowQuerier, userID int64) (*entity.LoanPolicy, error) {
+	return scanLoanPolicy(db.QueryRow(ctx, queryGetLoanPolicy, userID))
+}
+
+// lockLoanPolicy returns the loan policy of the user like getLoanPolicy, and locks the user until the transaction ends,
+// so that the active loans of the user are counted one borrow at a time.
+func lockLoanPolicy(ctx context.Context, tx pgx.Tx, userID int64) (*entity.LoanPolicy, error) {
+	return scanLoanPolicy(tx.QueryRow(ctx, queryGetLoanPolicy+"FOR NO KEY UPDATE OF u", userID))
+}
+
+func scanLoanPolicy(row pgx.Row) (*entity.LoanPolicy, error) {
+	policy := &entity.LoanPolicy{}
+	err := row.Scan(
+		&policy.PatronCategory, &policy.LoanDays, &policy.ExtensionDays,
+		&policy.MaxRenewals, &policy.MaxActiveLoans, &policy.RenewalWindowDays,
+		&policy.HoldOfferHours,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
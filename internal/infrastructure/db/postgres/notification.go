@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// dueSoonWindow is how long before its due date a loan is notified as due soon.
+	dueSoonWindow = 3 * 24 * time.Hour
+	// expiredNoticeWindow bounds how long after a loan expired its notification is still worth sending.
+	expiredNoticeWindow = 7 * 24 * time.Hour
+	// maxNotificationAttempts is how many times an email is tried before it is given up.
+	maxNotificationAttempts = 5
+	// notificationRetryBackoff is how long a failed email waits per attempt so far, so that the attempts are
+	// spread over an outage of the SMTP server rather than spent within one run of the worker.
+	notificationRetryBackoff = 5 * time.Minute
+	// notificationLease is how long a claimed email may be sending before its claim is presumed lost, e.g.
+	// because the instance that claimed it stopped, and the email is claimed again.
+	notificationLease = 10 * time.Minute
+)
+
+// The borrower, email and title of the claimed notifications.
+const queryClaimedLoanNotifications = `
+	SELECT c.loan_uuid, c.kind, c.return_date, u.email, l.name_of_borrower, b.title
+	FROM claimed c
+	JOIN loans l ON l.uuid = c.loan_uuid
+	JOIN users u ON u.id = l.user_id
+	JOIN books b ON b.uuid = l.book_uuid
+	ORDER BY c.return_date
+`
+
+type NotificationRepository struct {
+	dbpool *pgxpool.Pool
+}
+
+func NewNotificationRepository(dbpool *pgxpool.Pool) repository.NotificationRepository {
+	return &NotificationRepository{dbpool}
+}
+
+func (nr NotificationRepository) ClaimLoanNotifications(requestID string, batchSize int) ([]dto.LoanNotification, *apperrors.RestErr) {
+	notifications := []dto.LoanNotification{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	// SKIP LOCKED and the primary key keep two instances from claiming the same notification.
+	const queryClaimFailedNotifications = `
+		WITH failed AS (
+			SELECT loan_uuid, kind, return_date FROM loannotifications
+			WHERE attempts < $2 AND (
+				(status = 'failed' AND claimed_at < NOW() - make_interval(secs => $4::double precision * attempts))
+				OR (status = 'sending' AND claimed_at < NOW() - make_interval(secs => $3))
+			)
+			ORDER BY claimed_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		),
+		claimed AS (
+			UPDATE loannotifications n SET status = 'sending', attempts = n.attempts + 1, claimed_at = NOW()
+			FROM failed f
+			WHERE n.loan_uuid = f.loan_uuid AND n.kind = f.kind AND n.return_date = f.return_date
+			RETURNING n.loan_uuid, n.kind, n.return_date
+		)
+	` + queryClaimedLoanNotifications
+
+	const queryClaimDueNotifications = `
+		WITH due AS (
+			SELECT l.uuid AS loan_uuid, 'due_soon' AS kind, l.return_date FROM loans l
+			WHERE l.is_returned = FALSE AND l.return_date > NOW() AND l.return_date <= NOW() + make_interval(secs => $2)
+			UNION ALL
+			SELECT l.uuid, 'extended', l.return_date FROM loans l
+			WHERE l.is_returned = FALSE AND l.is_extended = TRUE
+			UNION ALL
+			SELECT l.uuid, 'expired', l.return_date FROM loans l
+			WHERE l.return_reason = 'expired' AND l.returned_at > NOW() - make_interval(secs => $3)
+		),
+		claimed AS (
+			INSERT INTO loannotifications (loan_uuid, kind, return_date)
+			SELECT d.loan_uuid, d.kind, d.return_date FROM due d
+			WHERE NOT EXISTS (
+				SELECT 1 FROM loannotifications n
+				WHERE n.loan_uuid = d.loan_uuid AND n.kind = d.kind AND n.return_date = d.return_date
+			)
+			LIMIT $1
+			ON CONFLICT DO NOTHING
+			RETURNING loan_uuid, kind, return_date
+		)
+	` + queryClaimedLoanNotifications
+
+	restErr := runInTransaction(ctx, nr.dbpool, func(tx pgx.Tx) *apperrors.RestErr {
+		claims := []struct {
+			query string
+			args  []any
+		}{
+			{queryClaimFailedNotifications, []any{batchSize, maxNotificationAttempts, notificationLease.Seconds(),
+				notificationRetryBackoff.Seconds()}},
+			{queryClaimDueNotifications, []any{0, dueSoonWindow.Seconds(), expiredNoticeWindow.Seconds()}},
+		}
+
+		for _, claim := range claims {
+			remaining := batchSize - len(notifications)
+			if remaining <= 0 {
+				break
+			}
+			claim.args[0] = remaining
+
+			rows, err := tx.Query(ctx, claim.query, claim.args...)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to claim loan notifications")
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+
+			for rows.Next() {
+				var notification dto.LoanNotification
+				err := rows.Scan(&notification.LoanUUID, &notification.Kind, &notification.ReturnDate,
+					&notification.Email, &notification.NameOfBorrower, &notification.BookTitle)
+				if err != nil {
+					rows.Close()
+					log.Error().Err(err).Msg("failed to scan loan notification")
+					return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+				}
+				notifications = append(notifications, notification)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				log.Error().Err(err).Msg("failed to iterate loan notifications")
+				return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+			}
+		}
+		return nil
+	})
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	return notifications, nil
+}
+
+func (nr NotificationRepository) MarkLoanNotificationSent(requestID string, notification dto.LoanNotification) *apperrors.RestErr {
+	const execMarkSent = `
+		UPDATE loannotifications SET status = 'sent', sent_at = NOW(), last_error = ''
+		WHERE loan_uuid = $1 AND kind = $2 AND return_date = $3
+	`
+	return nr.updateLoanNotification(requestID, execMarkSent, notification.LoanUUID, notification.Kind, notification.ReturnDate)
+}
+
+func (nr NotificationRepository) MarkLoanNotificationFailed(requestID string, notification dto.LoanNotification, reason string) *apperrors.RestErr {
+	const execMarkFailed = `
+		UPDATE loannotifications SET status = 'failed', last_error = $4
+		WHERE loan_uuid = $1 AND kind = $2 AND return_date = $3
+	`
+	return nr.updateLoanNotification(requestID, execMarkFailed, notification.LoanUUID, notification.Kind, notification.ReturnDate, reason)
+}
+
+func (nr NotificationRepository) updateLoanNotification(requestID string, query string, args ...any) *apperrors.RestErr {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	if _, err := nr.dbpool.Exec(ctx, query, args...); err != nil {
+		log.Error().Err(err).Msg("failed to update loan notification")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultSearchLanguage = "en"
+
+	// ts_headline does not escape the document, so the markers are restored after HTML escaping the snippet.
+	highlightStartSel = "<mark>"
+	highlightStopSel  = "</mark>"
+)
+
+var highlightRestorer = strings.NewReplacer(
+	html.EscapeString(highlightStartSel), highlightStartSel,
+	html.EscapeString(highlightStopSel), highlightStopSel,
+)
+
+/*
+SearchRepository keeps a tsvector document per book in `BookSearchDocuments`.
+Each document is stemmed with the text search configuration of the book's language (see `elib_regconfig`),
+and weighs title matches above author matches above description matches.
+*/
+type SearchRepository struct {
+	dbpool *pgxpool.Pool
+}
+
+func NewSearchRepository(dbpool *pgxpool.Pool) repository.SearchRepository {
+	return &SearchRepository{dbpool}
+}
+
+func (sr SearchRepository) IndexBooks(requestID string, bookUUIDs ...uuid.UUID) *apperrors.RestErr {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	const execIndexBooks = `
+		INSERT INTO BookSearchDocuments (book_uuid, language, title, authors, description, document, updated_at)
+		SELECT source.uuid, source.language, source.title, source.authors, source.description,
+			setweight(to_tsvector(elib_regconfig(source.language), source.title), 'A') ||
+			setweight(to_tsvector(elib_regconfig(source.language), source.authors), 'B') ||
+			setweight(to_tsvector(elib_regconfig(source.language), source.description), 'C'),
+			NOW()
+		FROM (
+			SELECT b.uuid, b.language, b.title,
+				COALESCE((
+					SELECT string_agg(a.name, ', ' ORDER BY ba.position)
+					FROM BookAuthors ba JOIN Authors a ON a.id = ba.author_id
+					WHERE ba.book_uuid = b.uuid
+				), '') AS authors,
+				b.description
+			FROM books b
+			WHERE b.withdrawn_at IS NULL
+				AND (cardinality($1::uuid[]) = 0 OR b.uuid = ANY($1::uuid[]))
+		) source
+		ON CONFLICT (book_uuid) DO UPDATE
+		SET language = EXCLUDED.language,
+			title = EXCLUDED.title,
+			authors = EXCLUDED.authors,
+			description = EXCLUDED.description,
+			document = EXCLUDED.document,
+			updated_at = EXCLUDED.updated_at
+	`
+	if bookUUIDs == nil {
+		bookUUIDs = []uuid.UUID{}
+	}
+
+	commandTag, err := sr.dbpool.Exec(ctx, execIndexBooks, bookUUIDs)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		log.Error().Err(err).Msg("failed to index books")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	log.Info().Msgf("indexed %d book(s) for full-text search", commandTag.RowsAffected())
+	return nil
+}
+
+func (sr SearchRepository) RemoveBooks(requestID string, bookUUIDs ...uuid.UUID) *apperrors.RestErr {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	const execRemoveBooks = "DELETE FROM BookSearchDocuments WHERE book_uuid = ANY($1::uuid[])"
+	_, err := sr.dbpool.Exec(ctx, execRemoveBooks, bookUUIDs)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		log.Error().Err(err).Msg("failed to remove books from the search index")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return nil
+}
+
+func (sr SearchRepository) SearchBooks(requestID string, params dto.FullTextSearchRequest) (*dto.FullTextSearchResponse, *apperrors.RestErr) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, entity.RequestIDKey, requestID)
+
+	// The query is stemmed with the requested language, or English when no language is given.
+	// Only a requested language restricts the documents that are searched.
+	stemmingLanguage := params.Language
+	if stemmingLanguage == "" {
+		stemmingLanguage = defaultSearchLanguage
+	}
+
+	// Highlights are computed only for the requested page, since ts_headline works on the original text.
+	const querySearchBooks = `
+		WITH query AS (
+			SELECT websearch_to_tsquery(elib_regconfig($2), $1) AS tsq
+		), hits AS (
+			SELECT d.book_uuid, d.title, d.authors, d.description,
+				ts_rank_cd(d.document, query.tsq)::float8 AS rank,
+				count(*) OVER () AS total
+			FROM BookSearchDocuments d, query
+			WHERE d.document @@ query.tsq
+				AND ($3 = '' OR d.language = $3)
+			ORDER BY rank DESC, d.book_uuid
+			LIMIT $4 OFFSET $5
+		)
+		SELECT` + bookDetailColumns + `, hits.rank, hits.total,
+			ts_headline(elib_regconfig($2), hits.title, query.tsq,
+				'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline(elib_regconfig($2), hits.authors, query.tsq,
+				'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline(elib_regconfig($2), hits.description, query.tsq,
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" ... "')
+		FROM hits
+		JOIN books b ON b.uuid = hits.book_uuid AND b.withdrawn_at IS NULL
+		CROSS JOIN query
+		ORDER BY hits.rank DESC, hits.book_uuid
+	`
+	rows, err := sr.dbpool.Query(ctx, querySearchBooks,
+		params.Query, stemmingLanguage, params.Language, params.Limit, params.Offset)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Err(err).Msg(errMsgContextTimeout)
+			return nil, apperrors.NewInternalServerError(errMsgContextTimeout)
+		}
+
+		log.Error().Err(err).Msg("failed to search books")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	defer rows.Close()
+
+	searchResponse := &dto.FullTextSearchResponse{Hits: []dto.FullTextSearchHit{}}
+	for rows.Next() {
+		var hit dto.FullTextSearchHit
+		scanTargets := append(bookDetailScanTargets(&hit.Book), &hit.Rank, &searchResponse.Total,
+			&hit.Highlights.Title, &hit.Highlights.Authors, &hit.Highlights.Description)
+		if err := rows.Scan(scanTargets...); err != nil {
+			log.Error().Err(err).Msg("failed to scan search hit")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		hit.Highlights.Title = sanitizeHighlight(hit.Highlights.Title)
+		hit.Highlights.Authors = sanitizeHighlight(hit.Highlights.Authors)
+		hit.Highlights.Description = sanitizeHighlight(hit.Highlights.Description)
+		searchResponse.Hits = append(searchResponse.Hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("failed to iterate search hits")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return searchResponse, nil
+}
+
+// sanitizeHighlight escapes the book metadata so that only the highlight markers remain as markup.
+func sanitizeHighlight(headline string) string {
+	return highlightRestorer.Replace(html.EscapeString(headline))
+}
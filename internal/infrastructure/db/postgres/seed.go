@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/DarrelA/e-lib/config"
 	"github.com/DarrelA/e-lib/internal/domain/entity"
 	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
@@ -68,20 +70,35 @@ func (sr SeedRepository) SeedBooks() error {
 	}
 
 	err = sr.executeTransaction(func(tx pgx.Tx) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		const insertBooksStmt = `
-			INSERT INTO Books (uuid, title, available_copies, created_at, updated_at)
-			VALUES (gen_random_uuid(), lower($1), $2, NOW(), NOW())
-			ON CONFLICT (title) DO NOTHING;  -- Skip duplicates based on title
+		const queryInsertBook = `
+			INSERT INTO Books (uuid, isbn_13, isbn_10, title, edition, publisher, language, publication_year,
+				description, total_copies, available_copies, created_at, updated_at)
+			VALUES (gen_random_uuid(), $1, NULLIF($2, ''), lower($3), $4, $5, COALESCE(NULLIF($6, ''), 'en'), NULLIF($7, 0),
+				$8, $9, $9, NOW(), NOW())
+			ON CONFLICT (isbn_13) DO NOTHING  -- Skip duplicates based on ISBN-13
+			RETURNING uuid
 		`
 		for _, book := range books {
-			_, err := tx.Exec(ctx, insertBooksStmt, strings.ToLower(book.Title), book.AvailableCopies)
+			var bookUUID uuid.UUID
+			err := tx.QueryRow(ctx, queryInsertBook,
+				book.ISBN13, book.ISBN10, strings.ToLower(book.Title), book.Edition, book.Publisher,
+				strings.ToLower(book.Language), book.PublicationYear, book.Description, book.TotalCopies).
+				Scan(&bookUUID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue // Already seeded
+			}
 			if err != nil {
 				log.Error().Err(err).Msg(fmt.Sprintf("error inserting book '%s'", book.Title))
 				return fmt.Errorf("error inserting book '%s': %w", book.Title, err)
 			}
+
+			if err := saveBookAuthors(ctx, tx, bookUUID, book.Authors); err != nil {
+				log.Error().Err(err).Msg("")
+				return err
+			}
 		}
 
 		log.Info().Msgf("books seeded successfully using %s", sr.config.PathToBooksJsonFile)
@@ -97,7 +114,7 @@ func (sr SeedRepository) SeedBooks() error {
 type TxFunc func(pgx.Tx) error
 
 func (sr SeedRepository) executeTransaction(txFunc TxFunc) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	tx, err := sr.dbpool.Begin(ctx)
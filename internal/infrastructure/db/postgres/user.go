@@ -15,6 +15,8 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const errMsgEmailTaken = "the email is already used by another account; login with that account instead"
+
 type UserRepository struct {
 	dbpool *pgxpool.Pool
 }
@@ -27,11 +29,11 @@ func (r *UserRepository) GetUserByID(userID int64) (dto.UserDetail, *apperrors.R
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	query := "SELECT id, name, email FROM users WHERE id = $1"
+	query := "SELECT id, name, email, role FROM users WHERE id = $1"
 	row := r.dbpool.QueryRow(ctx, query, userID)
 
 	user := dto.UserDetail{}
-	err := row.Scan(&user.ID, &user.Name, &user.Email)
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role)
 	if err != nil {
 		if err == context.DeadlineExceeded {
 			log.Ctx(ctx).Error().Msg(errMsgContextTimeout)
@@ -49,14 +51,16 @@ func (r *UserRepository) GetUserByID(userID int64) (dto.UserDetail, *apperrors.R
 	return user, nil
 }
 
-func (ur UserRepository) GetUserID(provider string, id string, email string) (int64, *apperrors.RestErr) {
+// GetUserID returns the user of a subject at a provider, or -1 for a subject that has not logged in yet. The
+// email at the provider may change, so it does not identify the account.
+func (ur UserRepository) GetUserID(provider string, id string) (int64, *apperrors.RestErr) {
 	var user_id int64
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	const queryGetUserFromAuth = "SELECT user_id FROM AuthProviders WHERE provider=$1 AND id=$2 AND email=$3;"
-	err := ur.dbpool.QueryRow(ctx, queryGetUserFromAuth, provider, id, email).Scan(&user_id)
+	const queryGetUserFromAuth = "SELECT user_id FROM AuthProviders WHERE provider=$1 AND id=$2;"
+	err := ur.dbpool.QueryRow(ctx, queryGetUserFromAuth, provider, id).Scan(&user_id)
 
 	if err != nil {
 		if err == context.DeadlineExceeded {
@@ -76,6 +80,54 @@ func (ur UserRepository) GetUserID(provider string, id string, email string) (in
 	return user_id, nil // return user_id and *nil* for error.
 }
 
+// GetUserIDByEmail returns the user with an email, or -1 when there is none.
+func (ur UserRepository) GetUserIDByEmail(email string) (int64, *apperrors.RestErr) {
+	var user_id int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const queryGetUserByEmail = "SELECT id FROM Users WHERE email=$1;"
+	err := ur.dbpool.QueryRow(ctx, queryGetUserByEmail, email).Scan(&user_id)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return -1, nil
+		}
+
+		log.Error().Err(err).Msg("failed to get user by email")
+		return -1, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return user_id, nil
+}
+
+// LinkProvider lets a user log in with their account at another provider.
+func (ur UserRepository) LinkProvider(userID int64, user *dto.GoogleOAuth2UserRes, provider string) *apperrors.RestErr {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := ur.dbpool.Exec(ctx, execInsertAuthProviders, user.ID, userID, user.Name, user.Email, user.VerifiedEmail, provider)
+	if err != nil {
+		// Another account at the provider already has the email.
+		if isUniqueViolation(err) {
+			return apperrors.NewConflictError(errMsgEmailTaken)
+		}
+
+		log.Error().Err(err).Msg("error linking user in AuthProviders table")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return nil
+}
+
+const execInsertAuthProviders = `
+	INSERT INTO AuthProviders (id, user_id, name, email, verified_email, provider, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+`
+
+// SaveUser adds a user who joins with their account at a provider. An email that another user has is a
+// conflict, which the caller resolves by linking a verified email instead.
 func (ur UserRepository) SaveUser(user *dto.GoogleOAuth2UserRes, provider string) (*entity.User, *apperrors.RestErr) {
 	newUser := &entity.User{}
 
@@ -101,27 +153,29 @@ func (ur UserRepository) SaveUser(user *dto.GoogleOAuth2UserRes, provider string
 		}
 	}()
 
-	const queryInsertUsers = "INSERT INTO Users (name, email, created_at, updated_at) VALUES ($1, $2, NOW(), NOW()) returning id, name, email, created_at, updated_at"
+	const queryInsertUsers = "INSERT INTO Users (name, email, created_at, updated_at) VALUES ($1, $2, NOW(), NOW()) returning id, name, email, role, patron_category, created_at, updated_at"
 	err = tx.QueryRow(ctx, queryInsertUsers, user.Name, user.Email).
-		Scan(&newUser.ID, &newUser.Name, &newUser.Email, &newUser.CreatedAt, &newUser.UpdatedAt)
+		Scan(&newUser.ID, &newUser.Name, &newUser.Email, &newUser.Role, &newUser.PatronCategory, &newUser.CreatedAt, &newUser.UpdatedAt)
 
 	if err != nil {
 		log.Error().Err(err).Msg("error saving new user into Users table")
 		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		if isUniqueViolation(err) {
+			rErr = apperrors.NewConflictError(errMsgEmailTaken)
+		}
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
 		}
 		return nil, rErr
 	}
 
-	const execInsertAuthProviders = `
-		INSERT INTO AuthProviders (id, user_id, name, email, verified_email, provider, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
-	`
 	_, err = tx.Exec(ctx, execInsertAuthProviders, user.ID, newUser.ID, user.Name, user.Email, user.VerifiedEmail, provider)
 	if err != nil {
 		log.Error().Err(err).Msg("error saving new user into AuthProviders table")
 		rErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		if isUniqueViolation(err) {
+			rErr = apperrors.NewConflictError(errMsgEmailTaken)
+		}
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			log.Error().Err(rbErr).Msg(errMsgFailedToRollbackTransaction)
 		}
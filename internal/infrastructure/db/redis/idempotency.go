@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	idempotencyPrefix  = "idempotency:"
+	idempotencyTTL     = 24 * time.Hour   // How long a key is remembered, and its response replayed
+	idempotencyLockTTL = 30 * time.Second // Outlasts a request, so that a key is not stuck when its request dies
+)
+
+type IdempotencyRepository struct {
+	redisClient *redis.Client
+	ctx         context.Context
+}
+
+func NewIdempotencyRepository(redisClient *redis.Client) repository.IdempotencyRepository {
+	ctx := context.Background()
+	return &IdempotencyRepository{redisClient, ctx}
+}
+
+func (ir IdempotencyRepository) Reserve(key string, fingerprint string) (*entity.IdempotencyRecord, *apperrors.RestErr) {
+	redisKey := idempotencyPrefix + key
+	value, err := json.Marshal(entity.IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode idempotency record")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	// The key may expire between the two commands, in which case it is claimed on the second attempt.
+	for attempt := 0; attempt < 2; attempt++ {
+		claimed, err := ir.redisClient.SetNX(ir.ctx, redisKey, value, idempotencyLockTTL).Result()
+		if err != nil {
+			log.Error().Err(err).Msg("failed to reserve idempotency key")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		if claimed {
+			return nil, nil
+		}
+
+		stored, err := ir.redisClient.Get(ir.ctx, redisKey).Bytes()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			log.Error().Err(err).Msg("failed to get idempotency record")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		record := &entity.IdempotencyRecord{}
+		if err := json.Unmarshal(stored, record); err != nil {
+			log.Error().Err(err).Msg("failed to decode idempotency record")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		return record, nil
+	}
+
+	log.Error().Msgf("idempotency key '%s' expired while it was being reserved", key)
+	return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+}
+
+func (ir IdempotencyRepository) Complete(key string, record *entity.IdempotencyRecord) *apperrors.RestErr {
+	value, err := json.Marshal(record)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode idempotency record")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	// The reservation is extended to keep the response; a key whose reservation has expired stays forgotten.
+	err = ir.redisClient.SetArgs(ir.ctx, idempotencyPrefix+key, value, redis.SetArgs{Mode: "XX", TTL: idempotencyTTL}).Err()
+	if err != nil && err != redis.Nil {
+		log.Error().Err(err).Msg("failed to store idempotent response")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return nil
+}
+
+func (ir IdempotencyRepository) Release(key string) *apperrors.RestErr {
+	if err := ir.redisClient.Del(ir.ctx, idempotencyPrefix+key).Err(); err != nil {
+		log.Error().Err(err).Msg("failed to release idempotency key")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return nil
+}
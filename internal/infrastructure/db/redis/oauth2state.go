@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	oauth2StatePrefix = "oauth2_state:"
+	oauth2StateTTL    = 10 * time.Minute // How long the user has to log in with the provider
+)
+
+type OAuth2StateRepository struct {
+	redisClient *redis.Client
+	ctx         context.Context
+}
+
+func NewOAuth2StateRepository(redisClient *redis.Client) repository.OAuth2StateRepository {
+	ctx := context.Background()
+	return &OAuth2StateRepository{redisClient, ctx}
+}
+
+func (or OAuth2StateRepository) SaveLoginState(state string, loginState *entity.OAuth2LoginState) *apperrors.RestErr {
+	value, err := json.Marshal(loginState)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode oauth2 login state")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	if err := or.redisClient.Set(or.ctx, oauth2StatePrefix+state, value, oauth2StateTTL).Err(); err != nil {
+		log.Error().Err(err).Msg("failed to store oauth2 login state")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return nil
+}
+
+func (or OAuth2StateRepository) TakeLoginState(state string) (*entity.OAuth2LoginState, *apperrors.RestErr) {
+	stored, err := or.redisClient.GetDel(or.ctx, oauth2StatePrefix+state).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		log.Error().Err(err).Msg("failed to get oauth2 login state")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	loginState := &entity.OAuth2LoginState{}
+	if err := json.Unmarshal(stored, loginState); err != nil {
+		log.Error().Err(err).Msg("failed to decode oauth2 login state")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return loginState, nil
+}
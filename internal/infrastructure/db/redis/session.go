@@ -1,8 +1,9 @@
 package redis
 
 import (
+	"cmp"
 	"context"
-	"fmt"
+	"slices"
 	"strconv"
 	"time"
 
@@ -15,8 +16,9 @@ import (
 )
 
 const (
-	sessionPrefix = "session:"
-	sessionTTL    = 24 * time.Hour // Session Time To Live
+	sessionPrefix      = "session:"
+	userSessionsPrefix = "user_sessions:" // Hash of the IDs of the sessions of a user to their session IDs
+	maxUserAgentLength = 255
 
 	errMsgLoginAgain      = "please login again"
 	errMsgSessionNotFound = "session not found"
@@ -24,54 +26,279 @@ const (
 
 type SessionRepository struct {
 	redisClient *redis.Client
+	config      *entity.SessionConfig
 	ctx         context.Context
 }
 
-func NewSessionRepository(redisClient *redis.Client) repository.SessionRepository {
+func NewSessionRepository(redisClient *redis.Client, config *entity.SessionConfig) repository.SessionRepository {
 	ctx := context.Background()
-	return &SessionRepository{redisClient, ctx}
+	return &SessionRepository{redisClient, config, ctx}
 }
 
-func (sr SessionRepository) NewSession(userID int64) (string, *apperrors.RestErr) {
+// NewSession starts a session, which lives for its idle timeout unless it is refreshed, and returns its
+// session ID and how long it lives.
+func (sr SessionRepository) NewSession(userID int64, role string, client entity.SessionClient, rememberMe bool,
+) (string, time.Duration, *apperrors.RestErr) {
 	sessionID := uuid.New().String()
 	sessionKey := sessionPrefix + sessionID
 
-	userIDString := strconv.FormatInt(userID, 10)       // Convert int64 to string
-	createdAt := time.Now().Unix()                      // Get Unix timestamp
-	createdAtString := strconv.FormatInt(createdAt, 10) // Convert Unix timestamp to string
-	sessionData := map[string]interface{}{              // Use a map to pass values
-		"userID":    userIDString,
-		"createdAt": createdAtString,
+	// The session ID is the secret of the cookie, so the session is shown and revoked by another ID.
+	id := uuid.New().String()
+
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
 	}
 
-	err := sr.redisClient.HSet(sr.ctx, sessionKey, sessionData).Err()
-	if err != nil {
-		log.Error().Err(err).Msg("")
-		return "", apperrors.NewInternalServerError("failed to store session in Redis")
+	now := time.Now()
+	idleTimeout, absoluteLifetime := sr.config.Timeouts(rememberMe)
+	expiresAt := now.Add(absoluteLifetime)
+	ttl := entity.SessionTTL(idleTimeout, expiresAt, now)
+
+	userIDString := strconv.FormatInt(userID, 10)              // Convert int64 to string
+	createdAtString := strconv.FormatInt(now.Unix(), 10)       // Convert Unix timestamp to string
+	expiresAtString := strconv.FormatInt(expiresAt.Unix(), 10) // Convert Unix timestamp to string
+	sessionData := map[string]interface{}{                     // Use a map to pass values
+		"id":         id,
+		"userID":     userIDString,
+		"createdAt":  createdAtString,
+		"expiresAt":  expiresAtString,
+		"rememberMe": strconv.FormatBool(rememberMe),
+		"role":       role,
+		"ip":         client.IP,
+		"userAgent":  userAgent,
 	}
 
-	err = sr.redisClient.Expire(sr.ctx, sessionKey, sessionTTL).Err()
+	// The index lives as long as the longest session could.
+	userSessionsKey := userSessionsPrefix + userIDString
+	indexTTL := max(sr.config.AbsoluteLifetime, sr.config.RememberMeAbsoluteLifetime)
+	_, err := sr.redisClient.TxPipelined(sr.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(sr.ctx, sessionKey, sessionData)
+		pipe.Expire(sr.ctx, sessionKey, ttl)
+		pipe.HSet(sr.ctx, userSessionsKey, id, sessionID)
+		pipe.Expire(sr.ctx, userSessionsKey, indexTTL)
+		return nil
+	})
 	if err != nil {
-		log.Warn().Msg(fmt.Sprintf("failed to set session expiration: %s", err))
-		return "", apperrors.NewInternalServerError("failed to set session expiration")
+		log.Error().Err(err).Msg("")
+		return "", 0, apperrors.NewInternalServerError("failed to store session in Redis")
 	}
 
-	return sessionID, nil
+	return sessionID, ttl, nil
 }
 
 func (r *SessionRepository) GetSessionData(sessionID string) (*entity.Session, *apperrors.RestErr) {
 	ctx := context.Background()
-	key := "session:" + sessionID
+	key := sessionPrefix + sessionID
 
 	sessionDataMap, err := r.redisClient.HGetAll(ctx, key).Result() // Changed to HGetAll
-	if err == redis.Nil {
-		log.Error().Err(err).Msg(errMsgSessionNotFound)
-		return nil, apperrors.NewInternalServerError(errMsgSessionNotFound)
-	} else if err != nil {
+	if err != nil {
 		log.Error().Err(err).Msg("failed to retrieve session data")
 		return nil, apperrors.NewInternalServerError(errMsgLoginAgain)
 	}
 
+	// A session that expired, or was revoked, is an empty hash.
+	if len(sessionDataMap) == 0 {
+		log.Info().Msg(errMsgSessionNotFound)
+		return nil, apperrors.NewUnauthorizedError(errMsgLoginAgain)
+	}
+
+	sessionData, restErr := toSession(sessionDataMap)
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	// The idle timeout never outlasts the absolute lifetime, so this only catches a clock skew.
+	if !time.Now().Before(r.expiresAt(sessionData)) {
+		log.Info().Msg("session has reached its absolute lifetime")
+		return nil, apperrors.NewUnauthorizedError(errMsgLoginAgain)
+	}
+
+	return sessionData, nil
+}
+
+// RefreshSession restarts the idle timeout of a session, up to its absolute lifetime, and returns how long
+// it lives. When the role of the user has changed since the session ID was issued, the session moves to a
+// new session ID, which is returned, so that a cookie taken before the change does not carry the new role.
+func (sr SessionRepository) RefreshSession(sessionID string, session *entity.Session, role string,
+) (string, time.Duration, *apperrors.RestErr) {
+	idleTimeout, _ := sr.config.Timeouts(session.RememberMe)
+	ttl := entity.SessionTTL(idleTimeout, sr.expiresAt(session), time.Now())
+	if ttl <= 0 {
+		return "", 0, apperrors.NewUnauthorizedError(errMsgLoginAgain)
+	}
+
+	// A session from before roles were recorded is not rotated.
+	sessionKey := sessionPrefix + sessionID
+	if session.Role == role || session.Role == "" {
+		refreshed, err := sr.redisClient.Expire(sr.ctx, sessionKey, ttl).Result()
+		if err != nil {
+			log.Error().Err(err).Msg("failed to refresh session")
+			return "", 0, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+		if !refreshed {
+			return "", 0, apperrors.NewUnauthorizedError(errMsgLoginAgain) // Revoked since it was read
+		}
+		return sessionID, ttl, nil
+	}
+
+	log.Info().Msgf("rotating the session of user %s, whose role changed from [%s] to [%s]", session.UserID, session.Role, role)
+	newSessionID := uuid.New().String()
+	newSessionKey := sessionPrefix + newSessionID
+
+	// The session is watched so that a session revoked, or rotated by a parallel request, is not moved.
+	err := sr.redisClient.Watch(sr.ctx, func(tx *redis.Tx) error {
+		exists, err := tx.Exists(sr.ctx, sessionKey).Result()
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return redis.Nil
+		}
+
+		_, err = tx.TxPipelined(sr.ctx, func(pipe redis.Pipeliner) error {
+			pipe.Rename(sr.ctx, sessionKey, newSessionKey)
+			pipe.HSet(sr.ctx, newSessionKey, "role", role)
+			pipe.Expire(sr.ctx, newSessionKey, ttl)
+			if session.ID != "" {
+				pipe.HSet(sr.ctx, userSessionsPrefix+session.UserID, session.ID, newSessionID)
+			}
+			return nil
+		})
+		return err
+	}, sessionKey)
+	if err == redis.Nil || err == redis.TxFailedErr {
+		return "", 0, apperrors.NewUnauthorizedError(errMsgLoginAgain)
+	} else if err != nil {
+		log.Error().Err(err).Msg("failed to rotate session")
+		return "", 0, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return newSessionID, ttl, nil
+}
+
+func (sr SessionRepository) GetUserSessions(userID int64) ([]entity.Session, *apperrors.RestErr) {
+	userSessionsKey := userSessionsPrefix + strconv.FormatInt(userID, 10)
+	sessionIDs, err := sr.redisClient.HGetAll(sr.ctx, userSessionsKey).Result()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to retrieve the sessions of the user")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	ids := make([]string, 0, len(sessionIDs))
+	cmds := make([]*redis.MapStringStringCmd, 0, len(sessionIDs))
+	pipe := sr.redisClient.Pipeline()
+	for id, sessionID := range sessionIDs {
+		ids = append(ids, id)
+		cmds = append(cmds, pipe.HGetAll(sr.ctx, sessionPrefix+sessionID))
+	}
+	if _, err := pipe.Exec(sr.ctx); err != nil {
+		log.Error().Err(err).Msg("failed to retrieve the sessions of the user")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	sessions := []entity.Session{}
+	var expiredIDs []string
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			expiredIDs = append(expiredIDs, ids[i])
+			continue
+		}
+
+		session, restErr := toSession(cmd.Val())
+		if restErr != nil {
+			return nil, restErr
+		}
+		sessions = append(sessions, *session)
+	}
+
+	// Sessions leave the index when they are revoked, or here once they have expired.
+	if len(expiredIDs) > 0 {
+		if err := sr.redisClient.HDel(sr.ctx, userSessionsKey, expiredIDs...).Err(); err != nil {
+			log.Warn().Err(err).Msg("failed to remove expired sessions from the index")
+		}
+	}
+
+	slices.SortFunc(sessions, func(a, b entity.Session) int {
+		createdAtA, _ := strconv.ParseInt(a.CreatedAt, 10, 64)
+		createdAtB, _ := strconv.ParseInt(b.CreatedAt, 10, 64)
+		return cmp.Compare(createdAtB, createdAtA)
+	})
+
+	return sessions, nil
+}
+
+func (sr SessionRepository) DeleteSession(userID int64, id string) *apperrors.RestErr {
+	userSessionsKey := userSessionsPrefix + strconv.FormatInt(userID, 10)
+
+	// A session of another user is not in the index of this user.
+	sessionID, err := sr.redisClient.HGet(sr.ctx, userSessionsKey, id).Result()
+	if err == redis.Nil {
+		return apperrors.NewNotFoundError(errMsgSessionNotFound)
+	} else if err != nil {
+		log.Error().Err(err).Msg("failed to retrieve the session of the user")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	_, err = sr.redisClient.TxPipelined(sr.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(sr.ctx, sessionPrefix+sessionID)
+		pipe.HDel(sr.ctx, userSessionsKey, id)
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete session")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return nil
+}
+
+// DeleteSessionData revokes a session by its session ID. A session from before sessions had IDs is not in
+// the index of its user, so it cannot be revoked by DeleteSession.
+func (sr SessionRepository) DeleteSessionData(sessionID string) *apperrors.RestErr {
+	if err := sr.redisClient.Del(sr.ctx, sessionPrefix+sessionID).Err(); err != nil {
+		log.Error().Err(err).Msg("failed to delete session")
+		return apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return nil
+}
+
+func (sr SessionRepository) DeleteUserSessions(userID int64, exceptID string) (int, *apperrors.RestErr) {
+	userSessionsKey := userSessionsPrefix + strconv.FormatInt(userID, 10)
+	sessionIDs, err := sr.redisClient.HGetAll(sr.ctx, userSessionsKey).Result()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to retrieve the sessions of the user")
+		return 0, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	var sessionKeys, ids []string
+	for id, sessionID := range sessionIDs {
+		if id != exceptID {
+			ids = append(ids, id)
+			sessionKeys = append(sessionKeys, sessionPrefix+sessionID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// Only the sessions that still existed are counted as revoked.
+	var deleted *redis.IntCmd
+	_, err = sr.redisClient.TxPipelined(sr.ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(sr.ctx, sessionKeys...)
+		pipe.HDel(sr.ctx, userSessionsKey, ids...)
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to delete the sessions of the user")
+		return 0, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	return int(deleted.Val()), nil
+}
+
+func toSession(sessionDataMap map[string]string) (*entity.Session, *apperrors.RestErr) {
 	userID, ok := sessionDataMap["userID"]
 	if !ok {
 		log.Error().Msg("userID not found in session data")
@@ -84,10 +311,28 @@ func (r *SessionRepository) GetSessionData(sessionID string) (*entity.Session, *
 		return nil, apperrors.NewInternalServerError(errMsgLoginAgain)
 	}
 
+	rememberMe, _ := strconv.ParseBool(sessionDataMap["rememberMe"])
 	sessionData := &entity.Session{
-		UserID:    userID,
-		CreatedAt: createdAt,
+		ID:         sessionDataMap["id"],
+		UserID:     userID,
+		CreatedAt:  createdAt,
+		ExpiresAt:  sessionDataMap["expiresAt"],
+		RememberMe: rememberMe,
+		Role:       sessionDataMap["role"],
+		IP:         sessionDataMap["ip"],
+		UserAgent:  sessionDataMap["userAgent"],
 	}
 
 	return sessionData, nil
 }
+
+// expiresAt is the end of the absolute lifetime of a session. A session from before it was recorded has
+// the lifetime of the config.
+func (sr SessionRepository) expiresAt(session *entity.Session) time.Time {
+	if expiresAt, err := strconv.ParseInt(session.ExpiresAt, 10, 64); err == nil {
+		return time.Unix(expiresAt, 0)
+	}
+
+	createdAt, _ := strconv.ParseInt(session.CreatedAt, 10, 64)
+	return time.Unix(createdAt, 0).Add(sr.config.AbsoluteLifetime)
+}
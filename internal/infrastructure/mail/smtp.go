@@ -0,0 +1,88 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+// SMTPMailer sends emails through an SMTP server, upgrading to TLS when the server offers STARTTLS.
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from mail.Address
+}
+
+func NewSMTPMailer(smtpConfig *entity.SMTPConfig) repository.Mailer {
+	var auth smtp.Auth
+	if smtpConfig.Username != "" {
+		auth = smtp.PlainAuth("", smtpConfig.Username, smtpConfig.Password, smtpConfig.Host)
+	}
+
+	from, err := mail.ParseAddress(smtpConfig.From)
+	if err != nil {
+		from = &mail.Address{Address: smtpConfig.From}
+	}
+
+	return &SMTPMailer{net.JoinHostPort(smtpConfig.Host, smtpConfig.Port), auth, *from}
+}
+
+func (m *SMTPMailer) SendMail(to string, subject string, body string) error {
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient '%s': %w", to, err)
+	}
+
+	message, err := m.buildMessage(*recipient, subject, body)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.addr, m.auth, m.from.Address, []string{recipient.Address}, message)
+}
+
+// buildMessage writes a UTF-8 plain text message. The subject is encoded, so a title cannot inject headers.
+func (m *SMTPMailer) buildMessage(to mail.Address, subject string, body string) ([]byte, error) {
+	var message bytes.Buffer
+	headers := []struct{ name, value string }{
+		{"From", m.from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", strings.Join(strings.Fields(subject), " "))},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", uuid.NewString(), domainOf(m.from.Address))},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `text/plain; charset="utf-8"`},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", header.name, header.value)
+	}
+	message.WriteString("\r\n")
+
+	writer := quotedprintable.NewWriter(&message)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return nil, fmt.Errorf("error encoding message body: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error encoding message body: %w", err)
+	}
+
+	return message.Bytes(), nil
+}
+
+func domainOf(address string) string {
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		return address[at+1:]
+	}
+	return "localhost"
+}
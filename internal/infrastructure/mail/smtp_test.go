@@ -0,0 +1,115 @@
+package mail
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpStandIn is a local SMTP server that accepts a single message and hands it over on a channel.
+type smtpStandIn struct {
+	listener net.Listener
+	messages chan capturedMessage
+}
+
+type capturedMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	standIn := &smtpStandIn{listener, make(chan capturedMessage, 1)}
+	go standIn.serve()
+	return standIn
+}
+
+func (s *smtpStandIn) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	reply := func(line string) { _ = text.PrintfLine("%s", line) }
+
+	var message capturedMessage
+	reply("220 localhost SMTP stand-in")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			message.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			message.to = append(message.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			message.data = string(data)
+			reply("250 OK")
+			s.messages <- message
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	standIn := newSMTPStandIn(t)
+	host, port, err := net.SplitHostPort(standIn.listener.Addr().String())
+	require.NoError(t, err)
+
+	mailer := NewSMTPMailer(&entity.SMTPConfig{Host: host, Port: port, From: "e-lib <library@example.com>"})
+	body := "Hi Ann,\n\nYour loan of \"Anna Karenina\" ends on Friday.\n.\nThis line follows a lone dot.\n"
+	err = mailer.SendMail("ann@example.com", "\"Anna Karenina\" is due\r\nBcc: everyone@example.com", body)
+	require.NoError(t, err)
+
+	message := <-standIn.messages
+	assert.Equal(t, "library@example.com", message.from)
+	assert.Equal(t, []string{"ann@example.com"}, message.to)
+
+	parsed, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(message.data)))
+	require.NoError(t, err)
+	assert.Equal(t, `"e-lib" <library@example.com>`, parsed.Header.Get("From"))
+	assert.Equal(t, "<ann@example.com>", parsed.Header.Get("To"))
+	assert.Empty(t, parsed.Header.Get("Bcc"), "the subject must not inject headers")
+	assert.Equal(t, `text/plain; charset="utf-8"`, parsed.Header.Get("Content-Type"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "\"Anna Karenina\" is due Bcc: everyone@example.com", subject)
+
+	decodedBody, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decodedBody), "the lone dot must survive dot-stuffing")
+}
@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/DarrelA/e-lib/internal/apperrors"
 	"github.com/DarrelA/e-lib/internal/application/dto"
@@ -12,22 +14,48 @@ import (
 
 const (
 	errMsgPleaseLoginAgain = "please login again"
+	errMsgInvalidAPIToken  = "invalid or expired token"
+	CookieSession          = "session_id"
 )
 
 type GetSessionByIDFunc func(sessionID string) (*entity.Session, *apperrors.RestErr)
+type RefreshSessionFunc func(sessionID string, session *entity.Session, role string) (string, time.Duration, *apperrors.RestErr)
+type GetAPITokenByHashFunc func(tokenHash string) (*entity.APIToken, *apperrors.RestErr)
 type GetUserByIDFunc func(userID int64) (dto.UserDetail, *apperrors.RestErr)
 
 type AuthMiddleware struct {
 	getSessionData GetSessionByIDFunc
+	refreshSession RefreshSessionFunc
+	getAPIToken    GetAPITokenByHashFunc
 	getUser        GetUserByIDFunc
 }
 
-func NewAuthMiddleware(getSessionData GetSessionByIDFunc, getUser GetUserByIDFunc) *AuthMiddleware {
-	return &AuthMiddleware{getSessionData, getUser}
+func NewAuthMiddleware(getSessionData GetSessionByIDFunc, refreshSession RefreshSessionFunc,
+	getAPIToken GetAPITokenByHashFunc, getUser GetUserByIDFunc,
+) *AuthMiddleware {
+	return &AuthMiddleware{getSessionData, refreshSession, getAPIToken, getUser}
 }
 
+// NewSessionCookie holds a session until the browser closes, or for maxAge seconds, or clears it with a
+// negative maxAge.
+func NewSessionCookie(sessionID string, maxAge int) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     CookieSession,
+		Value:    sessionID,
+		MaxAge:   maxAge,
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Strict",
+	}
+}
+
+// Authenticate accepts a personal access token in an Authorization: Bearer header, or else the session cookie.
 func (m *AuthMiddleware) Authenticate(c *fiber.Ctx) error {
-	sessionID := c.Cookies("session_id")
+	if authorization := c.Get(fiber.HeaderAuthorization); authorization != "" {
+		return m.authenticateAPIToken(c, authorization)
+	}
+
+	sessionID := c.Cookies(CookieSession)
 	if sessionID == "" {
 		log.Error().Msg("session_id cookie not found")
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": errMsgPleaseLoginAgain})
@@ -58,7 +86,87 @@ func (m *AuthMiddleware) Authenticate(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized: invalid user"})
 	}
 
+	// Activity restarts the idle timeout, and a change of role moves the session to a new session ID.
+	newSessionID, ttl, err := m.refreshSession(sessionID, sessionData, userDetail.Role)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+	if sessionData.RememberMe {
+		c.Cookie(NewSessionCookie(newSessionID, int(ttl.Seconds()))) // Slides with the session
+	} else if newSessionID != sessionID {
+		c.Cookie(NewSessionCookie(newSessionID, 0))
+	}
+
 	log.Debug().Msgf("Current user: %d: %s", userDetail.ID, userDetail.Name)
 	c.Locals("userDetail", userDetail)
+	c.Locals("session", sessionData)
 	return c.Next()
 }
+
+func (m *AuthMiddleware) authenticateAPIToken(c *fiber.Ctx, authorization string) error {
+	scheme, token, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "Bearer") || !strings.HasPrefix(token, entity.APITokenPrefix) {
+		log.Warn().Msg("authorization header is not a bearer personal access token")
+		restErr := apperrors.NewUnauthorizedError(errMsgInvalidAPIToken)
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	apiToken, err := m.getAPIToken(entity.HashAPIToken(token))
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	userDetail, err := m.getUser(apiToken.UserID)
+	if err != nil {
+		log.Error().Err(err).Msg("error getting user by ID")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized: invalid user"})
+	}
+
+	log.Debug().Msgf("Current user: %d: %s, with token %s", userDetail.ID, userDetail.Name, apiToken.UUID)
+	c.Locals("userDetail", userDetail)
+	c.Locals("apiToken", apiToken)
+	return c.Next()
+}
+
+// RequireScope only lets through personal access tokens with the given scope; sessions are let through.
+// It must run after Authenticate.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if apiToken, ok := c.Locals("apiToken").(*entity.APIToken); ok && !apiToken.HasScope(scope) {
+			restErr := apperrors.NewForbiddenError("forbidden: token lacks the " + scope + " scope")
+			return c.Status(restErr.Status).JSON(restErr)
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireSession keeps personal access tokens out of the routes that manage sessions and tokens, so that a
+// leaked token cannot mint more tokens. It must run after Authenticate.
+func RequireSession(c *fiber.Ctx) error {
+	if _, ok := c.Locals("apiToken").(*entity.APIToken); ok {
+		restErr := apperrors.NewForbiddenError("forbidden: a session is required")
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	return c.Next()
+}
+
+// RequireRole only lets through users with the given role. It must run after Authenticate.
+func RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userDetail, ok := c.Locals("userDetail").(dto.UserDetail)
+		if !ok {
+			log.Error().Msg("userDetail not found or has incorrect type")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": errMsgPleaseLoginAgain})
+		}
+
+		if userDetail.Role != role {
+			log.Warn().Msgf("user %d with role [%s] requires role [%s]", userDetail.ID, userDetail.Role, role)
+			restErr := apperrors.NewForbiddenError("forbidden: " + role + " role required")
+			return c.Status(restErr.Status).JSON(restErr)
+		}
+
+		return c.Next()
+	}
+}
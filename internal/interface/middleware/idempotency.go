@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotentReplayed  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	errMsgInvalidIdempotency  = "the Idempotency-Key header must be 1 to 255 printable characters"
+	errMsgIdempotencyReused   = "this Idempotency-Key was already used for a different request"
+	errMsgIdempotencyInFlight = "a request with this Idempotency-Key is still in progress"
+
+	// Reason codes of the conflicts
+	codeIdempotencyKeyReused     = "idempotency_key_reused"
+	codeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+)
+
+type IdempotencyMiddleware struct {
+	idempotencyRedis repository.IdempotencyRepository
+}
+
+func NewIdempotencyMiddleware(idempotencyRedis repository.IdempotencyRepository) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{idempotencyRedis}
+}
+
+// Handle replays the response of an earlier request with the same Idempotency-Key and body, so that a client
+// can retry a request without applying it twice. Keys are scoped to the user, so it runs after Authenticate.
+func (m *IdempotencyMiddleware) Handle(c *fiber.Ctx) error {
+	idempotencyKey := c.Get(headerIdempotencyKey)
+	if idempotencyKey == "" {
+		return c.Next()
+	}
+
+	if !isValidIdempotencyKey(idempotencyKey) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errMsgInvalidIdempotency})
+	}
+
+	userDetail, ok := c.Locals("userDetail").(dto.UserDetail)
+	if !ok {
+		log.Error().Msg("userDetail not found or of incorrect type")
+		restErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	key := strconv.FormatInt(userDetail.ID, 10) + ":" + idempotencyKey
+	fingerprint := requestFingerprint(c)
+
+	record, restErr := m.idempotencyRedis.Reserve(key, fingerprint)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	if record != nil {
+		switch {
+		case record.Fingerprint != fingerprint:
+			restErr = apperrors.NewConflictError(errMsgIdempotencyReused).WithCode(codeIdempotencyKeyReused)
+		case record.StatusCode == 0:
+			restErr = apperrors.NewConflictError(errMsgIdempotencyInFlight).WithCode(codeIdempotencyKeyInProgress)
+		default:
+			log.Info().Msgf("replaying the response of Idempotency-Key '%s'", idempotencyKey)
+			c.Set(headerIdempotentReplayed, "true")
+			c.Set(fiber.HeaderContentType, record.ContentType)
+			return c.Status(record.StatusCode).Send(record.Body)
+		}
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	if err := c.Next(); err != nil {
+		m.release(key)
+		return err
+	}
+
+	// A server error may not have been applied, so the key is released for the client to retry.
+	statusCode := c.Response().StatusCode()
+	if statusCode >= fiber.StatusInternalServerError {
+		m.release(key)
+		return nil
+	}
+
+	record = &entity.IdempotencyRecord{
+		Fingerprint: fingerprint,
+		StatusCode:  statusCode,
+		ContentType: string(c.Response().Header.ContentType()),
+		Body:        append([]byte(nil), c.Response().Body()...),
+	}
+	if restErr := m.idempotencyRedis.Complete(key, record); restErr != nil {
+		log.Error().Err(restErr).Msgf("the response of Idempotency-Key '%s' will not be replayed", idempotencyKey)
+	}
+
+	return nil
+}
+
+func (m *IdempotencyMiddleware) release(key string) {
+	if restErr := m.idempotencyRedis.Release(key); restErr != nil {
+		log.Error().Err(restErr).Msg("")
+	}
+}
+
+func isValidIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestFingerprint identifies a request by its method, path and body.
+func requestFingerprint(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method() + " " + c.Path() + "\n"))
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -43,7 +43,7 @@ func InputValidator(c *fiber.Ctx) error {
 			bookTitle = *bookTitlePtr // Dereference the pointer
 		}
 
-	case "POST":
+	case "POST", "DELETE":
 		err = c.BodyParser(&bookTitle)
 
 	default:
@@ -55,6 +55,7 @@ func InputValidator(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid request parameters"})
 	}
 
+	bookTitle.ISBN = strings.NewReplacer("-", "", " ", "").Replace(bookTitle.ISBN)
 	if err := validateBookTitle(bookTitle); err != nil {
 		return handleValidationError(c, err)
 	}
@@ -63,3 +64,170 @@ func InputValidator(c *fiber.Ctx) error {
 	c.Locals("bookTitleKey", bookTitle)
 	return c.Next()
 }
+
+const (
+	defaultBookListLimit  = 20
+	defaultBookListSortBy = "title"
+	defaultBookListOrder  = "asc"
+)
+
+func BookListValidator(c *fiber.Ctx) error {
+	bookList := dto.BookListRequest{
+		Limit:  defaultBookListLimit,
+		SortBy: defaultBookListSortBy,
+		Order:  defaultBookListOrder,
+	}
+
+	if err := c.QueryParser(&bookList); err != nil {
+		log.Error().Err(err).Msg("error parsing request query")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid request parameters"})
+	}
+
+	bookList.SortBy = strings.ToLower(bookList.SortBy)
+	bookList.Order = strings.ToLower(bookList.Order)
+	if err := validate.Struct(bookList); err != nil {
+		return handleValidationError(c, err)
+	}
+
+	c.Locals("bookListKey", bookList)
+	return c.Next()
+}
+
+const (
+	defaultBookSearchMode  = "fuzzy"
+	defaultBookSearchLimit = 10
+)
+
+func BookSearchValidator(c *fiber.Ctx) error {
+	bookSearch := dto.BookSearchRequest{
+		Mode:  defaultBookSearchMode,
+		Limit: defaultBookSearchLimit,
+	}
+
+	if err := c.QueryParser(&bookSearch); err != nil {
+		log.Error().Err(err).Msg("error parsing request query")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid request parameters"})
+	}
+
+	bookSearch.Title = strings.ToLower(strings.TrimSpace(bookSearch.Title))
+	bookSearch.Mode = strings.ToLower(bookSearch.Mode)
+	if err := validate.Struct(bookSearch); err != nil {
+		return handleValidationError(c, err)
+	}
+
+	c.Locals("bookSearchKey", bookSearch)
+	return c.Next()
+}
+
+const (
+	defaultLoanListStatus = dto.LoanStatusActive
+	defaultLoanListLimit  = 20
+)
+
+func LoanListValidator(c *fiber.Ctx) error {
+	loanList := dto.LoanListRequest{
+		Status: defaultLoanListStatus,
+		Limit:  defaultLoanListLimit,
+	}
+
+	if err := c.QueryParser(&loanList); err != nil {
+		log.Error().Err(err).Msg("error parsing request query")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid request parameters"})
+	}
+
+	loanList.Status = strings.ToLower(loanList.Status)
+	if err := validate.Struct(loanList); err != nil {
+		return handleValidationError(c, err)
+	}
+
+	c.Locals("loanListKey", loanList)
+	return c.Next()
+}
+
+const (
+	defaultFullTextSearchLimit = 10
+)
+
+func FullTextSearchValidator(c *fiber.Ctx) error {
+	fullTextSearch := dto.FullTextSearchRequest{
+		Limit: defaultFullTextSearchLimit,
+	}
+
+	if err := c.QueryParser(&fullTextSearch); err != nil {
+		log.Error().Err(err).Msg("error parsing request query")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid request parameters"})
+	}
+
+	fullTextSearch.Query = strings.TrimSpace(fullTextSearch.Query)
+	fullTextSearch.Language = strings.ToLower(fullTextSearch.Language)
+	if err := validate.Struct(fullTextSearch); err != nil {
+		return handleValidationError(c, err)
+	}
+
+	c.Locals("fullTextSearchKey", fullTextSearch)
+	return c.Next()
+}
+
+// BodyValidator parses the JSON body into T, validates it and stores it in the locals under localsKey.
+func BodyValidator[T any](localsKey string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body T
+		if err := c.BodyParser(&body); err != nil {
+			log.Error().Err(err).Msg("error parsing request body")
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid request parameters"})
+		}
+
+		if err := validate.Struct(body); err != nil {
+			return handleValidationError(c, err)
+		}
+
+		c.Locals(localsKey, body)
+		return c.Next()
+	}
+}
+
+const (
+	defaultImportFormat = "csv"
+)
+
+func CatalogImportValidator(c *fiber.Ctx) error {
+	catalogImport := dto.ImportRequest{
+		Format: defaultImportFormat,
+	}
+
+	if err := c.QueryParser(&catalogImport); err != nil {
+		log.Error().Err(err).Msg("error parsing request query")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid request parameters"})
+	}
+
+	catalogImport.Format = strings.ToLower(catalogImport.Format)
+	if err := validate.Struct(catalogImport); err != nil {
+		return handleValidationError(c, err)
+	}
+
+	c.Locals("catalogImportKey", catalogImport)
+	return c.Next()
+}
+
+const (
+	defaultOPDSSearchPage = 1
+)
+
+func OPDSSearchValidator(c *fiber.Ctx) error {
+	opdsSearch := dto.OPDSSearchRequest{
+		Page: defaultOPDSSearchPage,
+	}
+
+	if err := c.QueryParser(&opdsSearch); err != nil {
+		log.Error().Err(err).Msg("error parsing request query")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid request parameters"})
+	}
+
+	opdsSearch.Query = strings.TrimSpace(opdsSearch.Query)
+	if err := validate.Struct(opdsSearch); err != nil {
+		return handleValidationError(c, err)
+	}
+
+	c.Locals("opdsSearchKey", opdsSearch)
+	return c.Next()
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/DarrelA/e-lib/internal/apperrors"
 	"github.com/DarrelA/e-lib/internal/application/dto"
@@ -10,7 +11,7 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-type NewSessionFunc func(userID int64) (string, *apperrors.RestErr)
+type NewSessionFunc func(userID int64, role string, client entity.SessionClient, rememberMe bool) (string, time.Duration, *apperrors.RestErr)
 type SaveUserFunc func(user *dto.GoogleOAuth2UserRes, provider string) (*entity.User, *apperrors.RestErr)
 
 type MockUserSessionMiddleware struct {
@@ -34,7 +35,8 @@ func (m *MockUserSessionMiddleware) New(c *fiber.Ctx) error {
 	}
 
 	if appEnv == "test" {
-		sessionID, _ := m.newSessionFunc(1)
+		client := entity.SessionClient{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+		sessionID, _, _ := m.newSessionFunc(1, entity.RolePatron, client, false)
 		cookieValue := fmt.Sprintf("session_id=%s", sessionID)
 		c.Request().Header.Set("Cookie", cookieValue)
 		c.Request().Header.VisitAllCookie(func(key, value []byte) {
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"slices"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	appSvc "github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const errMsgInvalidAPITokenID = "invalid token id"
+
+type APITokenService struct {
+	apiTokenPGDB repository.APITokenRepository
+}
+
+func NewAPITokenService(apiTokenPGDB repository.APITokenRepository) appSvc.APITokenService {
+	return &APITokenService{apiTokenPGDB}
+}
+
+func (as *APITokenService) CreateAPITokenHandler(c *fiber.Ctx) error {
+	tokenRequest, ok := c.Locals("apiTokenCreateKey").(dto.APITokenCreateRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "apiTokenCreateKey")
+	}
+
+	requestID, userDetail, restErr := getUserContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	tokenResponse, restErr := as.CreateAPIToken(requestID, userDetail, tokenRequest)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+	return c.Status(fiber.StatusCreated).JSON(tokenResponse)
+}
+
+// CreateAPIToken returns the token itself only this once; only its hash is stored.
+func (as *APITokenService) CreateAPIToken(requestID string, userDetail dto.UserDetail, tokenRequest dto.APITokenCreateRequest,
+) (*dto.APITokenCreateResponse, *apperrors.RestErr) {
+	slices.Sort(tokenRequest.Scopes)
+	tokenRequest.Scopes = slices.Compact(tokenRequest.Scopes)
+
+	if slices.Contains(tokenRequest.Scopes, entity.ScopeCatalogWrite) && userDetail.Role != entity.RoleLibrarian {
+		return nil, apperrors.NewForbiddenError("forbidden: the " + entity.ScopeCatalogWrite + " scope requires the librarian role")
+	}
+
+	randomToken, err := newRandomToken()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to generate personal access token")
+		return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+	}
+	token := entity.APITokenPrefix + randomToken
+
+	tokenDetail, restErr := as.apiTokenPGDB.CreateAPIToken(requestID, userDetail.ID, tokenRequest, entity.HashAPIToken(token))
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	return &dto.APITokenCreateResponse{APITokenDetail: *tokenDetail, Token: token}, nil
+}
+
+func (as *APITokenService) GetAPITokensHandler(c *fiber.Ctx) error {
+	requestID, userDetail, restErr := getUserContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	tokenList, restErr := as.GetAPITokens(requestID, userDetail.ID)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+	return c.Status(fiber.StatusOK).JSON(tokenList)
+}
+
+func (as *APITokenService) GetAPITokens(requestID string, userID int64) (*dto.APITokenListResponse, *apperrors.RestErr) {
+	tokens, restErr := as.apiTokenPGDB.GetAPITokens(requestID, userID)
+	if restErr != nil {
+		return nil, restErr
+	}
+	return &dto.APITokenListResponse{Tokens: tokens}, nil
+}
+
+func (as *APITokenService) RevokeAPITokenHandler(c *fiber.Ctx) error {
+	requestID, userDetail, restErr := getUserContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	tokenUUID, err := uuid.Parse(c.Params("uuid"))
+	if err != nil {
+		restErr := apperrors.NewBadRequestError(errMsgInvalidAPITokenID)
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	if restErr := as.RevokeAPIToken(requestID, userDetail.ID, tokenUUID); restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success"})
+}
+
+func (as *APITokenService) RevokeAPIToken(requestID string, userID int64, tokenUUID uuid.UUID) *apperrors.RestErr {
+	return as.apiTokenPGDB.RevokeAPIToken(requestID, userID, tokenUUID)
+}
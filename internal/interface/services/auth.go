@@ -2,16 +2,26 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/DarrelA/e-lib/internal/apperrors"
 	"github.com/DarrelA/e-lib/internal/application/dto"
 	"github.com/DarrelA/e-lib/internal/application/services"
 	"github.com/DarrelA/e-lib/internal/domain/entity"
 	"github.com/DarrelA/e-lib/internal/domain/repository"
+	mw "github.com/DarrelA/e-lib/internal/interface/middleware"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/oauth2"
@@ -19,20 +29,27 @@ import (
 )
 
 const (
-	googleUserInfoEndpoint = "https://www.googleapis.com/oauth2/v2/userinfo?access_token="
+	googleUserInfoEndpoint = "https://www.googleapis.com/oauth2/v2/userinfo"
+	providerGoogle         = "google"
 
-	errMsgPleaseLoginAgain = "please login again"
-	errMsgGoogleOAuth2     = "google oauth2 error"
+	errMsgPleaseLoginAgain     = "please login again"
+	errMsgGoogleOAuth2         = "google oauth2 error"
+	errMsgRedirectNotAllowed   = "redirect_to must be a path of this server or a URL on an allowed origin"
+	cookieOAuth2Login          = "oauth2_login"
+	oauth2LoginCookieMaxAgeSec = 10 * 60 // As long as the login state is kept
 )
 
 type GoogleOAuth2 struct {
 	googleLoginConfig oauth2.Config
+	redirectAllowList []string
 	userPGDB          repository.UserRepository
 	sessionRedis      repository.SessionRepository
+	oauth2StateRedis  repository.OAuth2StateRepository
 }
 
 func NewGoogleOAuth2(OAuth2Config *entity.OAuth2Config,
 	userPGDB repository.UserRepository, sessionRedis repository.SessionRepository,
+	oauth2StateRedis repository.OAuth2StateRepository,
 ) services.GoogleOAuth2Service {
 	googleLoginConfig := oauth2.Config{
 		RedirectURL:  OAuth2Config.GoogleRedirectURL,
@@ -42,49 +59,62 @@ func NewGoogleOAuth2(OAuth2Config *entity.OAuth2Config,
 		Endpoint:     google.Endpoint,
 	}
 
-	return &GoogleOAuth2{googleLoginConfig, userPGDB, sessionRedis}
+	return &GoogleOAuth2{googleLoginConfig, OAuth2Config.RedirectAllowList, userPGDB, sessionRedis, oauth2StateRedis}
 }
 
+// Login sends the user to Google with a new state and PKCE challenge. The state is bound to the browser
+// by a pre-auth cookie, so that a callback started by another site or browser is refused.
 func (oa GoogleOAuth2) Login(c *fiber.Ctx) error {
-	url := oa.googleLoginConfig.AuthCodeURL("randomstate")
+	redirectTo := c.Query("redirect_to")
+	if redirectTo != "" && !isAllowedRedirect(redirectTo, oa.redirectAllowList) {
+		err := apperrors.NewBadRequestError(errMsgRedirectNotAllowed)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	state, stateErr := newRandomToken()
+	loginID, loginIDErr := newRandomToken()
+	if stateErr != nil || loginIDErr != nil {
+		log.Error().Err(stateErr).AnErr("loginIDErr", loginIDErr).Msg("failed to generate oauth2 state")
+		err := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	loginState := &entity.OAuth2LoginState{
+		Provider: providerGoogle, LoginID: loginID, CodeVerifier: oauth2.GenerateVerifier(),
+		RedirectTo: redirectTo, RememberMe: c.QueryBool("remember_me"),
+	}
+	if err := oa.oauth2StateRedis.SaveLoginState(state, loginState); err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	c.Cookie(newOAuth2LoginCookie(loginID, oauth2LoginCookieMaxAgeSec))
+	url := oa.googleLoginConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(loginState.CodeVerifier))
 	return c.Redirect(url, fiber.StatusSeeOther)
 }
 
 func (oa GoogleOAuth2) Callback(c *fiber.Ctx) error {
-	state := c.Query("state")
-	if state != "randomstate" {
-		err := apperrors.NewBadRequestError(errMsgPleaseLoginAgain)
-		return c.Status(err.Status).JSON(err)
+	loginState, restErr := takeLoginState(c, oa.oauth2StateRedis, providerGoogle)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	code := c.Query("code")
-	token, err := oa.googleLoginConfig.Exchange(context.Background(), code)
+	token, err := oa.googleLoginConfig.Exchange(ctx, code, oauth2.VerifierOption(loginState.CodeVerifier))
 	if err != nil {
 		err := apperrors.NewBadRequestError(errMsgPleaseLoginAgain)
 		return c.Status(err.Status).JSON(err)
 	}
 
-	resp, err := http.Get(googleUserInfoEndpoint + token.AccessToken)
+	user, err := oa.getUserInfo(ctx, token)
 	if err != nil {
 		log.Error().Err(err).Msg(errMsgGoogleOAuth2)
 		err := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
 		return c.Status(err.Status).JSON(err)
 	}
 
-	userInfo, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Error().Err(err).Msg(errMsgGoogleOAuth2)
-		err := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
-		return c.Status(err.Status).JSON(err)
-	}
-
-	user := &dto.GoogleOAuth2UserRes{}
-	if err := json.Unmarshal(userInfo, &user); err != nil {
-		log.Error().Err(err).Msg("")
-		err := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
-		return c.Status(err.Status).JSON(err)
-	}
-
 	var wg sync.WaitGroup
 	wg.Add(1) // Increment the counter
 
@@ -106,43 +136,176 @@ func (oa GoogleOAuth2) Callback(c *fiber.Ctx) error {
 
 	if dbErrFromGoroutine != nil {
 		log.Error().Err(dbErrFromGoroutine).Msg("failed to save user into RDBMS after waitgroup")
-		return c.Status(fiber.StatusInternalServerError).JSON(dbErrFromGoroutine)
+		return c.Status(dbErrFromGoroutine.Status).JSON(dbErrFromGoroutine)
+	}
+
+	if restErr := startSession(c, oa.userPGDB, oa.sessionRedis, libUserID, loginState.RememberMe); restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
 	}
 
-	sessionID, sessionErr := oa.sessionRedis.NewSession(libUserID)
+	if loginState.RedirectTo != "" {
+		return c.Redirect(loginState.RedirectTo, fiber.StatusSeeOther)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"user": user})
+}
+
+// getUserInfo reads the profile of the user with the access token, which is sent in the Authorization
+// header rather than in the URL.
+func (oa GoogleOAuth2) getUserInfo(ctx context.Context, token *oauth2.Token) (*dto.GoogleOAuth2UserRes, error) {
+	resp, err := oa.googleLoginConfig.Client(ctx, token).Get(googleUserInfoEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("userinfo responded with status %d: %s", resp.StatusCode, body)
+	}
+
+	user := &dto.GoogleOAuth2UserRes{}
+	if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+		return nil, fmt.Errorf("error decoding the userinfo: %w", err)
+	}
+	if user.ID == "" || user.Email == "" {
+		return nil, errors.New("userinfo has no id or email")
+	}
+
+	return user, nil
+}
+
+// takeLoginState ends the login of the callback, and returns its state if the callback comes from the
+// browser that started the login and for the same provider.
+func takeLoginState(c *fiber.Ctx, oauth2StateRedis repository.OAuth2StateRepository, provider string,
+) (*entity.OAuth2LoginState, *apperrors.RestErr) {
+	state := c.Query("state")
+	loginID := c.Cookies(cookieOAuth2Login)
+	c.Cookie(newOAuth2LoginCookie("", -1)) // The login is over either way
+	if state == "" || loginID == "" {
+		return nil, apperrors.NewBadRequestError(errMsgPleaseLoginAgain)
+	}
+
+	loginState, restErr := oauth2StateRedis.TakeLoginState(state)
+	if restErr != nil {
+		return nil, restErr
+	}
+	if loginState == nil || loginState.Provider != provider ||
+		subtle.ConstantTimeCompare([]byte(loginState.LoginID), []byte(loginID)) != 1 {
+		log.Warn().Msg("oauth2 callback with an unknown state or from another browser")
+		return nil, apperrors.NewBadRequestError(errMsgPleaseLoginAgain)
+	}
+
+	return loginState, nil
+}
+
+// startSession starts a session of the user with the role they have now, which the session keeps until it
+// is rotated.
+func startSession(c *fiber.Ctx, userPGDB repository.UserRepository, sessionRedis repository.SessionRepository,
+	userID int64, rememberMe bool,
+) *apperrors.RestErr {
+	userDetail, restErr := userPGDB.GetUserByID(userID)
+	if restErr != nil {
+		return restErr
+	}
+
+	client := entity.SessionClient{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+	sessionID, ttl, sessionErr := sessionRedis.NewSession(userID, userDetail.Role, client, rememberMe)
 	if sessionErr != nil {
 		return sessionErr
 	}
 
-	cookie := fiber.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
+	// Without "remember me", the cookie ends with the browser.
+	maxAge := 0
+	if rememberMe {
+		maxAge = int(ttl.Seconds())
+	}
+	c.Cookie(mw.NewSessionCookie(sessionID, maxAge))
+	return nil
+}
+
+// newOAuth2LoginCookie binds a login to the browser that started it. It is sent back on the redirect from
+// the provider, which is a cross-site navigation, so it cannot be SameSite=Strict.
+func newOAuth2LoginCookie(loginID string, maxAge int) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     cookieOAuth2Login,
+		Value:    loginID,
+		Path:     "/auth",
+		MaxAge:   maxAge,
 		HTTPOnly: true,
 		Secure:   true,
-		SameSite: "Strict",
+		SameSite: "Lax",
 	}
-	c.Cookie(&cookie)
+}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"user": user})
+func newRandomToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// isAllowedRedirect accepts a path of this server, or a URL on an origin of the allow-list.
+func isAllowedRedirect(target string, allowList []string) bool {
+	targetURL, err := url.Parse(target)
+	if err != nil || strings.ContainsAny(target, "\\\r\n") {
+		return false
+	}
+
+	// "//host/path" is a URL of another host, not a path.
+	if targetURL.Scheme == "" && targetURL.Host == "" {
+		return strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//")
+	}
+
+	if targetURL.User != nil {
+		return false
+	}
+	origin := targetURL.Scheme + "://" + targetURL.Host
+	return slices.ContainsFunc(allowList, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	})
 }
 
 func (oa GoogleOAuth2) SaveUserToRDBMS(user *dto.GoogleOAuth2UserRes) (int64, *apperrors.RestErr) {
-	user_id, dbErr := oa.userPGDB.GetUserID("google", user.ID, user.Email)
+	return saveProviderUser(oa.userPGDB, user, providerGoogle, false)
+}
+
+// saveProviderUser returns the library user of an account at a provider, who joins on their first login. The
+// first login with a provider that is trusted with emails links it to the user who has its verified email; a
+// provider that lets its users set their email could otherwise take over any account.
+func saveProviderUser(userPGDB repository.UserRepository, user *dto.GoogleOAuth2UserRes, provider string,
+	trustEmail bool,
+) (int64, *apperrors.RestErr) {
+	user_id, dbErr := userPGDB.GetUserID(provider, user.ID)
 	if dbErr != nil {
 		return -1, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
 	}
+	if user_id != -1 {
+		return user_id, nil
+	}
 
-	libUser := &entity.User{ID: int64(user_id)}
-
-	// No user_id found in User table
-	if user_id == -1 {
-		libUser, dbErr = oa.userPGDB.SaveUser(user, "google")
+	if trustEmail && user.VerifiedEmail {
+		user_id, dbErr = userPGDB.GetUserIDByEmail(user.Email)
 		if dbErr != nil {
 			return -1, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
 		}
 
-		log.Info().Msgf("user %s has joined the e-Lib using %s!", libUser.Name, libUser.Email)
+		if user_id != -1 {
+			if dbErr = userPGDB.LinkProvider(user_id, user, provider); dbErr != nil {
+				return -1, dbErr
+			}
+
+			log.Info().Msgf("user %d can now login using %s", user_id, provider)
+			return user_id, nil
+		}
+	}
+
+	// No user_id found in User table
+	libUser, dbErr := userPGDB.SaveUser(user, provider)
+	if dbErr != nil {
+		return -1, dbErr
 	}
 
+	log.Info().Msgf("user %s has joined the e-Lib using %s!", libUser.Name, libUser.Email)
 	return libUser.ID, nil
 }
@@ -6,6 +6,7 @@ import (
 	appSvc "github.com/DarrelA/e-lib/internal/application/services"
 	"github.com/DarrelA/e-lib/internal/domain/repository"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
@@ -42,10 +43,83 @@ func (bs *BookService) GetBookByTitleHandler(c *fiber.Ctx) error {
 }
 
 func (bs *BookService) GetBookByTitle(requestID string, bookRequest dto.BookRequest) (*dto.BookDetail, *apperrors.RestErr) {
-	bookDetail, err := bs.bookPGDB.GetBook(requestID, bookRequest.Title)
-	if err != nil {
+	return getBook(requestID, bs.bookPGDB, bookRequest)
+}
+
+// getBook looks up the book by UUID, then ISBN, and only falls back to the title when neither is given.
+func getBook(requestID string, bookPGDB repository.BookRepository, bookRequest dto.BookRequest) (*dto.BookDetail, *apperrors.RestErr) {
+	var bookDetail *dto.BookDetail
+	var restErr *apperrors.RestErr
+	switch {
+	case bookRequest.UUID != "":
+		bookUUID, err := uuid.Parse(bookRequest.UUID)
+		if err != nil {
+			return nil, apperrors.NewBadRequestError(errMsgInvalidBookUUID)
+		}
+		bookDetail, restErr = bookPGDB.GetBookByUUID(requestID, bookUUID)
+
+	case bookRequest.ISBN != "":
+		bookDetail, restErr = bookPGDB.GetBookByISBN(requestID, bookRequest.ISBN)
+
+	default:
+		bookDetail, restErr = bookPGDB.GetBook(requestID, bookRequest.Title)
+	}
+
+	if restErr != nil {
+		// Only a missing book is reported as such; an outage or an ambiguous title is passed through.
+		if restErr.Status != fiber.StatusNotFound {
+			return nil, restErr
+		}
+
+		log.Warn().Msg(errMsgBookNotFound + ":" + restErr.Message)
 		return nil, apperrors.NewNotFoundError(errMsgBookNotFound)
 	}
 
 	return bookDetail, nil
 }
+
+func (bs *BookService) ListBooksHandler(c *fiber.Ctx) error {
+	params, ok := c.Locals("bookListKey").(dto.BookListRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "bookListKey")
+	}
+
+	requestID, ok := c.Locals("requestid").(string)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "requestid")
+	}
+
+	bookListResponse, err := bs.ListBooks(requestID, params)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(bookListResponse)
+}
+
+func (bs *BookService) ListBooks(requestID string, params dto.BookListRequest) (*dto.BookListResponse, *apperrors.RestErr) {
+	return bs.bookPGDB.ListBooks(requestID, params)
+}
+
+func (bs *BookService) SearchBooksHandler(c *fiber.Ctx) error {
+	params, ok := c.Locals("bookSearchKey").(dto.BookSearchRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "bookSearchKey")
+	}
+
+	requestID, ok := c.Locals("requestid").(string)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "requestid")
+	}
+
+	bookSearchResults, err := bs.SearchBooks(requestID, params)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"books": bookSearchResults})
+}
+
+func (bs *BookService) SearchBooks(requestID string, params dto.BookSearchRequest) ([]dto.BookSearchResult, *apperrors.RestErr) {
+	return bs.bookPGDB.SearchBooks(requestID, params)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	appSvc "github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	errMsgInvalidBookUUID = "invalid book uuid"
+)
+
+// Removes the hyphens and spaces that the validator accepts within an ISBN.
+var isbnNormalizer = strings.NewReplacer("-", "", " ", "")
+
+type CatalogService struct {
+	bookPGDB     repository.BookRepository
+	searchEngine repository.SearchRepository
+}
+
+func NewCatalogService(bookPGDB repository.BookRepository, searchEngine repository.SearchRepository) appSvc.CatalogService {
+	return &CatalogService{bookPGDB, searchEngine}
+}
+
+func (cs *CatalogService) CreateBookHandler(c *fiber.Ctx) error {
+	book, ok := c.Locals("bookCreateKey").(dto.BookCreateRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "bookCreateKey")
+	}
+
+	requestID, ok := c.Locals("requestid").(string)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "requestid")
+	}
+
+	bookDetail, err := cs.CreateBook(requestID, book)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(bookDetail)
+}
+
+func (cs *CatalogService) CreateBook(requestID string, book dto.BookCreateRequest) (*dto.BookDetail, *apperrors.RestErr) {
+	book.ISBN13 = isbnNormalizer.Replace(book.ISBN13)
+	book.ISBN10 = isbnNormalizer.Replace(book.ISBN10)
+
+	bookDetail, err := cs.bookPGDB.CreateBook(requestID, book)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.reindex(requestID, bookDetail.UUID)
+	return bookDetail, nil
+}
+
+func (cs *CatalogService) UpdateBookHandler(c *fiber.Ctx) error {
+	book, ok := c.Locals("bookUpdateKey").(dto.BookUpdateRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "bookUpdateKey")
+	}
+
+	requestID, bookUUID, restErr := getCatalogContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	bookDetail, err := cs.UpdateBook(requestID, bookUUID, book)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(bookDetail)
+}
+
+func (cs *CatalogService) UpdateBook(requestID string, bookUUID uuid.UUID, book dto.BookUpdateRequest) (*dto.BookDetail, *apperrors.RestErr) {
+	if book.ISBN13 != nil {
+		isbn13 := isbnNormalizer.Replace(*book.ISBN13)
+		book.ISBN13 = &isbn13
+	}
+	if book.ISBN10 != nil {
+		isbn10 := isbnNormalizer.Replace(*book.ISBN10)
+		book.ISBN10 = &isbn10
+	}
+
+	bookDetail, err := cs.bookPGDB.UpdateBook(requestID, bookUUID, book)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.reindex(requestID, bookUUID)
+	return bookDetail, nil
+}
+
+func (cs *CatalogService) AdjustCopiesHandler(c *fiber.Ctx) error {
+	bookCopies, ok := c.Locals("bookCopiesKey").(dto.BookCopiesRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "bookCopiesKey")
+	}
+
+	requestID, bookUUID, restErr := getCatalogContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	bookDetail, err := cs.AdjustCopies(requestID, bookUUID, bookCopies.Delta)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(bookDetail)
+}
+
+func (cs *CatalogService) AdjustCopies(requestID string, bookUUID uuid.UUID, delta int) (*dto.BookDetail, *apperrors.RestErr) {
+	return cs.bookPGDB.AdjustCopies(requestID, bookUUID, delta)
+}
+
+func (cs *CatalogService) WithdrawBookHandler(c *fiber.Ctx) error {
+	requestID, bookUUID, restErr := getCatalogContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	err := cs.WithdrawBook(requestID, bookUUID, c.QueryBool("force"))
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success"})
+}
+
+func (cs *CatalogService) WithdrawBook(requestID string, bookUUID uuid.UUID, force bool) *apperrors.RestErr {
+	if err := cs.bookPGDB.WithdrawBook(requestID, bookUUID, force); err != nil {
+		return err
+	}
+
+	if err := cs.searchEngine.RemoveBooks(requestID, bookUUID); err != nil {
+		log.Error().Err(err).Msgf("failed to remove book '%s' from the search index", bookUUID)
+	}
+	return nil
+}
+
+// reindex keeps the search index in step with the catalog. The catalog change has been committed already,
+// so a failure is logged rather than returned; the next full reindex at startup catches up.
+func (cs *CatalogService) reindex(requestID string, bookUUID uuid.UUID) {
+	if err := cs.searchEngine.IndexBooks(requestID, bookUUID); err != nil {
+		log.Error().Err(err).Msgf("failed to index book '%s'", bookUUID)
+	}
+}
+
+func getCatalogContextInfo(c *fiber.Ctx) (string, uuid.UUID, *apperrors.RestErr) {
+	requestID, ok := c.Locals("requestid").(string)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "requestid")
+		return "", uuid.Nil, apperrors.NewBadRequestError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	bookUUID, err := uuid.Parse(c.Params("uuid"))
+	if err != nil {
+		return "", uuid.Nil, apperrors.NewBadRequestError(errMsgInvalidBookUUID)
+	}
+
+	return requestID, bookUUID, nil
+}
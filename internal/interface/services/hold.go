@@ -0,0 +1,97 @@
+package services
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	appSvc "github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type HoldService struct {
+	bookPGDB repository.BookRepository
+	holdPGDB repository.HoldRepository
+}
+
+func NewHoldService(bookPGDB repository.BookRepository, holdPGDB repository.HoldRepository) appSvc.HoldService {
+	return &HoldService{bookPGDB, holdPGDB}
+}
+
+func (hs *HoldService) PlaceHoldHandler(c *fiber.Ctx) error {
+	bookRequest, requestID, userDetail, restErr := getContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	holdDetail, err := hs.PlaceHold(requestID, userDetail.ID, bookRequest)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(holdDetail)
+}
+
+func (hs *HoldService) PlaceHold(requestID string, userID int64, bookRequest dto.BookRequest) (*dto.HoldDetail, *apperrors.RestErr) {
+	bookDetail, restErr := getBook(requestID, hs.bookPGDB, bookRequest)
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	return hs.holdPGDB.PlaceHold(requestID, userID, bookDetail)
+}
+
+func (hs *HoldService) CancelHoldHandler(c *fiber.Ctx) error {
+	bookRequest, requestID, userDetail, restErr := getContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	err := hs.CancelHold(requestID, userDetail.ID, bookRequest)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success"})
+}
+
+func (hs *HoldService) CancelHold(requestID string, userID int64, bookRequest dto.BookRequest) *apperrors.RestErr {
+	bookDetail, restErr := getBook(requestID, hs.bookPGDB, bookRequest)
+	if restErr != nil {
+		return restErr
+	}
+
+	return hs.holdPGDB.CancelHold(requestID, userID, bookDetail.UUID)
+}
+
+func (hs *HoldService) GetHoldsHandler(c *fiber.Ctx) error {
+	requestID, userDetail, restErr := getUserContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	holds, err := hs.GetHolds(requestID, userDetail.ID)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+	return c.Status(fiber.StatusOK).JSON(holds)
+}
+
+func (hs *HoldService) GetHolds(requestID string, userID int64) ([]dto.HoldDetail, *apperrors.RestErr) {
+	return hs.holdPGDB.GetHolds(requestID, userID)
+}
+
+func getUserContextInfo(c *fiber.Ctx) (string, dto.UserDetail, *apperrors.RestErr) {
+	restErr := apperrors.NewBadRequestError(apperrors.ErrMsgSomethingWentWrong)
+	requestID, ok := c.Locals("requestid").(string)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "requestid")
+		return "", dto.UserDetail{}, restErr
+	}
+
+	userDetail, ok := c.Locals("userDetail").(dto.UserDetail)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "userDetail")
+		return "", dto.UserDetail{}, restErr
+	}
+
+	return requestID, userDetail, nil
+}
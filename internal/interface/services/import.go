@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	appSvc "github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	importFileFormField = "file"
+
+	errMsgMissingImportFile      = "missing import file in form field 'file'"
+	errMsgInvalidColumnMapping   = "invalid column mapping '%s', expected field=Column pairs separated by commas"
+	errMsgFailedToOpenImportFile = "failed to open import file"
+	errMsgUnidentifiedDelete     = "delete record has neither an ISBN nor a feed record reference"
+)
+
+var importRecordValidator = validator.New()
+
+type CatalogImportService struct {
+	bookPGDB     repository.BookRepository
+	searchEngine repository.SearchRepository
+	newParser    repository.CatalogParserFactory
+}
+
+func NewCatalogImportService(
+	bookPGDB repository.BookRepository, searchEngine repository.SearchRepository, newParser repository.CatalogParserFactory,
+) appSvc.CatalogImportService {
+	return &CatalogImportService{bookPGDB, searchEngine, newParser}
+}
+
+func (cis *CatalogImportService) ImportBooksHandler(c *fiber.Ctx) error {
+	params, ok := c.Locals("catalogImportKey").(dto.ImportRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "catalogImportKey")
+	}
+
+	requestID, ok := c.Locals("requestid").(string)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "requestid")
+	}
+
+	columnMapping, restErr := ParseColumnMapping(params.ColumnMapping)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	fileHeader, err := c.FormFile(importFileFormField)
+	if err != nil {
+		restErr := apperrors.NewBadRequestError(errMsgMissingImportFile)
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Error().Err(err).Msg(errMsgFailedToOpenImportFile)
+		restErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+	defer file.Close()
+
+	report, restErr := cis.ImportBooks(requestID, params.Format, columnMapping, file, params.DryRun)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(report)
+}
+
+/*
+ImportBooks parses the source, validates every record and upserts the valid ones.
+Records that cannot be parsed or validated are reported alongside the saved ones, ordered by their position in the source.
+*/
+func (cis *CatalogImportService) ImportBooks(
+	requestID string, format string, columnMapping map[string]string, source io.Reader, dryRun bool,
+) (*dto.ImportReport, *apperrors.RestErr) {
+	parser, err := cis.newParser(format, columnMapping)
+	if err != nil {
+		return nil, apperrors.NewBadRequestError(err.Error())
+	}
+
+	records, recordErrors := parser.Parse(source)
+
+	validRecords := make([]dto.ImportRecord, 0, len(records))
+	for _, record := range records {
+		record.ISBN13 = isbnNormalizer.Replace(record.ISBN13)
+		record.ISBN10 = isbnNormalizer.Replace(record.ISBN10)
+		if err := validateImportRecord(record); err != nil {
+			recordErrors = append(recordErrors, dto.ImportRecordError{Line: record.Line, Message: validationMessage(err)})
+			continue
+		}
+		validRecords = append(validRecords, record)
+	}
+
+	report, restErr := cis.bookPGDB.ImportBooks(requestID, validRecords, dryRun)
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	report.Format = strings.ToLower(format)
+	for _, recordError := range recordErrors {
+		report.Records = append(report.Records, dto.ImportRecordResult{
+			Line: recordError.Line, Action: dto.ImportActionError, Error: recordError.Message,
+		})
+	}
+	report.Failed += len(recordErrors)
+	slices.SortStableFunc(report.Records, func(a, b dto.ImportRecordResult) int { return a.Line - b.Line })
+
+	if !dryRun {
+		cis.reindex(requestID, report)
+	}
+	return report, nil
+}
+
+// validateImportRecord only requires a delete record to identify the title, by ISBN or by the feed's record reference.
+func validateImportRecord(record dto.ImportRecord) error {
+	if !record.Delete {
+		return importRecordValidator.Struct(record)
+	}
+
+	if record.ISBN13 == "" && record.Source == nil {
+		return errors.New(errMsgUnidentifiedDelete)
+	}
+	return importRecordValidator.Var(record.ISBN13, "omitempty,isbn13")
+}
+
+// reindex updates the search index for the titles that the import created, updated or withdrew;
+// like CatalogService, a failure is only logged.
+func (cis *CatalogImportService) reindex(requestID string, report *dto.ImportReport) {
+	indexedUUIDs, removedUUIDs := []uuid.UUID{}, []uuid.UUID{}
+	for _, result := range report.Records {
+		if result.BookUUID == nil {
+			continue
+		}
+
+		switch result.Action {
+		case dto.ImportActionCreate, dto.ImportActionUpdate:
+			indexedUUIDs = append(indexedUUIDs, *result.BookUUID)
+		case dto.ImportActionWithdraw:
+			removedUUIDs = append(removedUUIDs, *result.BookUUID)
+		}
+	}
+
+	if len(indexedUUIDs) > 0 {
+		if err := cis.searchEngine.IndexBooks(requestID, indexedUUIDs...); err != nil {
+			log.Error().Err(err).Msgf("failed to index %d imported book(s)", len(indexedUUIDs))
+		}
+	}
+
+	if len(removedUUIDs) > 0 {
+		if err := cis.searchEngine.RemoveBooks(requestID, removedUUIDs...); err != nil {
+			log.Error().Err(err).Msgf("failed to remove %d withdrawn book(s) from the search index", len(removedUUIDs))
+		}
+	}
+}
+
+// ParseColumnMapping reads a CSV column mapping such as `isbn_13=ISBN,title=Book Title`.
+func ParseColumnMapping(mapping string) (map[string]string, *apperrors.RestErr) {
+	columnMapping := map[string]string{}
+	if strings.TrimSpace(mapping) == "" {
+		return columnMapping, nil
+	}
+
+	for _, pair := range strings.Split(mapping, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+		if !ok || field == "" || column == "" {
+			return nil, apperrors.NewBadRequestError(fmt.Sprintf(errMsgInvalidColumnMapping, mapping))
+		}
+		columnMapping[strings.ToLower(field)] = column
+	}
+	return columnMapping, nil
+}
+
+func validationMessage(err error) string {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err.Error()
+	}
+
+	messages := make([]string, 0, len(validationErrors))
+	for _, e := range validationErrors {
+		messages = append(messages, fmt.Sprintf("field %s: %s", e.Field(), e.Tag()))
+	}
+	return fmt.Sprintf("validation failed: %v", messages)
+}
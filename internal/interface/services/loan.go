@@ -8,20 +8,25 @@ import (
 	appSvc "github.com/DarrelA/e-lib/internal/application/services"
 	"github.com/DarrelA/e-lib/internal/domain/repository"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	warnMsgOutOfStock = "book '%s' is out of stock"
+	warnMsgOutOfStock     = "book '%s' is out of stock; place a hold to be offered the next copy"
+	errMsgInvalidLoanUUID = "invalid loan uuid"
 )
 
 type LoanService struct {
 	bookPGDB repository.BookRepository
 	loanPGDB repository.LoanRepository
+	holdPGDB repository.HoldRepository
 }
 
-func NewLoanService(bookPGDB repository.BookRepository, loanPGDB repository.LoanRepository) appSvc.LoanService {
-	return &LoanService{bookPGDB, loanPGDB}
+func NewLoanService(
+	bookPGDB repository.BookRepository, loanPGDB repository.LoanRepository, holdPGDB repository.HoldRepository,
+) appSvc.LoanService {
+	return &LoanService{bookPGDB, loanPGDB, holdPGDB}
 }
 
 func (ls *LoanService) BorrowBookHandler(c *fiber.Ctx) error {
@@ -38,16 +43,38 @@ func (ls *LoanService) BorrowBookHandler(c *fiber.Ctx) error {
 }
 
 func (ls *LoanService) BorrowBook(requestID string, userDetail dto.UserDetail, bookRequest dto.BookRequest) (*dto.LoanDetail, *apperrors.RestErr) {
-	bookDetail, restErr := ls.bookPGDB.GetBook(requestID, bookRequest.Title)
+	bookDetail, restErr := getBook(requestID, ls.bookPGDB, bookRequest)
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	return ls.lendBook(requestID, userDetail, bookDetail)
+}
+
+// BorrowBookByUUID borrows an exact edition, e.g. from the borrow link of an OPDS feed.
+func (ls *LoanService) BorrowBookByUUID(requestID string, userDetail dto.UserDetail, bookUUID uuid.UUID) (*dto.LoanDetail, *apperrors.RestErr) {
+	bookDetail, restErr := ls.bookPGDB.GetBookByUUID(requestID, bookUUID)
 	if restErr != nil {
 		log.Warn().Msg(errMsgBookNotFound + ":" + restErr.Message)
 		restErr.Message = errMsgBookNotFound
 		return nil, restErr
 	}
 
+	return ls.lendBook(requestID, userDetail, bookDetail)
+}
+
+func (ls *LoanService) lendBook(requestID string, userDetail dto.UserDetail, bookDetail *dto.BookDetail) (*dto.LoanDetail, *apperrors.RestErr) {
+	// A copy offered to the user's hold is reserved for them, so it is lent even when none is available.
 	if bookDetail.AvailableCopies <= 0 {
-		log.Warn().Msgf(warnMsgOutOfStock, bookRequest)
-		return nil, apperrors.NewBadRequestError(fmt.Sprintf(warnMsgOutOfStock, bookRequest))
+		holdDetail, restErr := ls.holdPGDB.GetHold(requestID, userDetail.ID, bookDetail.UUID)
+		if restErr != nil && restErr.Status != fiber.StatusNotFound {
+			return nil, restErr
+		}
+
+		if holdDetail == nil || holdDetail.Status != dto.HoldStatusOffered {
+			log.Warn().Msgf(warnMsgOutOfStock, bookDetail.Title)
+			return nil, apperrors.NewBadRequestError(fmt.Sprintf(warnMsgOutOfStock, bookDetail.Title))
+		}
 	}
 
 	loanDetail, err := ls.loanPGDB.BorrowBook(requestID, userDetail, bookDetail)
@@ -72,9 +99,8 @@ func (ls *LoanService) ExtendBookLoanHandler(c *fiber.Ctx) error {
 }
 
 func (ls *LoanService) ExtendBookLoan(requestID string, userID int64, bookRequest dto.BookRequest) (*dto.LoanDetail, *apperrors.RestErr) {
-	bookDetail, restErr := ls.bookPGDB.GetBook(requestID, bookRequest.Title)
+	bookDetail, restErr := getBook(requestID, ls.bookPGDB, bookRequest)
 	if restErr != nil {
-		log.Error().Err(restErr).Msgf("")
 		return nil, restErr
 	}
 
@@ -101,9 +127,8 @@ func (ls *LoanService) ReturnBookHandler(c *fiber.Ctx) error {
 }
 
 func (ls *LoanService) ReturnBook(requestID string, userID int64, bookRequest dto.BookRequest) *apperrors.RestErr {
-	bookDetail, restErr := ls.bookPGDB.GetBook(requestID, bookRequest.Title)
+	bookDetail, restErr := getBook(requestID, ls.bookPGDB, bookRequest)
 	if restErr != nil {
-		log.Error().Err(restErr).Msgf("")
 		return restErr
 	}
 
@@ -116,6 +141,51 @@ func (ls *LoanService) ReturnBook(requestID string, userID int64, bookRequest dt
 	return nil
 }
 
+func (ls *LoanService) GetLoansHandler(c *fiber.Ctx) error {
+	params, ok := c.Locals("loanListKey").(dto.LoanListRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "loanListKey")
+	}
+
+	requestID, userDetail, restErr := getUserContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	loanList, err := ls.GetLoans(requestID, userDetail.ID, params)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+	return c.Status(fiber.StatusOK).JSON(loanList)
+}
+
+func (ls *LoanService) GetLoans(requestID string, userID int64, params dto.LoanListRequest) (*dto.LoanListResponse, *apperrors.RestErr) {
+	return ls.loanPGDB.GetLoans(requestID, userID, params)
+}
+
+func (ls *LoanService) GetLoanHistoryHandler(c *fiber.Ctx) error {
+	requestID, userDetail, restErr := getUserContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	loanUUID, err := uuid.Parse(c.Params("uuid"))
+	if err != nil {
+		restErr := apperrors.NewBadRequestError(errMsgInvalidLoanUUID)
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	loanHistory, restErr := ls.GetLoanHistory(requestID, userDetail.ID, loanUUID)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+	return c.Status(fiber.StatusOK).JSON(loanHistory)
+}
+
+func (ls *LoanService) GetLoanHistory(requestID string, userID int64, loanUUID uuid.UUID) (*dto.LoanHistoryResponse, *apperrors.RestErr) {
+	return ls.loanPGDB.GetLoanHistory(requestID, userID, loanUUID)
+}
+
 func getContextInfo(c *fiber.Ctx) (dto.BookRequest, string, dto.UserDetail, *apperrors.RestErr) {
 	restErr := apperrors.NewBadRequestError(apperrors.ErrMsgSomethingWentWrong)
 	borrowBook, ok := c.Locals("bookTitleKey").(dto.BookRequest)
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	appSvc "github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Due dates are written in UTC, as they are stored.
+	notificationDateLayout = "Monday, 2 January 2006 15:04 MST"
+)
+
+//go:embed templates/*.tmpl
+var notificationTemplateFiles embed.FS
+
+// Each template defines a "subject" and a "body".
+var notificationTemplates = map[string]*template.Template{
+	dto.LoanNotificationDueSoon:  template.Must(template.ParseFS(notificationTemplateFiles, "templates/loan_due_soon.tmpl")),
+	dto.LoanNotificationExtended: template.Must(template.ParseFS(notificationTemplateFiles, "templates/loan_extended.tmpl")),
+	dto.LoanNotificationExpired:  template.Must(template.ParseFS(notificationTemplateFiles, "templates/loan_expired.tmpl")),
+}
+
+type NotificationService struct {
+	notificationPGDB repository.NotificationRepository
+	mailer           repository.Mailer
+}
+
+func NewNotificationService(notificationPGDB repository.NotificationRepository, mailer repository.Mailer) appSvc.NotificationService {
+	return &NotificationService{notificationPGDB, mailer}
+}
+
+func (ns *NotificationService) SendLoanNotifications(requestID string, batchSize int) (int, *apperrors.RestErr) {
+	notifications, restErr := ns.notificationPGDB.ClaimLoanNotifications(requestID, batchSize)
+	if restErr != nil {
+		return 0, restErr
+	}
+
+	for _, notification := range notifications {
+		if err := ns.sendLoanNotification(notification); err != nil {
+			log.Warn().Err(err).Msgf("failed to send the %s notification of loan '%s'", notification.Kind, notification.LoanUUID)
+			restErr = ns.notificationPGDB.MarkLoanNotificationFailed(requestID, notification, err.Error())
+		} else {
+			restErr = ns.notificationPGDB.MarkLoanNotificationSent(requestID, notification)
+		}
+
+		if restErr != nil {
+			return 0, restErr
+		}
+	}
+
+	if len(notifications) > 0 {
+		log.Info().Msgf("processed %d loan notification(s)", len(notifications))
+	}
+	return len(notifications), nil
+}
+
+func (ns *NotificationService) sendLoanNotification(notification dto.LoanNotification) error {
+	subject, body, err := renderLoanNotification(notification)
+	if err != nil {
+		return err
+	}
+
+	return ns.mailer.SendMail(notification.Email, subject, body)
+}
+
+func renderLoanNotification(notification dto.LoanNotification) (string, string, error) {
+	tmpl, ok := notificationTemplates[notification.Kind]
+	if !ok {
+		return "", "", fmt.Errorf("no template for notification kind '%s'", notification.Kind)
+	}
+
+	data := struct {
+		NameOfBorrower string
+		BookTitle      string
+		ReturnDate     string
+	}{notification.NameOfBorrower, notification.BookTitle, notification.ReturnDate.UTC().Format(notificationDateLayout)}
+
+	var subject, body strings.Builder
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", fmt.Errorf("error rendering subject: %w", err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return "", "", fmt.Errorf("error rendering body: %w", err)
+	}
+
+	return subject.String(), body.String(), nil
+}
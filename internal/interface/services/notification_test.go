@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockNotificationRepository struct{ mock.Mock }
+
+func (m *mockNotificationRepository) ClaimLoanNotifications(requestID string, batchSize int) ([]dto.LoanNotification, *apperrors.RestErr) {
+	args := m.Called(requestID, batchSize)
+	notifications, ok := args.Get(0).([]dto.LoanNotification)
+	if !ok {
+		return nil, args.Get(1).(*apperrors.RestErr)
+	}
+	return notifications, nil
+}
+
+func (m *mockNotificationRepository) MarkLoanNotificationSent(requestID string, notification dto.LoanNotification) *apperrors.RestErr {
+	m.Called(requestID, notification)
+	return nil
+}
+
+func (m *mockNotificationRepository) MarkLoanNotificationFailed(requestID string, notification dto.LoanNotification, reason string) *apperrors.RestErr {
+	m.Called(requestID, notification, reason)
+	return nil
+}
+
+type sentMail struct{ to, subject, body string }
+
+type mockMailer struct {
+	sent    []sentMail
+	failFor string
+}
+
+func (m *mockMailer) SendMail(to string, subject string, body string) error {
+	if to == m.failFor {
+		return errors.New("550 mailbox unavailable")
+	}
+	m.sent = append(m.sent, sentMail{to, subject, body})
+	return nil
+}
+
+func TestSendLoanNotifications(t *testing.T) {
+	returnDate := time.Date(2026, 3, 6, 9, 30, 0, 0, time.UTC)
+	dueSoon := dto.LoanNotification{
+		LoanUUID: uuid.New(), Kind: dto.LoanNotificationDueSoon, ReturnDate: returnDate,
+		Email: "ann@example.com", NameOfBorrower: "Ann", BookTitle: "Anna Karenina",
+	}
+	extended := dueSoon
+	extended.LoanUUID, extended.Kind = uuid.New(), dto.LoanNotificationExtended
+	expired := dueSoon
+	expired.LoanUUID, expired.Kind, expired.Email = uuid.New(), dto.LoanNotificationExpired, "gone@example.com"
+
+	notificationRepo := new(mockNotificationRepository)
+	notificationRepo.On("ClaimLoanNotifications", "request-1", 10).
+		Return([]dto.LoanNotification{dueSoon, extended, expired}, nil)
+	notificationRepo.On("MarkLoanNotificationSent", "request-1", dueSoon).Return()
+	notificationRepo.On("MarkLoanNotificationSent", "request-1", extended).Return()
+	notificationRepo.On("MarkLoanNotificationFailed", "request-1", expired, "550 mailbox unavailable").Return()
+
+	mailer := &mockMailer{failFor: "gone@example.com"}
+	notificationService := NewNotificationService(notificationRepo, mailer)
+
+	claimedCount, restErr := notificationService.SendLoanNotifications("request-1", 10)
+	assert.Nil(t, restErr)
+	assert.Equal(t, 3, claimedCount)
+	notificationRepo.AssertExpectations(t)
+
+	assert.Len(t, mailer.sent, 2)
+	assert.Equal(t, "ann@example.com", mailer.sent[0].to)
+	assert.Equal(t, `"Anna Karenina" is due on Friday, 6 March 2026 09:30 UTC`, mailer.sent[0].subject)
+	assert.Contains(t, mailer.sent[0].body, "Hi Ann,\n\nYour loan of \"Anna Karenina\" ends on Friday, 6 March 2026 09:30 UTC")
+	assert.Equal(t, `Your loan of "Anna Karenina" is extended`, mailer.sent[1].subject)
+	assert.Contains(t, mailer.sent[1].body, "It now ends on Friday, 6 March 2026 09:30 UTC.")
+}
+
+func TestRenderLoanNotification(t *testing.T) {
+	for _, kind := range []string{dto.LoanNotificationDueSoon, dto.LoanNotificationExtended, dto.LoanNotificationExpired} {
+		subject, body, err := renderLoanNotification(dto.LoanNotification{
+			Kind: kind, ReturnDate: time.Now(), NameOfBorrower: "Ann", BookTitle: "Anna Karenina",
+		})
+		assert.NoError(t, err, kind)
+		assert.Contains(t, subject, "Anna Karenina", kind)
+		assert.NotContains(t, subject, "\n", kind)
+		assert.Contains(t, body, "Hi Ann,", kind)
+	}
+
+	_, _, err := renderLoanNotification(dto.LoanNotification{Kind: "overdue"})
+	assert.Error(t, err)
+}
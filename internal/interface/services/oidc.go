@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+const (
+	errMsgUnknownProvider   = "unknown login provider"
+	errMsgOIDC              = "oidc error"
+	errMsgEmailNotVerified  = "the email of this account is not verified by the provider"
+	oidcRequestTimeout      = 10 * time.Second
+	oidcDiscoveryRetryDelay = 30 * time.Second
+)
+
+// oidcProvider is discovered on its first login rather than at startup, so that a provider that is down
+// does not keep the server, or the other providers, from starting.
+type oidcProvider struct {
+	config *entity.OIDCProviderConfig
+
+	mu             sync.Mutex
+	provider       *oidc.Provider
+	lastDiscoverAt time.Time
+}
+
+// oidcClaims are the claims of an ID token that are mapped to a user.
+type oidcClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+type OIDC struct {
+	providers         map[string]*oidcProvider
+	httpClient        *http.Client
+	redirectAllowList []string
+	userPGDB          repository.UserRepository
+	sessionRedis      repository.SessionRepository
+	oauth2StateRedis  repository.OAuth2StateRepository
+}
+
+func NewOIDC(providerConfigs []*entity.OIDCProviderConfig, redirectAllowList []string,
+	userPGDB repository.UserRepository, sessionRedis repository.SessionRepository,
+	oauth2StateRedis repository.OAuth2StateRepository,
+) services.OIDCService {
+	providers := make(map[string]*oidcProvider, len(providerConfigs))
+	for _, config := range providerConfigs {
+		providers[config.Name] = &oidcProvider{config: config}
+	}
+
+	httpClient := &http.Client{Timeout: oidcRequestTimeout}
+	return &OIDC{providers, httpClient, redirectAllowList, userPGDB, sessionRedis, oauth2StateRedis}
+}
+
+// Login sends the user to the provider with a new state, nonce and PKCE challenge. As with Google, the
+// state is bound to the browser by the pre-auth cookie.
+func (o *OIDC) Login(c *fiber.Ctx) error {
+	p, ok := o.providers[c.Params("provider")]
+	if !ok {
+		err := apperrors.NewNotFoundError(errMsgUnknownProvider)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	redirectTo := c.Query("redirect_to")
+	if redirectTo != "" && !isAllowedRedirect(redirectTo, o.redirectAllowList) {
+		err := apperrors.NewBadRequestError(errMsgRedirectNotAllowed)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestTimeout)
+	defer cancel()
+
+	provider, err := p.discover(oidc.ClientContext(ctx, o.httpClient))
+	if err != nil {
+		log.Error().Err(err).Str("provider", p.config.Name).Msg(errMsgOIDC)
+		err := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	state, stateErr := newRandomToken()
+	loginID, loginIDErr := newRandomToken()
+	nonce, nonceErr := newRandomToken()
+	if err := errors.Join(stateErr, loginIDErr, nonceErr); err != nil {
+		log.Error().Err(err).Msg("failed to generate oidc state")
+		err := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	loginState := &entity.OAuth2LoginState{
+		Provider: p.config.Name, LoginID: loginID, CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce: nonce, RedirectTo: redirectTo, RememberMe: c.QueryBool("remember_me"),
+	}
+	if err := o.oauth2StateRedis.SaveLoginState(state, loginState); err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	c.Cookie(newOAuth2LoginCookie(loginID, oauth2LoginCookieMaxAgeSec))
+	url := p.oauth2Config(provider).AuthCodeURL(state,
+		oidc.Nonce(nonce), oauth2.S256ChallengeOption(loginState.CodeVerifier))
+	return c.Redirect(url, fiber.StatusSeeOther)
+}
+
+// Callback exchanges the code for an ID token, which must be signed by a key of the provider, for this
+// client and with the nonce of the login, and logs in the user of its subject.
+func (o *OIDC) Callback(c *fiber.Ctx) error {
+	p, ok := o.providers[c.Params("provider")]
+	if !ok {
+		err := apperrors.NewNotFoundError(errMsgUnknownProvider)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	loginState, restErr := takeLoginState(c, o.oauth2StateRedis, p.config.Name)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestTimeout)
+	defer cancel()
+	ctx = oidc.ClientContext(ctx, o.httpClient)
+
+	provider, err := p.discover(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("provider", p.config.Name).Msg(errMsgOIDC)
+		err := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	token, err := p.oauth2Config(provider).Exchange(ctx, c.Query("code"), oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		log.Warn().Err(err).Str("provider", p.config.Name).Msg("failed to exchange the oidc code")
+		err := apperrors.NewBadRequestError(errMsgPleaseLoginAgain)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	user, err := p.verifyIDToken(ctx, provider, token, loginState.Nonce)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", p.config.Name).Msg("refused the oidc id token")
+		err := apperrors.NewBadRequestError(errMsgPleaseLoginAgain)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	// An unverified email could claim the email of another user.
+	if !user.VerifiedEmail {
+		err := apperrors.NewForbiddenError(errMsgEmailNotVerified)
+		return c.Status(err.Status).JSON(err)
+	}
+
+	libUserID, restErr := saveProviderUser(o.userPGDB, user, p.config.Name, p.config.TrustEmail)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	if restErr := startSession(c, o.userPGDB, o.sessionRedis, libUserID, loginState.RememberMe); restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	if loginState.RedirectTo != "" {
+		return c.Redirect(loginState.RedirectTo, fiber.StatusSeeOther)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"user": user})
+}
+
+// discover reads the discovery document of the issuer once it is first needed. A failed discovery is
+// retried, at most once per delay so that logins do not flood a provider that is down.
+func (p *oidcProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider != nil {
+		return p.provider, nil
+	}
+	if time.Since(p.lastDiscoverAt) < oidcDiscoveryRetryDelay {
+		return nil, fmt.Errorf("discovery of '%s' failed recently", p.config.IssuerURL)
+	}
+
+	p.lastDiscoverAt = time.Now()
+	provider, err := oidc.NewProvider(ctx, p.config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering '%s': %w", p.config.IssuerURL, err)
+	}
+
+	p.provider = provider
+	return provider, nil
+}
+
+func (p *oidcProvider) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.config.Scopes,
+	}
+}
+
+// verifyIDToken checks the signature, issuer, audience and expiry of the ID token, and its nonce, and maps
+// its claims to a user of the provider.
+func (p *oidcProvider) verifyIDToken(ctx context.Context, provider *oidc.Provider, token *oauth2.Token, nonce string,
+) (*dto.GoogleOAuth2UserRes, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("id token has another nonce")
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("error decoding the claims of the id token: %w", err)
+	}
+	if claims.Email == "" {
+		return nil, errors.New("id token has no email claim")
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	if name == "" {
+		name = claims.Email
+	}
+
+	// The subject, not the email, identifies the account at the provider.
+	return &dto.GoogleOAuth2UserRes{
+		ID: idToken.Subject, Name: name, Email: claims.Email, VerifiedEmail: claims.EmailVerified,
+	}, nil
+}
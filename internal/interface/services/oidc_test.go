@@ -0,0 +1,386 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/coreos/go-oidc/v3/oidc/oidctest"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	stubClientID     = "e-lib"
+	stubClientSecret = "e-lib-secret"
+	stubCode         = "stub-code"
+)
+
+// stubOIDCServer is a provider that serves discovery and keys, and a token endpoint that checks the client,
+// the code and its PKCE verifier before it issues an ID token with the claims of the test.
+type stubOIDCServer struct {
+	*httptest.Server
+	key           *rsa.PrivateKey
+	signingKey    *rsa.PrivateKey // The key that signs the ID token; another key than the advertised one is forged
+	codeChallenge string          // Of the login that the token endpoint expects
+	claims        map[string]any
+}
+
+func newStubOIDCServer(t *testing.T) *stubOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	stub := &stubOIDCServer{key: key}
+	discovery := &oidctest.Server{
+		PublicKeys: []oidctest.PublicKey{{PublicKey: key.Public(), KeyID: "stub-key", Algorithm: "RS256"}},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", discovery)
+	mux.HandleFunc("POST /token", stub.serveToken)
+	stub.Server = httptest.NewServer(mux)
+	t.Cleanup(stub.Close)
+	discovery.SetIssuer(stub.URL)
+
+	return stub
+}
+
+func (s *stubOIDCServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != stubClientID || clientSecret != stubClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	verifierHash := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code") != stubCode ||
+		base64.RawURLEncoding.EncodeToString(verifierHash[:]) != s.codeChallenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims, _ := json.Marshal(s.claims)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "stub-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     oidctest.SignIDToken(s.signingKey, "stub-key", "RS256", string(claims)),
+	})
+}
+
+type fakeUserRepository struct {
+	users   map[string]*dto.GoogleOAuth2UserRes // By provider and subject
+	userIDs map[string]int64                    // By provider and subject
+	emails  map[string]int64                    // User IDs by email
+	saved   int
+	linked  int
+}
+
+func (r *fakeUserRepository) GetUserByID(userID int64) (dto.UserDetail, *apperrors.RestErr) {
+	return dto.UserDetail{ID: userID, Role: entity.RolePatron}, nil
+}
+
+func (r *fakeUserRepository) GetUserID(provider string, id string) (int64, *apperrors.RestErr) {
+	if userID, ok := r.userIDs[provider+"/"+id]; ok {
+		return userID, nil
+	}
+	return -1, nil
+}
+
+func (r *fakeUserRepository) GetUserIDByEmail(email string) (int64, *apperrors.RestErr) {
+	if userID, ok := r.emails[email]; ok {
+		return userID, nil
+	}
+	return -1, nil
+}
+
+func (r *fakeUserRepository) LinkProvider(userID int64, user *dto.GoogleOAuth2UserRes, provider string) *apperrors.RestErr {
+	r.users[provider+"/"+user.ID] = user
+	r.userIDs[provider+"/"+user.ID] = userID
+	r.linked++
+	return nil
+}
+
+func (r *fakeUserRepository) SaveUser(user *dto.GoogleOAuth2UserRes, provider string) (*entity.User, *apperrors.RestErr) {
+	if _, ok := r.emails[user.Email]; ok {
+		return nil, apperrors.NewConflictError("the email is already used by another account")
+	}
+
+	r.saved++
+	userID := int64(r.saved)
+	r.users[provider+"/"+user.ID] = user
+	r.userIDs[provider+"/"+user.ID] = userID
+	r.emails[user.Email] = userID
+	return &entity.User{ID: userID, Name: user.Name, Email: user.Email}, nil
+}
+
+type fakeSessionRepository struct {
+	userIDs    []int64
+	rememberMe []bool
+}
+
+func (r *fakeSessionRepository) NewSession(userID int64, role string, client entity.SessionClient, rememberMe bool,
+) (string, time.Duration, *apperrors.RestErr) {
+	r.userIDs = append(r.userIDs, userID)
+	r.rememberMe = append(r.rememberMe, rememberMe)
+	return "stub-session", 7 * 24 * time.Hour, nil
+}
+
+func (r *fakeSessionRepository) RefreshSession(sessionID string, session *entity.Session, role string,
+) (string, time.Duration, *apperrors.RestErr) {
+	return sessionID, 30 * time.Minute, nil
+}
+
+func (r *fakeSessionRepository) GetSessionData(sessionID string) (*entity.Session, *apperrors.RestErr) {
+	return nil, apperrors.NewUnauthorizedError("please login again")
+}
+
+func (r *fakeSessionRepository) GetUserSessions(userID int64) ([]entity.Session, *apperrors.RestErr) {
+	return []entity.Session{}, nil
+}
+
+func (r *fakeSessionRepository) DeleteSession(userID int64, id string) *apperrors.RestErr {
+	return apperrors.NewNotFoundError("session not found")
+}
+
+func (r *fakeSessionRepository) DeleteSessionData(sessionID string) *apperrors.RestErr {
+	return nil
+}
+
+func (r *fakeSessionRepository) DeleteUserSessions(userID int64, exceptID string) (int, *apperrors.RestErr) {
+	return 0, nil
+}
+
+type fakeOAuth2StateRepository struct {
+	states map[string]entity.OAuth2LoginState
+}
+
+func (r *fakeOAuth2StateRepository) SaveLoginState(state string, loginState *entity.OAuth2LoginState) *apperrors.RestErr {
+	r.states[state] = *loginState
+	return nil
+}
+
+func (r *fakeOAuth2StateRepository) TakeLoginState(state string) (*entity.OAuth2LoginState, *apperrors.RestErr) {
+	loginState, ok := r.states[state]
+	if !ok {
+		return nil, nil
+	}
+	delete(r.states, state)
+	return &loginState, nil
+}
+
+func TestOIDCLogin(t *testing.T) {
+	stub := newStubOIDCServer(t)
+	userRepo := &fakeUserRepository{
+		users: map[string]*dto.GoogleOAuth2UserRes{}, userIDs: map[string]int64{}, emails: map[string]int64{},
+	}
+	sessionRepo := &fakeSessionRepository{}
+	stateRepo := &fakeOAuth2StateRepository{states: map[string]entity.OAuth2LoginState{}}
+
+	providerConfig := &entity.OIDCProviderConfig{
+		Name: "stub", IssuerURL: stub.URL, ClientID: stubClientID, ClientSecret: stubClientSecret,
+		RedirectURL: "http://localhost:3000/auth/oidc/stub/callback", Scopes: []string{"openid", "email", "profile"},
+	}
+	oidcService := NewOIDC([]*entity.OIDCProviderConfig{providerConfig}, nil, userRepo, sessionRepo, stateRepo)
+
+	app := fiber.New()
+	app.Get("/auth/oidc/:provider/login", oidcService.Login)
+	app.Get("/auth/oidc/:provider/callback", oidcService.Callback)
+
+	// login starts a login and gives the stub its PKCE challenge and the claims of its ID token.
+	loginWith := func(t *testing.T, query string) (state string, loginID string, claims map[string]any) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/auth/oidc/stub/login?"+query, nil))
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusSeeOther, resp.StatusCode)
+
+		location, err := url.Parse(resp.Header.Get("Location"))
+		assert.Nil(t, err)
+		assert.Equal(t, stub.URL+"/auth", location.Scheme+"://"+location.Host+location.Path)
+		authQuery := location.Query()
+		assert.Equal(t, stubClientID, authQuery.Get("client_id"))
+		assert.Equal(t, "openid email profile", authQuery.Get("scope"))
+		assert.Equal(t, "S256", authQuery.Get("code_challenge_method"))
+		assert.NotEmpty(t, authQuery.Get("nonce"))
+
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == cookieOAuth2Login {
+				loginID = cookie.Value
+			}
+		}
+		assert.NotEmpty(t, loginID)
+
+		stub.signingKey = stub.key
+		stub.codeChallenge = authQuery.Get("code_challenge")
+		claims = map[string]any{
+			"iss":            stub.URL,
+			"aud":            stubClientID,
+			"sub":            "alice-subject",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          authQuery.Get("nonce"),
+			"email":          "alice@example.com",
+			"email_verified": true,
+			"name":           "Alice",
+		}
+		stub.claims = claims
+		return authQuery.Get("state"), loginID, claims
+	}
+	login := func(t *testing.T) (string, string, map[string]any) {
+		return loginWith(t, "redirect_to=/Loans")
+	}
+	callback := func(t *testing.T, state string, loginID string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/auth/oidc/stub/callback?code="+stubCode+"&state="+url.QueryEscape(state), nil)
+		req.AddCookie(&http.Cookie{Name: cookieOAuth2Login, Value: loginID})
+		resp, err := app.Test(req)
+		assert.Nil(t, err)
+		return resp
+	}
+
+	t.Run("A verified ID token logs in the user of its subject", func(t *testing.T) {
+		state, loginID, _ := login(t)
+		resp := callback(t, state, loginID)
+		assert.Equal(t, http.StatusSeeOther, resp.StatusCode)
+		assert.Equal(t, "/Loans", resp.Header.Get("Location"))
+		assert.Contains(t, resp.Header.Values("Set-Cookie"), "session_id=stub-session; path=/; HttpOnly; secure; SameSite=Strict")
+
+		assert.Equal(t, &dto.GoogleOAuth2UserRes{
+			ID: "alice-subject", Name: "Alice", Email: "alice@example.com", VerifiedEmail: true,
+		}, userRepo.users["stub/alice-subject"])
+		assert.Equal(t, []int64{1}, sessionRepo.userIDs)
+
+		// The next login of the subject finds the user.
+		state, loginID, _ = login(t)
+		assert.Equal(t, http.StatusSeeOther, callback(t, state, loginID).StatusCode)
+		assert.Equal(t, 1, userRepo.saved)
+	})
+
+	// Bob joined with Google before the provider was configured.
+	_, restErr := userRepo.SaveUser(&dto.GoogleOAuth2UserRes{
+		ID: "bob-google", Name: "Bob", Email: "bob@example.com", VerifiedEmail: true,
+	}, providerGoogle)
+	assert.Nil(t, restErr)
+	bobID := userRepo.emails["bob@example.com"]
+
+	t.Run("The email of another user is a conflict at a provider that is not trusted with emails", func(t *testing.T) {
+		sessionsBefore := len(sessionRepo.userIDs)
+		state, loginID, claims := login(t)
+		claims["sub"], claims["email"], claims["name"] = "bob-subject", "bob@example.com", "Bob"
+
+		resp := callback(t, state, loginID)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Len(t, sessionRepo.userIDs, sessionsBefore)
+		assert.Equal(t, 0, userRepo.linked)
+	})
+
+	t.Run("The first login with a trusted provider links the user of a verified email", func(t *testing.T) {
+		providerConfig.TrustEmail = true
+		defer func() { providerConfig.TrustEmail = false }()
+
+		state, loginID, claims := login(t)
+		claims["sub"], claims["email"], claims["name"] = "bob-subject", "bob@example.com", "Bob"
+		assert.Equal(t, http.StatusSeeOther, callback(t, state, loginID).StatusCode)
+		assert.Equal(t, bobID, userRepo.userIDs["stub/bob-subject"])
+		assert.Equal(t, bobID, sessionRepo.userIDs[len(sessionRepo.userIDs)-1])
+		assert.Equal(t, 1, userRepo.linked)
+
+		// The subject, not its email, identifies the account after the email changes at the provider.
+		state, loginID, claims = login(t)
+		claims["sub"], claims["email"], claims["name"] = "bob-subject", "robert@example.com", "Bob"
+		assert.Equal(t, http.StatusSeeOther, callback(t, state, loginID).StatusCode)
+		assert.Equal(t, bobID, sessionRepo.userIDs[len(sessionRepo.userIDs)-1])
+		assert.Equal(t, 1, userRepo.linked)
+		assert.Equal(t, 2, userRepo.saved)
+	})
+
+	t.Run("Remember me starts a session whose cookie outlives the browser", func(t *testing.T) {
+		state, loginID, _ := loginWith(t, "remember_me=true")
+		resp := callback(t, state, loginID)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Values("Set-Cookie"), "session_id=stub-session; max-age=604800; path=/; HttpOnly; secure; SameSite=Strict")
+		assert.Equal(t, []bool{false, false, false, false, true}, sessionRepo.rememberMe)
+	})
+
+	refusedTests := []struct {
+		description  string
+		tamper       func(claims map[string]any)
+		expectedCode int
+	}{
+		{
+			description: "An ID token signed by another key is refused",
+			tamper: func(claims map[string]any) {
+				forgedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+				assert.Nil(t, err)
+				stub.signingKey = forgedKey
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			description:  "An ID token with the nonce of another login is refused",
+			tamper:       func(claims map[string]any) { claims["nonce"] = "another-nonce" },
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			description:  "An ID token for another client is refused",
+			tamper:       func(claims map[string]any) { claims["aud"] = "another-client" },
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			description:  "An ID token of another issuer is refused",
+			tamper:       func(claims map[string]any) { claims["iss"] = "https://issuer.example.com" },
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			description:  "An expired ID token is refused",
+			tamper:       func(claims map[string]any) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			description:  "A code without the PKCE verifier of its login is refused",
+			tamper:       func(claims map[string]any) { stub.codeChallenge = "another-challenge" },
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			description:  "An ID token without an email is refused",
+			tamper:       func(claims map[string]any) { delete(claims, "email") },
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			description:  "An unverified email is forbidden",
+			tamper:       func(claims map[string]any) { claims["email_verified"] = false },
+			expectedCode: http.StatusForbidden,
+		},
+	}
+	for _, test := range refusedTests {
+		t.Run(test.description, func(t *testing.T) {
+			sessionsBefore := len(sessionRepo.userIDs)
+			state, loginID, claims := login(t)
+			test.tamper(claims)
+
+			resp := callback(t, state, loginID)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			assert.Len(t, sessionRepo.userIDs, sessionsBefore)
+		})
+	}
+
+	t.Run("The state of a login with another provider is refused", func(t *testing.T) {
+		stateRepo.states["google-state"] = entity.OAuth2LoginState{Provider: providerGoogle, LoginID: "google-login"}
+		resp := callback(t, "google-state", "google-login")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"message":"please login again","status":404}`, string(body))
+	})
+}
@@ -0,0 +1,541 @@
+package services
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	appSvc "github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	opdsPath   = "/opds"
+	opdsV2Path = "/opds/v2"
+
+	opdsCatalogTitle = "e-lib"
+	opdsSearchLimit  = 20
+
+	atomNamespace       = "http://www.w3.org/2005/Atom"
+	dcNamespace         = "http://purl.org/dc/terms/"
+	opdsNamespace       = "http://opds-spec.org/2010/catalog"
+	openSearchNamespace = "http://a9.com/-/spec/opensearch/1.1/"
+	schemaOrgBook       = "http://schema.org/Book"
+
+	opdsStatusAvailable   = "available"
+	opdsStatusUnavailable = "unavailable"
+	opdsStatusReady       = "ready" // Borrowed by the patron
+)
+
+// opdsShelf is an entry of the navigation feeds; each shelf is a preset query of the books feed.
+type opdsShelf struct {
+	id    string
+	title string
+	query string
+}
+
+var opdsShelves = []opdsShelf{
+	{"all", "All books", ""},
+	{"available", "Available now", "available=true"},
+	{"newest", "Newest additions", "sort_by=created_at&order=desc"},
+}
+
+/*
+OPDSService renders the catalog as OPDS feeds. The books feed pages through BookRepository.ListBooks with its cursors,
+the search feed pages through the full-text search, and each book carries a borrow link to the LoanService.
+*/
+type OPDSService struct {
+	bookPGDB     repository.BookRepository
+	searchEngine repository.SearchRepository
+	loanService  appSvc.LoanService
+}
+
+func NewOPDSService(
+	bookPGDB repository.BookRepository, searchEngine repository.SearchRepository, loanService appSvc.LoanService,
+) appSvc.OPDSService {
+	return &OPDSService{bookPGDB, searchEngine, loanService}
+}
+
+/********************
+ *    OPDS 1.2     *
+ ********************/
+
+func (ops *OPDSService) NavigationFeedHandler(c *fiber.Ctx) error {
+	baseURL := c.BaseURL()
+	feed := newAtomFeed("urn:e-lib:opds", opdsCatalogTitle,
+		dto.AtomLink{Rel: "self", Href: baseURL + opdsPath, Type: dto.OPDSNavigationFeedType},
+		dto.AtomLink{Rel: "start", Href: baseURL + opdsPath, Type: dto.OPDSNavigationFeedType},
+		opdsSearchLink(baseURL),
+	)
+
+	for _, shelf := range opdsShelves {
+		feed.Entries = append(feed.Entries, dto.AtomEntry{
+			ID:      "urn:e-lib:opds:" + shelf.id,
+			Title:   shelf.title,
+			Updated: feed.Updated,
+			Content: &dto.AtomContent{Type: "text", Value: shelf.title},
+			Links: []dto.AtomLink{{
+				Rel: "subsection", Href: withQuery(baseURL+opdsPath+"/books", shelf.query), Type: dto.OPDSAcquisitionFeedType,
+			}},
+		})
+	}
+
+	return sendAtom(c, dto.OPDSNavigationFeedType, feed)
+}
+
+func (ops *OPDSService) BooksFeedHandler(c *fiber.Ctx) error {
+	params, requestID := getBookListContextInfo(c)
+	bookList, err := ops.bookPGDB.ListBooks(requestID, params)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	baseURL := c.BaseURL()
+	booksURL := baseURL + opdsPath + "/books"
+	feed := newAtomFeed("urn:e-lib:opds:books", "Books",
+		dto.AtomLink{Rel: "self", Href: baseURL + c.OriginalURL(), Type: dto.OPDSAcquisitionFeedType},
+		dto.AtomLink{Rel: "start", Href: baseURL + opdsPath, Type: dto.OPDSNavigationFeedType},
+		dto.AtomLink{Rel: "first", Href: bookListPageURL(booksURL, params, ""), Type: dto.OPDSAcquisitionFeedType},
+		opdsSearchLink(baseURL),
+	)
+	feed.ItemsPerPage = params.Limit
+
+	if bookList.NextCursor != "" {
+		feed.Links = append(feed.Links, dto.AtomLink{
+			Rel: "next", Href: bookListPageURL(booksURL, params, bookList.NextCursor), Type: dto.OPDSAcquisitionFeedType,
+		})
+	}
+	if bookList.PrevCursor != "" {
+		feed.Links = append(feed.Links, dto.AtomLink{
+			Rel: "previous", Href: bookListPageURL(booksURL, params, bookList.PrevCursor), Type: dto.OPDSAcquisitionFeedType,
+		})
+	}
+
+	for _, book := range bookList.Books {
+		feed.Entries = append(feed.Entries, bookEntry(baseURL, book, feed.Updated))
+	}
+
+	return sendAtom(c, dto.OPDSAcquisitionFeedType, feed)
+}
+
+func (ops *OPDSService) SearchFeedHandler(c *fiber.Ctx) error {
+	params, requestID := getOPDSSearchContextInfo(c)
+	searchResponse, err := ops.search(requestID, params)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	baseURL := c.BaseURL()
+	searchURL := baseURL + opdsPath + "/search"
+	feed := newAtomFeed("urn:e-lib:opds:search:"+url.QueryEscape(params.Query), "Search results for "+params.Query,
+		dto.AtomLink{Rel: "self", Href: searchPageURL(searchURL, params.Query, params.Page), Type: dto.OPDSAcquisitionFeedType},
+		dto.AtomLink{Rel: "start", Href: baseURL + opdsPath, Type: dto.OPDSNavigationFeedType},
+		opdsSearchLink(baseURL),
+	)
+	feed.TotalResults = &searchResponse.Total
+	feed.ItemsPerPage = opdsSearchLimit
+	feed.StartIndex = (params.Page-1)*opdsSearchLimit + 1
+
+	if hasNextSearchPage(params, searchResponse) {
+		feed.Links = append(feed.Links, dto.AtomLink{
+			Rel: "next", Href: searchPageURL(searchURL, params.Query, params.Page+1), Type: dto.OPDSAcquisitionFeedType,
+		})
+	}
+	if params.Page > 1 {
+		feed.Links = append(feed.Links, dto.AtomLink{
+			Rel: "previous", Href: searchPageURL(searchURL, params.Query, params.Page-1), Type: dto.OPDSAcquisitionFeedType,
+		})
+	}
+
+	for _, hit := range searchResponse.Hits {
+		feed.Entries = append(feed.Entries, bookEntry(baseURL, hit.Book, feed.Updated))
+	}
+
+	return sendAtom(c, dto.OPDSAcquisitionFeedType, feed)
+}
+
+func (ops *OPDSService) OpenSearchDescriptionHandler(c *fiber.Ctx) error {
+	return sendAtom(c, dto.OpenSearchType, dto.OpenSearchDescription{
+		Xmlns:          openSearchNamespace,
+		ShortName:      opdsCatalogTitle,
+		Description:    "Search the e-lib catalog by title, author or description",
+		InputEncoding:  "UTF-8",
+		OutputEncoding: "UTF-8",
+		URL: dto.OpenSearchURL{
+			Type:     dto.OPDSAcquisitionFeedType,
+			Template: c.BaseURL() + opdsPath + "/search?q={searchTerms}&page={startPage?}",
+		},
+	})
+}
+
+// BorrowEntryHandler answers a GET of the borrow link with the book's entry and borrows nothing,
+// so that following the link previews the copies before the client POSTs to it to borrow.
+func (ops *OPDSService) BorrowEntryHandler(c *fiber.Ctx) error {
+	requestID, bookUUID, restErr := getCatalogContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	bookDetail, restErr := ops.bookPGDB.GetBookByUUID(requestID, bookUUID)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	entry := bookEntry(c.BaseURL(), *bookDetail, time.Now().UTC().Format(time.RFC3339))
+	entry.Xmlns, entry.XmlnsDC, entry.XmlnsOPDS = atomNamespace, dcNamespace, opdsNamespace
+	return sendAtom(c, dto.OPDSEntryType, entry)
+}
+
+// BorrowHandler borrows the book and answers with its entry, which marks the loan as `ready` until the return date.
+func (ops *OPDSService) BorrowHandler(c *fiber.Ctx) error {
+	bookDetail, loanDetail, restErr := ops.borrow(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	entry := bookEntry(c.BaseURL(), *bookDetail, time.Now().UTC().Format(time.RFC3339))
+	entry.Xmlns, entry.XmlnsDC, entry.XmlnsOPDS = atomNamespace, dcNamespace, opdsNamespace
+	entry.Links[0].Availability = &dto.OPDSAvailability{
+		Status: opdsStatusReady,
+		Since:  loanDetail.LoanDate.UTC().Format(time.RFC3339),
+		Until:  loanDetail.ReturnDate.UTC().Format(time.RFC3339),
+	}
+
+	return sendAtom(c, dto.OPDSEntryType, entry)
+}
+
+/********************
+ *    OPDS 2.0     *
+ ********************/
+
+func (ops *OPDSService) NavigationFeedV2Handler(c *fiber.Ctx) error {
+	baseURL := c.BaseURL()
+	feed := dto.OPDS2Feed{
+		Metadata: dto.OPDS2FeedMetadata{Title: opdsCatalogTitle},
+		Links: []dto.OPDS2Link{
+			{Rel: "self", Href: baseURL + opdsV2Path, Type: dto.OPDS2FeedType},
+			opdsV2SearchLink(baseURL),
+		},
+	}
+
+	for _, shelf := range opdsShelves {
+		feed.Navigation = append(feed.Navigation, dto.OPDS2Link{
+			Href: withQuery(baseURL+opdsV2Path+"/books", shelf.query), Type: dto.OPDS2FeedType, Title: shelf.title,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(feed, dto.OPDS2FeedType)
+}
+
+func (ops *OPDSService) BooksFeedV2Handler(c *fiber.Ctx) error {
+	params, requestID := getBookListContextInfo(c)
+	bookList, err := ops.bookPGDB.ListBooks(requestID, params)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	baseURL := c.BaseURL()
+	booksURL := baseURL + opdsV2Path + "/books"
+	feed := dto.OPDS2Feed{
+		Metadata: dto.OPDS2FeedMetadata{Title: "Books", ItemsPerPage: params.Limit},
+		Links: []dto.OPDS2Link{
+			{Rel: "self", Href: baseURL + c.OriginalURL(), Type: dto.OPDS2FeedType},
+			{Rel: "start", Href: baseURL + opdsV2Path, Type: dto.OPDS2FeedType},
+			{Rel: "first", Href: bookListPageURL(booksURL, params, ""), Type: dto.OPDS2FeedType},
+			opdsV2SearchLink(baseURL),
+		},
+		Publications: []dto.OPDS2Publication{},
+	}
+
+	if bookList.NextCursor != "" {
+		feed.Links = append(feed.Links, dto.OPDS2Link{
+			Rel: "next", Href: bookListPageURL(booksURL, params, bookList.NextCursor), Type: dto.OPDS2FeedType,
+		})
+	}
+	if bookList.PrevCursor != "" {
+		feed.Links = append(feed.Links, dto.OPDS2Link{
+			Rel: "previous", Href: bookListPageURL(booksURL, params, bookList.PrevCursor), Type: dto.OPDS2FeedType,
+		})
+	}
+
+	for _, book := range bookList.Books {
+		feed.Publications = append(feed.Publications, bookPublication(baseURL, book))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(feed, dto.OPDS2FeedType)
+}
+
+func (ops *OPDSService) SearchFeedV2Handler(c *fiber.Ctx) error {
+	params, requestID := getOPDSSearchContextInfo(c)
+	searchResponse, err := ops.search(requestID, params)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	baseURL := c.BaseURL()
+	searchURL := baseURL + opdsV2Path + "/search"
+	feed := dto.OPDS2Feed{
+		Metadata: dto.OPDS2FeedMetadata{
+			Title:         "Search results for " + params.Query,
+			NumberOfItems: &searchResponse.Total,
+			ItemsPerPage:  opdsSearchLimit,
+			CurrentPage:   params.Page,
+		},
+		Links: []dto.OPDS2Link{
+			{Rel: "self", Href: searchPageURL(searchURL, params.Query, params.Page), Type: dto.OPDS2FeedType},
+			{Rel: "start", Href: baseURL + opdsV2Path, Type: dto.OPDS2FeedType},
+			opdsV2SearchLink(baseURL),
+		},
+		Publications: []dto.OPDS2Publication{},
+	}
+
+	if hasNextSearchPage(params, searchResponse) {
+		feed.Links = append(feed.Links, dto.OPDS2Link{
+			Rel: "next", Href: searchPageURL(searchURL, params.Query, params.Page+1), Type: dto.OPDS2FeedType,
+		})
+	}
+	if params.Page > 1 {
+		feed.Links = append(feed.Links, dto.OPDS2Link{
+			Rel: "previous", Href: searchPageURL(searchURL, params.Query, params.Page-1), Type: dto.OPDS2FeedType,
+		})
+	}
+
+	for _, hit := range searchResponse.Hits {
+		feed.Publications = append(feed.Publications, bookPublication(baseURL, hit.Book))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(feed, dto.OPDS2FeedType)
+}
+
+// BorrowEntryV2Handler is the OPDS 2.0 BorrowEntryHandler.
+func (ops *OPDSService) BorrowEntryV2Handler(c *fiber.Ctx) error {
+	requestID, bookUUID, restErr := getCatalogContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	bookDetail, restErr := ops.bookPGDB.GetBookByUUID(requestID, bookUUID)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(bookPublication(c.BaseURL(), *bookDetail), dto.OPDS2PublicationType)
+}
+
+func (ops *OPDSService) BorrowV2Handler(c *fiber.Ctx) error {
+	bookDetail, loanDetail, restErr := ops.borrow(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	publication := bookPublication(c.BaseURL(), *bookDetail)
+	publication.Links[0].Properties.Availability = &dto.OPDS2Availability{
+		State: opdsStatusReady,
+		Since: loanDetail.LoanDate.UTC().Format(time.RFC3339),
+		Until: loanDetail.ReturnDate.UTC().Format(time.RFC3339),
+	}
+
+	return c.Status(fiber.StatusOK).JSON(publication, dto.OPDS2PublicationType)
+}
+
+/********************
+ *     Helpers     *
+ ********************/
+
+func (ops *OPDSService) search(requestID string, params dto.OPDSSearchRequest) (*dto.FullTextSearchResponse, *apperrors.RestErr) {
+	return ops.searchEngine.SearchBooks(requestID, dto.FullTextSearchRequest{
+		Query:  params.Query,
+		Limit:  opdsSearchLimit,
+		Offset: (params.Page - 1) * opdsSearchLimit,
+	})
+}
+
+// borrow lends the book of the `:uuid` route parameter to the session's user and reloads it for its copies.
+func (ops *OPDSService) borrow(c *fiber.Ctx) (*dto.BookDetail, *dto.LoanDetail, *apperrors.RestErr) {
+	requestID, bookUUID, restErr := getCatalogContextInfo(c)
+	if restErr != nil {
+		return nil, nil, restErr
+	}
+
+	userDetail, ok := c.Locals("userDetail").(dto.UserDetail)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "userDetail")
+		return nil, nil, apperrors.NewBadRequestError(apperrors.ErrMsgSomethingWentWrong)
+	}
+
+	loanDetail, restErr := ops.loanService.BorrowBookByUUID(requestID, userDetail, bookUUID)
+	if restErr != nil {
+		return nil, nil, restErr
+	}
+
+	bookDetail, restErr := ops.bookPGDB.GetBookByUUID(requestID, bookUUID)
+	if restErr != nil {
+		return nil, nil, restErr
+	}
+
+	return bookDetail, loanDetail, nil
+}
+
+func newAtomFeed(id string, title string, links ...dto.AtomLink) dto.AtomFeed {
+	return dto.AtomFeed{
+		Xmlns:           atomNamespace,
+		XmlnsDC:         dcNamespace,
+		XmlnsOPDS:       opdsNamespace,
+		XmlnsOpenSearch: openSearchNamespace,
+		ID:              id,
+		Title:           title,
+		Updated:         time.Now().UTC().Format(time.RFC3339),
+		Links:           links,
+		Entries:         []dto.AtomEntry{},
+	}
+}
+
+// bookEntry is the Atom entry of a book; its first link is the borrow link.
+func bookEntry(baseURL string, book dto.BookDetail, updated string) dto.AtomEntry {
+	entry := dto.AtomEntry{
+		ID:         "urn:uuid:" + book.UUID.String(),
+		Title:      book.Title,
+		Updated:    updated,
+		Identifier: "urn:isbn:" + book.ISBN13,
+		Language:   book.Language,
+		Publisher:  book.Publisher,
+		Summary:    book.Description,
+		Links: []dto.AtomLink{{
+			Rel:          dto.OPDSRelBorrow,
+			Href:         baseURL + opdsPath + "/books/" + book.UUID.String() + "/borrow",
+			Type:         dto.OPDSEntryType,
+			Availability: &dto.OPDSAvailability{Status: availabilityStatus(book)},
+			Copies:       &dto.OPDSCopies{Total: book.TotalCopies, Available: book.AvailableCopies},
+		}},
+	}
+
+	for _, author := range book.Authors {
+		entry.Authors = append(entry.Authors, dto.AtomAuthor{Name: author})
+	}
+	if book.PublicationYear > 0 {
+		entry.Issued = strconv.Itoa(book.PublicationYear)
+	}
+	return entry
+}
+
+// bookPublication is the OPDS 2.0 publication of a book; its first link is the borrow link.
+func bookPublication(baseURL string, book dto.BookDetail) dto.OPDS2Publication {
+	publication := dto.OPDS2Publication{
+		Metadata: dto.OPDS2PublicationMetadata{
+			Type:        schemaOrgBook,
+			Identifier:  "urn:isbn:" + book.ISBN13,
+			Title:       book.Title,
+			Author:      book.Authors,
+			Language:    book.Language,
+			Publisher:   book.Publisher,
+			Description: book.Description,
+		},
+		Links: []dto.OPDS2Link{{
+			Rel:  dto.OPDSRelBorrow,
+			Href: baseURL + opdsV2Path + "/books/" + book.UUID.String() + "/borrow",
+			Type: dto.OPDS2PublicationType,
+			Properties: &dto.OPDS2LinkProperties{
+				Availability: &dto.OPDS2Availability{State: availabilityStatus(book)},
+				Copies:       &dto.OPDS2Copies{Total: book.TotalCopies, Available: book.AvailableCopies},
+			},
+		}},
+	}
+
+	if book.PublicationYear > 0 {
+		publication.Metadata.Published = strconv.Itoa(book.PublicationYear)
+	}
+	return publication
+}
+
+func availabilityStatus(book dto.BookDetail) string {
+	if book.AvailableCopies > 0 {
+		return opdsStatusAvailable
+	}
+	return opdsStatusUnavailable
+}
+
+func opdsSearchLink(baseURL string) dto.AtomLink {
+	return dto.AtomLink{Rel: "search", Href: baseURL + opdsPath + "/opensearch.xml", Type: dto.OpenSearchType}
+}
+
+func opdsV2SearchLink(baseURL string) dto.OPDS2Link {
+	return dto.OPDS2Link{Rel: "search", Href: baseURL + opdsV2Path + "/search{?q}", Type: dto.OPDS2FeedType, Templated: true}
+}
+
+// bookListPageURL keeps the filter and sort order of the books feed across its pages.
+func bookListPageURL(booksURL string, params dto.BookListRequest, cursor string) string {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(params.Limit))
+	query.Set("sort_by", params.SortBy)
+	query.Set("order", params.Order)
+	if params.Available {
+		query.Set("available", "true")
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	return booksURL + "?" + query.Encode()
+}
+
+func searchPageURL(searchURL string, searchTerms string, page int) string {
+	query := url.Values{}
+	query.Set("q", searchTerms)
+	query.Set("page", strconv.Itoa(page))
+	return searchURL + "?" + query.Encode()
+}
+
+func hasNextSearchPage(params dto.OPDSSearchRequest, searchResponse *dto.FullTextSearchResponse) bool {
+	return params.Page*opdsSearchLimit < searchResponse.Total
+}
+
+func withQuery(path string, query string) string {
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+func sendAtom(c *fiber.Ctx, contentType string, document any) error {
+	body, err := xml.Marshal(document)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to render OPDS feed")
+		restErr := apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Status(fiber.StatusOK).Send(append([]byte(xml.Header), body...))
+}
+
+func getBookListContextInfo(c *fiber.Ctx) (dto.BookListRequest, string) {
+	params, ok := c.Locals("bookListKey").(dto.BookListRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "bookListKey")
+	}
+
+	requestID, ok := c.Locals("requestid").(string)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "requestid")
+	}
+
+	return params, requestID
+}
+
+func getOPDSSearchContextInfo(c *fiber.Ctx) (dto.OPDSSearchRequest, string) {
+	params, ok := c.Locals("opdsSearchKey").(dto.OPDSSearchRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "opdsSearchKey")
+	}
+
+	requestID, ok := c.Locals("requestid").(string)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "requestid")
+	}
+
+	return params, requestID
+}
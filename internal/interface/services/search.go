@@ -0,0 +1,41 @@
+package services
+
+import (
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	appSvc "github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type SearchService struct {
+	searchEngine repository.SearchRepository
+}
+
+func NewSearchService(searchEngine repository.SearchRepository) appSvc.SearchService {
+	return &SearchService{searchEngine}
+}
+
+func (ss *SearchService) SearchHandler(c *fiber.Ctx) error {
+	params, ok := c.Locals("fullTextSearchKey").(dto.FullTextSearchRequest)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "fullTextSearchKey")
+	}
+
+	requestID, ok := c.Locals("requestid").(string)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "requestid")
+	}
+
+	searchResponse, err := ss.Search(requestID, params)
+	if err != nil {
+		return c.Status(err.Status).JSON(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(searchResponse)
+}
+
+func (ss *SearchService) Search(requestID string, params dto.FullTextSearchRequest) (*dto.FullTextSearchResponse, *apperrors.RestErr) {
+	return ss.searchEngine.SearchBooks(requestID, params)
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
+	appSvc "github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
+	mw "github.com/DarrelA/e-lib/internal/interface/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const errMsgInvalidSessionID = "invalid session id"
+
+type SessionService struct {
+	sessionRedis repository.SessionRepository
+}
+
+func NewSessionService(sessionRedis repository.SessionRepository) appSvc.SessionService {
+	return &SessionService{sessionRedis}
+}
+
+// LogoutHandler revokes the session of the request. A session that is already gone is logged out as well.
+func (ss *SessionService) LogoutHandler(c *fiber.Ctx) error {
+	userDetail, session, restErr := getSessionContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	// A session from before sessions had IDs is revoked by the session ID of its cookie.
+	if session.ID == "" {
+		restErr = ss.sessionRedis.DeleteSessionData(c.Cookies(mw.CookieSession))
+	} else {
+		restErr = ss.sessionRedis.DeleteSession(userDetail.ID, session.ID)
+	}
+	if restErr != nil && restErr.Status != fiber.StatusNotFound {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	c.Cookie(mw.NewSessionCookie("", -1))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success"})
+}
+
+func (ss *SessionService) GetSessionsHandler(c *fiber.Ctx) error {
+	userDetail, session, restErr := getSessionContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	sessionList, restErr := ss.GetSessions(userDetail.ID, session.ID)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+	return c.Status(fiber.StatusOK).JSON(sessionList)
+}
+
+func (ss *SessionService) GetSessions(userID int64, currentID string) (*dto.SessionListResponse, *apperrors.RestErr) {
+	sessions, restErr := ss.sessionRedis.GetUserSessions(userID)
+	if restErr != nil {
+		return nil, restErr
+	}
+
+	sessionList := &dto.SessionListResponse{Sessions: make([]dto.SessionDetail, 0, len(sessions))}
+	for _, session := range sessions {
+		createdAt, err := strconv.ParseInt(session.CreatedAt, 10, 64)
+		if err != nil {
+			log.Error().Err(err).Msg("error converting session.CreatedAt (string) to int64")
+			return nil, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		}
+
+		sessionList.Sessions = append(sessionList.Sessions, dto.SessionDetail{
+			ID:        session.ID,
+			CreatedAt: time.Unix(createdAt, 0).UTC(),
+			IP:        session.IP,
+			UserAgent: session.UserAgent,
+			Current:   session.ID == currentID,
+		})
+	}
+
+	return sessionList, nil
+}
+
+func (ss *SessionService) RevokeSessionHandler(c *fiber.Ctx) error {
+	userDetail, session, restErr := getSessionContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	id := c.Params("id")
+	if _, err := uuid.Parse(id); err != nil {
+		restErr := apperrors.NewBadRequestError(errMsgInvalidSessionID)
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	if restErr := ss.sessionRedis.DeleteSession(userDetail.ID, id); restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	if id == session.ID {
+		c.Cookie(mw.NewSessionCookie("", -1))
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success"})
+}
+
+// RevokeSessionsHandler revokes every session of the user, or with ?keep_current=true every session but
+// the one of the request, e.g. after a cookie was stolen.
+func (ss *SessionService) RevokeSessionsHandler(c *fiber.Ctx) error {
+	userDetail, session, restErr := getSessionContextInfo(c)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	keepCurrent := c.QueryBool("keep_current")
+	exceptID := ""
+	if keepCurrent {
+		exceptID = session.ID
+	}
+
+	revoked, restErr := ss.sessionRedis.DeleteUserSessions(userDetail.ID, exceptID)
+	if restErr != nil {
+		return c.Status(restErr.Status).JSON(restErr)
+	}
+
+	if !keepCurrent {
+		c.Cookie(mw.NewSessionCookie("", -1))
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "revoked": revoked})
+}
+
+func getSessionContextInfo(c *fiber.Ctx) (dto.UserDetail, *entity.Session, *apperrors.RestErr) {
+	restErr := apperrors.NewBadRequestError(apperrors.ErrMsgSomethingWentWrong)
+	userDetail, ok := c.Locals("userDetail").(dto.UserDetail)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "userDetail")
+		return dto.UserDetail{}, nil, restErr
+	}
+
+	session, ok := c.Locals("session").(*entity.Session)
+	if !ok {
+		log.Error().Msgf(errMsgNotFoundOrIncorrectType, "session")
+		return dto.UserDetail{}, nil, restErr
+	}
+
+	return userDetail, session, nil
+}
@@ -3,7 +3,10 @@ package rest
 import (
 	"github.com/DarrelA/e-lib/config"
 	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/DarrelA/e-lib/internal/application/dto"
 	appSvc "github.com/DarrelA/e-lib/internal/application/services"
+	"github.com/DarrelA/e-lib/internal/domain/entity"
+	"github.com/DarrelA/e-lib/internal/domain/repository"
 	"github.com/DarrelA/e-lib/internal/infrastructure/db/postgres"
 	mw "github.com/DarrelA/e-lib/internal/interface/middleware"
 	"github.com/gofiber/fiber/v2"
@@ -11,6 +14,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Catalog import files are uploaded in one request, so the body limit is above Fiber's default of 4 MB.
+const maxRequestBodySize = 32 * 1024 * 1024
+
 func StartServer(app *fiber.App, port string) {
 	log.Info().Msg("listening at port: " + port)
 	err := app.Listen(":" + port)
@@ -21,13 +27,19 @@ func StartServer(app *fiber.App, port string) {
 
 func NewRouter(
 	config *config.EnvConfig,
-	googleOAuth2Service appSvc.GoogleOAuth2Service, postgresDBInstance *postgres.PostgresDB,
-	bookService appSvc.BookService, loanService appSvc.LoanService,
+	googleOAuth2Service appSvc.GoogleOAuth2Service, oidcService appSvc.OIDCService, sessionService appSvc.SessionService,
+	apiTokenService appSvc.APITokenService,
+	postgresDBInstance *postgres.PostgresDB,
+	bookService appSvc.BookService, loanService appSvc.LoanService, holdService appSvc.HoldService, searchService appSvc.SearchService,
+	catalogService appSvc.CatalogService, catalogImportService appSvc.CatalogImportService,
+	opdsService appSvc.OPDSService,
 	newSessionFunc mw.NewSessionFunc, saveUserFunc mw.SaveUserFunc,
-	getSessionDataFunc mw.GetSessionByIDFunc, getUserByIDFunc mw.GetUserByIDFunc,
+	getSessionDataFunc mw.GetSessionByIDFunc, refreshSessionFunc mw.RefreshSessionFunc,
+	getAPITokenByHashFunc mw.GetAPITokenByHashFunc, getUserByIDFunc mw.GetUserByIDFunc,
+	idempotencyRepository repository.IdempotencyRepository,
 ) *fiber.App {
 	log.Info().Msg("creating fiber instances")
-	appInstance := fiber.New()
+	appInstance := fiber.New(fiber.Config{BodyLimit: maxRequestBodySize})
 
 	log.Info().Msg("connecting middlewares")
 	useMiddlewares(appInstance, config.AppEnv)
@@ -43,20 +55,37 @@ func NewRouter(
 	auth := appInstance.Group("/auth")
 	auth.Get("/google_login", googleOAuth2Service.Login)
 	auth.Get("/google_callback", googleOAuth2Service.Callback)
+	auth.Get("/oidc/:provider/login", oidcService.Login)
+	auth.Get("/oidc/:provider/callback", oidcService.Callback)
 
 	/********************
-	*       e-Lib       *
-	********************/
-	appInstance.Use(mw.InputValidator)
+	 *   BookService   *
+	 ********************/
+	appInstance.Get("/Books", mw.BookListValidator, bookService.ListBooksHandler)
+	appInstance.Get("/Books/search", mw.BookSearchValidator, bookService.SearchBooksHandler)
+	appInstance.Get("/Book", mw.InputValidator, bookService.GetBookByTitleHandler)
 
 	/********************
-	 *   BookService   *
+	 *  SearchService  *
 	 ********************/
-	appInstance.Get("/Book", bookService.GetBookByTitleHandler)
+	appInstance.Get("/Search", mw.FullTextSearchValidator, searchService.SearchHandler)
 
 	/********************
-	*   LoanService   *
-	********************/
+	 *   OPDSService   *
+	 ********************/
+	opds := appInstance.Group("/opds")
+	opds.Get("/", opdsService.NavigationFeedHandler)
+	opds.Get("/books", mw.BookListValidator, opdsService.BooksFeedHandler)
+	opds.Get("/search", mw.OPDSSearchValidator, opdsService.SearchFeedHandler)
+	opds.Get("/opensearch.xml", opdsService.OpenSearchDescriptionHandler)
+	opds.Get("/v2", opdsService.NavigationFeedV2Handler)
+	opds.Get("/v2/books", mw.BookListValidator, opdsService.BooksFeedV2Handler)
+	opds.Get("/v2/search", mw.OPDSSearchValidator, opdsService.SearchFeedV2Handler)
+	// A GET of a borrow link, e.g. by a crawler or a prefetch, only shows the book; borrowing takes a POST.
+	opds.Get("/books/:uuid/borrow", opdsService.BorrowEntryHandler)
+	opds.Get("/v2/books/:uuid/borrow", opdsService.BorrowEntryV2Handler)
+
+	// Every route below requires a session, or a personal access token with the scope of the route.
 	if config.AppEnv == "test" {
 		mockUserSessionMiddleware := mw.NewMockUserSessionMiddleware(newSessionFunc, saveUserFunc)
 		appInstance.Use(func(c *fiber.Ctx) error {
@@ -64,14 +93,58 @@ func NewRouter(
 		})
 	}
 
-	authMiddleware := mw.NewAuthMiddleware(getSessionDataFunc, getUserByIDFunc)
+	authMiddleware := mw.NewAuthMiddleware(getSessionDataFunc, refreshSessionFunc, getAPITokenByHashFunc, getUserByIDFunc)
 	appInstance.Use(func(c *fiber.Ctx) error {
 		return authMiddleware.Authenticate(c)
 	})
 
-	appInstance.Post("/Borrow", loanService.BorrowBookHandler)
-	appInstance.Post("/Extend", loanService.ExtendBookLoanHandler)
-	appInstance.Post("/Return", loanService.ReturnBookHandler)
+	/********************
+	*  SessionService  *
+	********************/
+	auth.Post("/logout", mw.RequireSession, sessionService.LogoutHandler)
+	auth.Get("/sessions", mw.RequireSession, sessionService.GetSessionsHandler)
+	auth.Delete("/sessions", mw.RequireSession, sessionService.RevokeSessionsHandler)
+	auth.Delete("/sessions/:id", mw.RequireSession, sessionService.RevokeSessionHandler)
+
+	/********************
+	*  APITokenService *
+	********************/
+	auth.Post("/tokens", mw.RequireSession, mw.BodyValidator[dto.APITokenCreateRequest]("apiTokenCreateKey"),
+		apiTokenService.CreateAPITokenHandler)
+	auth.Get("/tokens", mw.RequireSession, apiTokenService.GetAPITokensHandler)
+	auth.Delete("/tokens/:uuid", mw.RequireSession, apiTokenService.RevokeAPITokenHandler)
+
+	/********************
+	*   LoanService   *
+	********************/
+	// Clients may retry these with the same Idempotency-Key to have them applied at most once.
+	idempotencyMiddleware := mw.NewIdempotencyMiddleware(idempotencyRepository)
+	requireLoansWrite := mw.RequireScope(entity.ScopeLoansWrite)
+	appInstance.Post("/Borrow", requireLoansWrite, idempotencyMiddleware.Handle, mw.InputValidator, loanService.BorrowBookHandler)
+	appInstance.Post("/Extend", requireLoansWrite, idempotencyMiddleware.Handle, mw.InputValidator, loanService.ExtendBookLoanHandler)
+	appInstance.Post("/Return", requireLoansWrite, idempotencyMiddleware.Handle, mw.InputValidator, loanService.ReturnBookHandler)
+	appInstance.Get("/Loans", mw.RequireScope(entity.ScopeLoansRead), mw.LoanListValidator, loanService.GetLoansHandler)
+	appInstance.Get("/Loans/:uuid/history", mw.RequireScope(entity.ScopeLoansRead), loanService.GetLoanHistoryHandler)
+
+	/********************
+	*   HoldService   *
+	********************/
+	appInstance.Post("/Hold", mw.RequireScope(entity.ScopeHoldsWrite), mw.InputValidator, holdService.PlaceHoldHandler)
+	appInstance.Delete("/Hold", mw.RequireScope(entity.ScopeHoldsWrite), mw.InputValidator, holdService.CancelHoldHandler)
+	appInstance.Get("/Holds", mw.RequireScope(entity.ScopeHoldsRead), holdService.GetHoldsHandler)
+
+	opds.Post("/books/:uuid/borrow", requireLoansWrite, opdsService.BorrowHandler)
+	opds.Post("/v2/books/:uuid/borrow", requireLoansWrite, opdsService.BorrowV2Handler)
+
+	/********************
+	*  CatalogService  *
+	********************/
+	admin := appInstance.Group("/admin", mw.RequireRole(entity.RoleLibrarian), mw.RequireScope(entity.ScopeCatalogWrite))
+	admin.Post("/books", mw.BodyValidator[dto.BookCreateRequest]("bookCreateKey"), catalogService.CreateBookHandler)
+	admin.Patch("/books/:uuid", mw.BodyValidator[dto.BookUpdateRequest]("bookUpdateKey"), catalogService.UpdateBookHandler)
+	admin.Patch("/books/:uuid/copies", mw.BodyValidator[dto.BookCopiesRequest]("bookCopiesKey"), catalogService.AdjustCopiesHandler)
+	admin.Delete("/books/:uuid", catalogService.WithdrawBookHandler)
+	admin.Post("/books/import", mw.CatalogImportValidator, catalogImportService.ImportBooksHandler)
 
 	appInstance.All("*", func(c *fiber.Ctx) error {
 		path := c.Path()
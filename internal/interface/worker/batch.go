@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// BatchFunc processes up to batchSize items and returns how many it processed.
+type BatchFunc func(requestID string, batchSize int) (int, *apperrors.RestErr)
+
+// BatchWorker periodically runs a background job in batches, e.g. expiring loans or hold offers.
+// The jobs lock their rows with SKIP LOCKED, so every instance of the app can run the same workers.
+type BatchWorker struct {
+	name      string
+	interval  time.Duration
+	batchSize int
+	job       BatchFunc
+}
+
+func NewBatchWorker(name string, interval time.Duration, batchSize int, job BatchFunc) *BatchWorker {
+	return &BatchWorker{name, interval, batchSize, job}
+}
+
+// Run runs the job every interval until the context is cancelled.
+func (w *BatchWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	log.Info().Msgf("%s runs every %s", w.name, w.interval)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msgf("%s has stopped", w.name)
+			return
+		case <-ticker.C:
+			w.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce runs batches until one comes back short, so that a backlog is worked off in a single tick.
+func (w *BatchWorker) RunOnce(ctx context.Context) {
+	for ctx.Err() == nil {
+		processedCount, restErr := w.job(uuid.NewString(), w.batchSize)
+		if restErr != nil {
+			log.Error().Msgf("%s failed: %s", w.name, restErr.Message)
+			return
+		}
+
+		if processedCount < w.batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DarrelA/e-lib/internal/apperrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchWorker(t *testing.T) {
+	t.Run("RunOnce works off the backlog until a batch comes back short", func(t *testing.T) {
+		backlog := 7
+		var batchSizes []int
+		w := NewBatchWorker("test job", time.Hour, 3, func(requestID string, batchSize int) (int, *apperrors.RestErr) {
+			assert.NotEmpty(t, requestID)
+			batchSizes = append(batchSizes, batchSize)
+			processedCount := min(backlog, batchSize)
+			backlog -= processedCount
+			return processedCount, nil
+		})
+
+		w.RunOnce(context.Background())
+		assert.Equal(t, []int{3, 3, 3}, batchSizes)
+		assert.Equal(t, 0, backlog)
+	})
+
+	t.Run("RunOnce stops at the first error", func(t *testing.T) {
+		calls := 0
+		w := NewBatchWorker("test job", time.Hour, 3, func(requestID string, batchSize int) (int, *apperrors.RestErr) {
+			calls++
+			return 0, apperrors.NewInternalServerError(apperrors.ErrMsgSomethingWentWrong)
+		})
+
+		w.RunOnce(context.Background())
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Run stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		batches := make(chan struct{}, 10)
+		w := NewBatchWorker("test job", time.Millisecond, 3, func(requestID string, batchSize int) (int, *apperrors.RestErr) {
+			select {
+			case batches <- struct{}{}:
+			default:
+			}
+			return 0, nil
+		})
+
+		stopped := make(chan struct{})
+		go func() {
+			w.Run(ctx)
+			close(stopped)
+		}()
+
+		<-batches
+		cancel()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("worker did not stop after the context was cancelled")
+		}
+	})
+}